@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -31,24 +32,13 @@ func main() {
 
 	metrics.Init()
 
-	clientPool, err := client.NewPool(cfg.Clients)
+	clientPool, err := client.NewPool(cfg.Clients, cfg.Pool)
 	if err != nil {
 		log.Fatalf("Failed to initialize client pool: %v", err)
 	}
 
-	go clientPool.CheckAllHealth()
-
-	go func() {
-		ticker := time.NewTicker(cfg.HealthCheckInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				clientPool.CheckAllHealth()
-			}
-		}
-	}()
+	healthMonitor := client.NewHealthMonitor(clientPool, cfg.HealthCheckInterval)
+	healthMonitor.Start(context.Background())
 
 	router := handler.SetupRouter(clientPool, cfg)
 
@@ -61,6 +51,48 @@ func main() {
 
 	log.Printf("Server started on port %s\n", cfg.ServerPort)
 
+	// The admin API listens on its own port, so it can be kept off the public network
+	var adminSrv *server.Server
+	if cfg.Admin.Port != "" {
+		adminSrv = server.New(handler.SetupAdminRouter(clientPool, cfg), cfg.Admin.Port)
+		go func() {
+			if err := adminSrv.Start(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start admin server: %v", err)
+			}
+		}()
+		log.Printf("Admin API started on port %s\n", cfg.Admin.Port)
+	}
+
+	// Reloads are applied in order: the pool first, as it is the only step that can fail,
+	// then fresh routers built from the new settings. Requests in flight finish on the old ones.
+	running := cfg
+	watcher := config.NewWatcher(os.Getenv("CONFIG_FILE"), func(next *config.Config) error {
+		if err := clientPool.Reconfigure(next.Clients, next.Pool); err != nil {
+			return err
+		}
+
+		srv.SetHandler(handler.SetupRouter(clientPool, next))
+		if adminSrv != nil {
+			adminSrv.SetHandler(handler.SetupAdminRouter(clientPool, next))
+		}
+
+		healthMonitor.SetInterval(next.HealthCheckInterval)
+
+		if next.ServerPort != running.ServerPort || next.Admin.Port != running.Admin.Port {
+			log.Println("Warning: listener port changes take effect after a restart")
+		}
+		running = next
+		return nil
+	})
+
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go func() {
+		if err := watcher.Run(watchCtx); err != nil {
+			log.Printf("Configuration hot reload disabled: %v\n", err)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -72,6 +104,14 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server shutdown failed: %v", err)
 	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(ctx); err != nil {
+			log.Printf("Admin server shutdown failed: %v", err)
+		}
+	}
+
+	healthMonitor.Stop()
+	clientPool.Close()
 
 	log.Println("Server gracefully stopped")
 }
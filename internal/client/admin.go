@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// drainPollInterval is how often a drain checks whether a client's in-flight requests have finished
+const drainPollInterval = 20 * time.Millisecond
+
+// Override is a manual routing decision made by an operator. While set it takes
+// precedence over health checks, until it is cleared.
+type Override string
+
+const (
+	// OverrideNone leaves routing to health checks
+	OverrideNone Override = ""
+	// OverrideCordoned takes a client out of rotation, requests already sent to it finish normally
+	OverrideCordoned Override = "cordoned"
+	// OverrideEnabled keeps a client in rotation even when health checks fail, report it degraded
+	// or it was ejected for disagreeing with consensus.
+	// Clients on the wrong chain or with an open circuit breaker still get no traffic.
+	OverrideEnabled Override = "enabled"
+)
+
+// overrideCodes are the values of the client_override metric
+var overrideCodes = map[Override]int{
+	OverrideNone:     0,
+	OverrideCordoned: 1,
+	OverrideEnabled:  2,
+}
+
+// Errors returned by the pool administration methods
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrClientExists   = errors.New("client already exists")
+)
+
+// ClientStatus is a snapshot of a client's live state
+type ClientStatus struct {
+	Name string `json:"name"`
+	// URL is stripped of credentials, paths and queries, which commonly hold API keys
+	URL       string `json:"url"`
+	Weight    int    `json:"weight"`
+	Tier      int    `json:"tier"`
+	Serving   bool   `json:"serving"`
+	Available bool   `json:"available"`
+	// AvailabilityReason is what last changed Available: health_check, passive or manual
+	AvailabilityReason string   `json:"availability_reason,omitempty"`
+	Degraded           bool     `json:"degraded"`
+	Quarantined        bool     `json:"quarantined"`
+	Throttled          bool     `json:"throttled"`
+	Override           Override `json:"override"`
+	Breaker            string   `json:"breaker"`
+	// Reputation is the ratio of recent consensus votes the client agreed with
+	Reputation float64 `json:"reputation"`
+	Ejected    bool    `json:"ejected"`
+	// StateHistory is archive, full or unknown while it is being detected
+	StateHistory string  `json:"state_history"`
+	StateWindow  uint64  `json:"state_window,omitempty"`
+	InFlight     int64   `json:"in_flight"`
+	HeadBlock    uint64  `json:"head_block"`
+	LatencyMs    float64 `json:"latency_ms"`
+	// LastHealthCheck is unset until the client has been health checked
+	LastHealthCheck *HealthResult `json:"last_health_check,omitempty"`
+}
+
+// Status returns a snapshot of every client in the pool
+func (p *PoolStruct) Status() []ClientStatus {
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+
+	statuses := make([]ClientStatus, 0, len(p.clients))
+	for _, client := range p.clients {
+		statuses = append(statuses, client.status())
+	}
+	return statuses
+}
+
+// ClientStatus returns a snapshot of the named client
+func (p *PoolStruct) ClientStatus(name string) (ClientStatus, error) {
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+
+	client := p.findClient(name)
+	if client == nil {
+		return ClientStatus{}, fmt.Errorf("%w: %s", ErrClientNotFound, name)
+	}
+	return client.status(), nil
+}
+
+// status builds the client's snapshot, callers must hold the pool lock
+func (c *Client) status() ClientStatus {
+	status := ClientStatus{
+		Name:               c.Name,
+		URL:                redactURL(c.URL),
+		Weight:             c.Weight,
+		Tier:               c.Tier,
+		Serving:            c.isServing(),
+		Available:          c.IsAvailable,
+		AvailabilityReason: c.availabilityReason,
+		Degraded:           c.IsDegraded,
+		Quarantined:        c.quarantined,
+		Throttled:          !c.limiter.Ready(),
+		Override:           c.override,
+		Breaker:            c.breaker.State().String(),
+		Reputation:         c.reputation.score(),
+		Ejected:            c.reputation.ejected,
+		StateHistory:       c.stateHistory.String(),
+		StateWindow:        c.stateHistory.Window,
+		InFlight:           c.inFlight.Load(),
+		HeadBlock:          c.Head().Number,
+		LatencyMs:          float64(c.Latency()) / float64(time.Millisecond),
+	}
+	if last, ok := c.health.last(); ok {
+		status.LastHealthCheck = &last
+	}
+	return status
+}
+
+// AddClient creates a client and adds it to the pool while requests are in flight.
+// When an expected chain id is configured the client is only added once it is verified
+// to be on that chain. It is health checked before it takes traffic, nothing is added when ctx ends first.
+// The client survives configuration reloads until it is removed or the file defines a client of the same name.
+func (p *PoolStruct) AddClient(ctx context.Context, cfg config.ClientConfig) (ClientStatus, error) {
+	if cfg.Name == "" || cfg.URL == "" {
+		return ClientStatus{}, fmt.Errorf("client name and url are required")
+	}
+
+	p.mutateMutex.Lock()
+	defer p.mutateMutex.Unlock()
+
+	p.clientsMutex.RLock()
+	exists := p.findClient(cfg.Name) != nil
+	p.clientsMutex.RUnlock()
+	if exists {
+		return ClientStatus{}, fmt.Errorf("%w: %s", ErrClientExists, cfg.Name)
+	}
+
+	client, err := p.newClient(cfg)
+	if err != nil {
+		return ClientStatus{}, err
+	}
+	client.runtime = true
+
+	if p.config().ExpectedChainID != 0 {
+		verifyCtx, cancel := context.WithTimeout(ctx, chainVerifyTimeout)
+		err := p.verifyChain(verifyCtx, client)
+		cancel()
+		if err != nil {
+			client.Close()
+			return ClientStatus{}, fmt.Errorf("client %s: %w", cfg.Name, err)
+		}
+	}
+	// The probe gives up when the admin request is cancelled, a client that hangs does not hold up other changes
+	p.checkClientHealth(ctx, client)
+	if err := ctx.Err(); err != nil {
+		client.Close()
+		metrics.RemoveClient(cfg.Name)
+		return ClientStatus{}, fmt.Errorf("client %s: %w", cfg.Name, err)
+	}
+
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	// The slice is replaced rather than appended to, so snapshots handed out earlier stay untouched
+	p.clients = append(slices.Clone(p.clients), client)
+	log.Printf("Added client %s\n", client.Name)
+
+	return client.status(), nil
+}
+
+// RemoveClient takes a client out of the pool, waits for its in-flight requests to
+// finish and closes it. When ctx expires first the client is closed anyway,
+// failing the requests that are still running.
+func (p *PoolStruct) RemoveClient(ctx context.Context, name string) error {
+	p.mutateMutex.Lock()
+	p.clientsMutex.Lock()
+	i := slices.IndexFunc(p.clients, func(client *Client) bool { return client.Name == name })
+	if i < 0 {
+		p.clientsMutex.Unlock()
+		p.mutateMutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrClientNotFound, name)
+	}
+	client := p.clients[i]
+	p.clients = slices.Delete(slices.Clone(p.clients), i, i+1)
+	p.clientsMutex.Unlock()
+	p.mutateMutex.Unlock()
+
+	err := client.waitIdle(ctx)
+	if err != nil {
+		log.Printf("Closing client %s with %d requests in flight: %v\n", name, client.inFlight.Load(), err)
+	}
+
+	if closeErr := client.Close(); closeErr != nil {
+		log.Printf("Error closing client %s: %v\n", name, closeErr)
+	}
+	metrics.RemoveClient(name)
+	log.Printf("Removed client %s\n", name)
+
+	return err
+}
+
+// SetOverride sets or clears the manual override of a client
+func (p *PoolStruct) SetOverride(name string, override Override) (ClientStatus, error) {
+	if _, ok := overrideCodes[override]; !ok {
+		return ClientStatus{}, fmt.Errorf("unknown override %q", override)
+	}
+
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	client := p.findClient(name)
+	if client == nil {
+		return ClientStatus{}, fmt.Errorf("%w: %s", ErrClientNotFound, name)
+	}
+
+	if client.override != override {
+		log.Printf("Client %s override changed from %q to %q\n", name, client.override, override)
+	}
+	client.override = override
+	metrics.SetClientOverride(name, overrideCodes[override])
+
+	return client.status(), nil
+}
+
+// Drain cordons a client and waits until its in-flight requests have finished.
+// The client stays cordoned in the pool until the override is cleared.
+func (p *PoolStruct) Drain(ctx context.Context, name string) (ClientStatus, error) {
+	if _, err := p.SetOverride(name, OverrideCordoned); err != nil {
+		return ClientStatus{}, err
+	}
+
+	p.clientsMutex.RLock()
+	client := p.findClient(name)
+	p.clientsMutex.RUnlock()
+	if client == nil {
+		return ClientStatus{}, fmt.Errorf("%w: %s", ErrClientNotFound, name)
+	}
+
+	err := client.waitIdle(ctx)
+
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+	return client.status(), err
+}
+
+// findClient returns the named client or nil, callers must hold the pool lock
+func (p *PoolStruct) findClient(name string) *Client {
+	for _, client := range p.clients {
+		if client.Name == name {
+			return client
+		}
+	}
+	return nil
+}
+
+// waitIdle waits until the client has no requests in flight
+func (c *Client) waitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for c.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("client %s still has %d requests in flight: %w", c.Name, c.inFlight.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// redactURL keeps only the scheme and host of a client URL, socket paths of IPC clients are kept as they are
+func redactURL(raw string) string {
+	if isIPCURL(raw) {
+		return raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return "[redacted]"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func availableNames(pool *PoolStruct) []string {
+	var names []string
+	for _, client := range pool.GetAvailableClients() {
+		names = append(names, client.Name)
+	}
+	return names
+}
+
+func TestPoolStruct_OverridesWinOverHealthChecks(t *testing.T) {
+	healthy := newHeadServer(t, 100, 1700000000)
+	broken := rpctest.NewServer()
+	defer broken.Close()
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: healthy.URL, Name: "healthy", Timeout: time.Second},
+		{URL: broken.URL, Name: "broken", Timeout: time.Second},
+	}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pool.CheckAllHealth()
+	assert.Equal(t, []string{"healthy"}, availableNames(pool))
+
+	_, err = pool.SetOverride("healthy", OverrideCordoned)
+	require.NoError(t, err)
+	_, err = pool.SetOverride("broken", OverrideEnabled)
+	require.NoError(t, err)
+
+	pool.CheckAllHealth()
+	assert.Equal(t, []string{"broken"}, availableNames(pool), "overrides survive health checks")
+
+	status, err := pool.ClientStatus("healthy")
+	require.NoError(t, err)
+	assert.True(t, status.Available, "health results are still recorded")
+	assert.False(t, status.Serving)
+	assert.Equal(t, OverrideCordoned, status.Override)
+
+	for _, name := range []string{"healthy", "broken"} {
+		_, err = pool.SetOverride(name, OverrideNone)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"healthy"}, availableNames(pool), "cleared overrides hand back to health checks")
+
+	_, err = pool.SetOverride("missing", OverrideCordoned)
+	assert.ErrorIs(t, err, ErrClientNotFound)
+	_, err = pool.SetOverride("healthy", Override("paused"))
+	assert.Error(t, err)
+}
+
+func TestPoolStruct_AddAndRemoveClients(t *testing.T) {
+	first := newHeadServer(t, 100, 1700000000)
+	second := newHeadServer(t, 100, 1700000000)
+
+	release := make(chan struct{})
+	second.Handle("eth_blockNumber", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		<-release
+		return "0x64", nil
+	})
+
+	pool, err := NewPool([]config.ClientConfig{{URL: first.URL, Name: "first", Timeout: time.Second}}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	status, err := pool.AddClient(context.Background(), config.ClientConfig{URL: second.URL + "/v3/secret-key", Name: "second", Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	assert.True(t, status.Serving)
+	assert.Equal(t, second.URL, status.URL, "API keys in the path are not exposed")
+	assert.ElementsMatch(t, []string{"first", "second"}, availableNames(pool))
+
+	_, err = pool.AddClient(context.Background(), config.ClientConfig{URL: second.URL, Name: "second"})
+	assert.ErrorIs(t, err, ErrClientExists)
+
+	// A request in flight when the client is removed still completes
+	added := pool.GetAllClients()[1]
+	done := make(chan error, 1)
+	go func() {
+		_, err := added.Call(context.Background(), "eth_blockNumber", []interface{}{})
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return added.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	removed := make(chan error, 1)
+	go func() { removed <- pool.RemoveClient(context.Background(), "second") }()
+
+	require.Eventually(t, func() bool { return len(pool.GetAllClients()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"first"}, availableNames(pool), "removed clients get no new requests")
+
+	select {
+	case <-removed:
+		t.Fatal("client was closed with a request in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-removed)
+
+	assert.ErrorIs(t, pool.RemoveClient(context.Background(), "second"), ErrClientNotFound)
+}
+
+func TestPoolStruct_DrainTimesOut(t *testing.T) {
+	server := newHeadServer(t, 100, 1700000000)
+	release := make(chan struct{})
+	defer close(release)
+	server.Handle("eth_blockNumber", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		<-release
+		return "0x64", nil
+	})
+
+	pool, err := NewPool([]config.ClientConfig{{URL: server.URL, Name: "slow", Timeout: 5 * time.Second}}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	client := pool.GetAllClients()[0]
+	go client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+	require.Eventually(t, func() bool { return client.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	status, err := pool.Drain(ctx, "slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, OverrideCordoned, status.Override, "the client stays cordoned")
+	assert.Equal(t, int64(1), status.InFlight)
+	assert.Empty(t, pool.GetAvailableClients())
+}
+
+func TestPoolStruct_AddClientGivesUpWithTheRequest(t *testing.T) {
+	first := newHeadServer(t, 100, 1700000000)
+	hanging := newHeadServer(t, 100, 1700000000)
+	release := make(chan struct{})
+	defer close(release)
+	hanging.Handle("eth_getBlockByNumber", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		<-release
+		return nil, nil
+	})
+
+	pool, err := NewPool([]config.ClientConfig{{URL: first.URL, Name: "first", Timeout: time.Second}},
+		config.PoolConfig{Health: config.HealthConfig{Timeout: 5 * time.Second}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = pool.AddClient(ctx, config.ClientConfig{URL: hanging.URL, Name: "hanging", Timeout: 5 * time.Second})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "the health check follows the request's context")
+	assert.Equal(t, []string{"first"}, clientNames(pool.GetAllClients()))
+
+	_, err = pool.AddClient(context.Background(), config.ClientConfig{URL: first.URL, Name: "second", Timeout: time.Second})
+	require.NoError(t, err, "the pool is free for other changes")
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// Authenticator adds credentials to the requests sent to a client.
+// It is applied to every HTTP request and to the WebSocket handshake.
+type Authenticator interface {
+	Apply(header http.Header) error
+}
+
+// NewAuthenticator creates the authenticator for a client's auth configuration.
+// Custom headers are applied before the auth scheme, so the scheme wins on conflicts.
+func NewAuthenticator(cfg config.AuthConfig) (Authenticator, error) {
+	var chain authChain
+	if len(cfg.Headers) > 0 {
+		chain = append(chain, HeaderAuth{Headers: cfg.Headers})
+	}
+
+	switch cfg.Type {
+	case config.AuthNone:
+	case config.AuthBearer:
+		chain = append(chain, BearerAuth{Token: cfg.Token})
+	case config.AuthBasic:
+		chain = append(chain, BasicAuth{Username: cfg.Username, Password: cfg.Password})
+	case config.AuthJWT:
+		auth, err := NewJWTAuth(cfg.JWTSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, auth)
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", cfg.Type)
+	}
+
+	return chain, nil
+}
+
+// authChain applies several authenticators in order
+type authChain []Authenticator
+
+func (c authChain) Apply(header http.Header) error {
+	for _, auth := range c {
+		if err := auth.Apply(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HeaderAuth sets static headers such as x-api-key
+type HeaderAuth struct {
+	Headers map[string]string
+}
+
+func (a HeaderAuth) Apply(header http.Header) error {
+	for name, value := range a.Headers {
+		header.Set(name, value)
+	}
+	return nil
+}
+
+// BearerAuth sends a static bearer token
+type BearerAuth struct {
+	Token string
+}
+
+func (a BearerAuth) Apply(header http.Header) error {
+	header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// BasicAuth sends HTTP basic credentials
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Apply(header http.Header) error {
+	credentials := base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+	header.Set("Authorization", "Basic "+credentials)
+	return nil
+}
+
+// JWTAuth signs a fresh HS256 token for every request, as required by the
+// execution engine API. The token only carries the issued-at claim, which
+// nodes accept within a short window around their own clock.
+type JWTAuth struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewJWTAuth reads a hex encoded shared secret, optionally 0x prefixed, from a file
+func NewJWTAuth(secretFile string) (*JWTAuth, error) {
+	data, err := os.ReadFile(secretFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWT secret: %w", err)
+	}
+
+	encoded := strings.TrimPrefix(strings.TrimSpace(string(data)), "0x")
+	secret, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT secret in %s: %w", secretFile, err)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("empty JWT secret in %s", secretFile)
+	}
+
+	return &JWTAuth{secret: secret, now: time.Now}, nil
+}
+
+func (a *JWTAuth) Apply(header http.Header) error {
+	token, err := a.token()
+	if err != nil {
+		return err
+	}
+	header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *JWTAuth) token() (string, error) {
+	claims, err := json.Marshal(struct {
+		IssuedAt int64 `json:"iat"`
+	}{IssuedAt: a.now().Unix()})
+	if err != nil {
+		return "", fmt.Errorf("error encoding JWT claims: %w", err)
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString(claims)
+
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecretFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwt.hex")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.AuthConfig
+		expected http.Header
+	}{
+		{
+			name:     "No auth",
+			cfg:      config.AuthConfig{},
+			expected: http.Header{},
+		},
+		{
+			name:     "Bearer token",
+			cfg:      config.AuthConfig{Type: config.AuthBearer, Token: "secret"},
+			expected: http.Header{"Authorization": {"Bearer secret"}},
+		},
+		{
+			name:     "Basic auth",
+			cfg:      config.AuthConfig{Type: config.AuthBasic, Username: "user", Password: "pass"},
+			expected: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}},
+		},
+		{
+			name: "Custom headers with bearer token",
+			cfg: config.AuthConfig{
+				Type:    config.AuthBearer,
+				Token:   "secret",
+				Headers: map[string]string{"x-api-key": "key", "Authorization": "overridden"},
+			},
+			expected: http.Header{"X-Api-Key": {"key"}, "Authorization": {"Bearer secret"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := NewAuthenticator(tt.cfg)
+			require.NoError(t, err)
+
+			header := http.Header{}
+			require.NoError(t, auth.Apply(header))
+			assert.Equal(t, tt.expected, header)
+		})
+	}
+
+	_, err := NewAuthenticator(config.AuthConfig{Type: "digest"})
+	assert.Error(t, err)
+}
+
+func TestJWTAuth(t *testing.T) {
+	secret := strings.Repeat("ab", 32)
+	auth, err := NewJWTAuth(writeSecretFile(t, "0x"+secret+"\n"))
+	require.NoError(t, err)
+	auth.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	header := http.Header{}
+	require.NoError(t, auth.Apply(header))
+
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	require.True(t, ok)
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"iat":1700000000}`, string(claims))
+
+	mac := hmac.New(sha256.New, auth.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), parts[2])
+
+	_, err = NewJWTAuth(writeSecretFile(t, "not hex"))
+	assert.Error(t, err)
+	_, err = NewJWTAuth(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestClient_SendsCredentials(t *testing.T) {
+	server := rpctest.NewServer()
+	defer server.Close()
+	server.HandleResult("eth_blockNumber", "0x10")
+	server.HandleHead(16, 1700000000)
+
+	auth := config.AuthConfig{
+		Type:    config.AuthBearer,
+		Token:   "secret",
+		Headers: map[string]string{"x-api-key": "key"},
+	}
+
+	for _, url := range []string{server.URL, server.WSURL()} {
+		pool, err := NewPool([]config.ClientConfig{{URL: url, Name: "auth", Timeout: time.Second, Auth: auth}}, config.PoolConfig{})
+		require.NoError(t, err)
+		client := pool.GetAllClients()[0]
+
+		require.Eventually(t, func() bool {
+			_, err := client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond, url)
+		assert.Equal(t, "Bearer secret", server.LastHeader().Get("Authorization"), url)
+		assert.Equal(t, "key", server.LastHeader().Get("x-api-key"), url)
+
+		assert.True(t, pool.CheckClientHealth(client), url)
+		pool.Close()
+	}
+
+	_, err := NewPool([]config.ClientConfig{{URL: server.URL, Name: "bad", Auth: config.AuthConfig{Type: config.AuthJWT, JWTSecretFile: "/does/not/exist"}}}, config.PoolConfig{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultMaxBatchSize is used when a client has no batch size limit configured
+const defaultMaxBatchSize = 100
+
+// batchMethod is the method label of batch requests in upstream metrics, a batch mixes methods
+const batchMethod = "batch"
+
+// Call is a single JSON-RPC method invocation inside a batch
+type Call struct {
+	Method string
+	Params interface{}
+}
+
+// CallResult is the outcome of a single call inside a batch
+type CallResult struct {
+	Result json.RawMessage
+	Error  error
+}
+
+// BatchResponse holds the per-call results of a batch from a single client
+type BatchResponse struct {
+	ClientName string
+	Results    []CallResult
+}
+
+// errMissingResponse is reported for batch items the client did not answer
+var errMissingResponse = errors.New("no response for request in batch")
+
+// BatchCall sends calls to the client as JSON-RPC batch payloads.
+// Results are matched back to calls by request id and returned in call order;
+// errors for individual items are reported in their CallResult.
+func (c *Client) BatchCall(ctx context.Context, calls []Call) ([]CallResult, error) {
+	maxBatchSize := c.maxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultMaxBatchSize
+	}
+
+	results := make([]CallResult, 0, len(calls))
+	for start := 0; start < len(calls); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(calls))
+
+		chunkResults, err := c.batchCall(ctx, calls[start:end])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunkResults...)
+	}
+
+	return results, nil
+}
+
+// batchCall sends a single batch payload to the client through its circuit breaker and retry policy
+func (c *Client) batchCall(ctx context.Context, calls []Call) ([]CallResult, error) {
+	var results []CallResult
+	err := c.execute(ctx, batchMethod, func(ctx context.Context) error {
+		var err error
+		results, err = c.sendBatch(ctx, calls)
+		return err
+	})
+	return results, err
+}
+
+// sendBatch sends a single batch payload and matches the responses to the calls
+func (c *Client) sendBatch(ctx context.Context, calls []Call) ([]CallResult, error) {
+	payload := make([]Request, len(calls))
+	ids := make([]json.RawMessage, len(calls))
+	for i, call := range calls {
+		ids[i] = c.nextRequestID()
+		payload[i] = Request{
+			JSONRPC: "2.0",
+			Method:  call.Method,
+			Params:  call.Params,
+			ID:      ids[i],
+		}
+	}
+
+	body, err := c.transport.roundTrip(ctx, payload, true)
+	if err != nil {
+		c.recordError(err)
+		return nil, err
+	}
+
+	responses, err := decodeBatchResponse(body, ids)
+	if err != nil {
+		// Some providers answer a rejected batch with a single error object
+		c.recordError(err)
+		return nil, err
+	}
+
+	results := make([]CallResult, len(calls))
+	answered := make([]bool, len(calls))
+	for i, resp := range responses {
+		if resp == nil {
+			continue
+		}
+		answered[i] = true
+
+		if resp.Error != nil {
+			metrics.RecordClientError(c.Name, "rpc_error")
+			results[i] = CallResult{Error: resp.Error}
+			continue
+		}
+		results[i] = CallResult{Result: resp.Result}
+	}
+
+	for i := range results {
+		if !answered[i] {
+			metrics.RecordClientError(c.Name, "missing_response")
+			results[i] = CallResult{Error: errMissingResponse}
+		}
+	}
+
+	return results, nil
+}
+
+// BatchCallFromAllClients sends a batch of calls to all available clients
+func (p *PoolStruct) BatchCallFromAllClients(ctx context.Context, calls []Call) ([]BatchResponse, error) {
+	return p.BatchCallFromClients(ctx, p.GetAvailableClients(), calls)
+}
+
+// BatchCallFromClients sends a batch of calls to the given clients.
+// Clients whose whole batch failed are left out of the result.
+func (p *PoolStruct) BatchCallFromClients(ctx context.Context, clients []*Client, calls []Call) ([]BatchResponse, error) {
+	if len(clients) == 0 {
+		return nil, ErrNoClientsAvailable
+	}
+
+	clientCtx, cancel := context.WithTimeout(ctx, p.fanOutTimeout())
+	defer cancel()
+
+	g, ctx := errgroup.WithContext(clientCtx)
+	responses := make([]BatchResponse, 0, len(clients))
+	var responsesMutex sync.Mutex
+
+	for _, client := range clients {
+		g.Go(func(client *Client) func() error {
+			return func() error {
+				results, err := client.BatchCall(ctx, calls)
+
+				responsesMutex.Lock()
+				if err == nil {
+					responses = append(responses, BatchResponse{
+						ClientName: client.Name,
+						Results:    results,
+					})
+				} else {
+					log.Printf("Batch error from client %s: %v\n", client.Name, err)
+				}
+				responsesMutex.Unlock()
+				return nil
+			}
+		}(client))
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("error while sending batch: %w", err)
+	}
+
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("failed to retrieve batch results from any client")
+	}
+
+	return responses, nil
+}
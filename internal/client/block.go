@@ -0,0 +1,204 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Block tags accepted by state queries
+const (
+	TagLatest    = "latest"
+	TagEarliest  = "earliest"
+	TagPending   = "pending"
+	TagSafe      = "safe"
+	TagFinalized = "finalized"
+)
+
+var blockTags = map[string]bool{
+	TagLatest:    true,
+	TagEarliest:  true,
+	TagPending:   true,
+	TagSafe:      true,
+	TagFinalized: true,
+}
+
+type blockKind int
+
+const (
+	blockKindTag blockKind = iota
+	blockKindNumber
+	blockKindHash
+)
+
+// BlockParameter identifies the block a state query is made at:
+// a tag, a block number or, as defined by EIP-1898, a block hash
+type BlockParameter struct {
+	kind             blockKind
+	tag              string
+	number           uint64
+	hash             common.Hash
+	requireCanonical bool
+}
+
+// LatestBlock is the block parameter used when callers do not ask for a block
+var LatestBlock = BlockTag(TagLatest)
+
+// BlockTag returns the block parameter for a tag such as latest or finalized
+func BlockTag(tag string) BlockParameter {
+	return BlockParameter{kind: blockKindTag, tag: tag}
+}
+
+// BlockNumber returns the block parameter for a block number
+func BlockNumber(number uint64) BlockParameter {
+	return BlockParameter{kind: blockKindNumber, number: number}
+}
+
+// BlockHash returns the EIP-1898 block parameter for a block hash. With requireCanonical
+// clients fail the query when the block is not on their canonical chain.
+func BlockHash(hash common.Hash, requireCanonical bool) BlockParameter {
+	return BlockParameter{kind: blockKindHash, hash: hash, requireCanonical: requireCanonical}
+}
+
+// ParseBlockParameter parses a block parameter given as a tag, a hex or decimal block number,
+// a 32 byte block hash or an EIP-1898 object such as {"blockHash":"0x…","requireCanonical":true}
+func ParseBlockParameter(value string) (BlockParameter, error) {
+	value = strings.TrimSpace(value)
+
+	switch {
+	case value == "":
+		return BlockParameter{}, fmt.Errorf("empty block parameter")
+	case strings.HasPrefix(value, "{"):
+		return parseBlockObject(value)
+	case blockTags[strings.ToLower(value)]:
+		return BlockTag(strings.ToLower(value)), nil
+	case has0xPrefix(value):
+		digits := value[2:]
+		if len(digits) == 2*common.HashLength {
+			return parseBlockHash(value, false)
+		}
+		if digits == "" {
+			return BlockParameter{}, fmt.Errorf("invalid block number %q: no digits after 0x", value)
+		}
+		number, err := strconv.ParseUint(digits, 16, 64)
+		if err != nil {
+			return BlockParameter{}, fmt.Errorf("invalid block number %q: expected a hex number of at most 64 bits or a 32 byte block hash", value)
+		}
+		return BlockNumber(number), nil
+	default:
+		number, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return BlockParameter{}, fmt.Errorf("invalid block parameter %q: expected latest, earliest, pending, safe, finalized, a block number or a block hash", value)
+		}
+		return BlockNumber(number), nil
+	}
+}
+
+// parseBlockObject parses an EIP-1898 block object, which holds either a block number or a block hash
+func parseBlockObject(value string) (BlockParameter, error) {
+	var object struct {
+		BlockNumber      *string `json:"blockNumber"`
+		BlockHash        *string `json:"blockHash"`
+		RequireCanonical *bool   `json:"requireCanonical"`
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&object); err != nil {
+		return BlockParameter{}, fmt.Errorf("invalid EIP-1898 block object: %v", err)
+	}
+
+	switch {
+	case object.BlockNumber != nil && object.BlockHash != nil:
+		return BlockParameter{}, fmt.Errorf("invalid EIP-1898 block object: blockNumber and blockHash are mutually exclusive")
+	case object.BlockNumber != nil:
+		if object.RequireCanonical != nil {
+			return BlockParameter{}, fmt.Errorf("invalid EIP-1898 block object: requireCanonical is only valid with blockHash")
+		}
+		number, err := hexutil.DecodeUint64(*object.BlockNumber)
+		if err != nil {
+			return BlockParameter{}, fmt.Errorf("invalid EIP-1898 blockNumber %q: %v", *object.BlockNumber, err)
+		}
+		return BlockNumber(number), nil
+	case object.BlockHash != nil:
+		return parseBlockHash(*object.BlockHash, object.RequireCanonical != nil && *object.RequireCanonical)
+	default:
+		return BlockParameter{}, fmt.Errorf("invalid EIP-1898 block object: expected blockNumber or blockHash")
+	}
+}
+
+func parseBlockHash(value string, requireCanonical bool) (BlockParameter, error) {
+	bytes, err := hexutil.Decode(value)
+	if err != nil || len(bytes) != common.HashLength {
+		return BlockParameter{}, fmt.Errorf("invalid block hash %q: expected 32 hex encoded bytes", value)
+	}
+	return BlockHash(common.BytesToHash(bytes), requireCanonical), nil
+}
+
+func has0xPrefix(value string) bool {
+	return len(value) >= 2 && value[0] == '0' && (value[1] == 'x' || value[1] == 'X')
+}
+
+// Tag returns the block tag, ok is false when the parameter is not a tag
+func (b BlockParameter) Tag() (tag string, ok bool) {
+	return b.tag, b.kind == blockKindTag
+}
+
+// Number returns the block number, ok is false when the parameter is not a number
+func (b BlockParameter) Number() (number uint64, ok bool) {
+	return b.number, b.kind == blockKindNumber
+}
+
+// Hash returns the block hash, ok is false when the parameter is not a hash
+func (b BlockParameter) Hash() (hash common.Hash, ok bool) {
+	return b.hash, b.kind == blockKindHash
+}
+
+// RequireCanonical reports whether a block hash must be on the client's canonical chain
+func (b BlockParameter) RequireCanonical() bool {
+	return b.requireCanonical
+}
+
+// WithRequireCanonical returns a copy of a block hash parameter with requireCanonical set
+func (b BlockParameter) WithRequireCanonical(requireCanonical bool) (BlockParameter, error) {
+	if b.kind != blockKindHash {
+		return BlockParameter{}, fmt.Errorf("requireCanonical is only valid with a block hash")
+	}
+	b.requireCanonical = requireCanonical
+	return b, nil
+}
+
+// String returns the parameter in its JSON-RPC form
+func (b BlockParameter) String() string {
+	switch b.kind {
+	case blockKindNumber:
+		return hexutil.EncodeUint64(b.number)
+	case blockKindHash:
+		if b.requireCanonical {
+			return fmt.Sprintf(`{"blockHash":"%s","requireCanonical":true}`, b.hash.Hex())
+		}
+		return fmt.Sprintf(`{"blockHash":"%s"}`, b.hash.Hex())
+	default:
+		return b.tag
+	}
+}
+
+// MarshalJSON encodes the parameter as sent to clients: tags and hex numbers as
+// strings and block hashes as EIP-1898 objects
+func (b BlockParameter) MarshalJSON() ([]byte, error) {
+	switch b.kind {
+	case blockKindNumber:
+		return json.Marshal(hexutil.EncodeUint64(b.number))
+	case blockKindHash:
+		return json.Marshal(struct {
+			BlockHash        common.Hash `json:"blockHash"`
+			RequireCanonical bool        `json:"requireCanonical,omitempty"`
+		}{b.hash, b.requireCanonical})
+	default:
+		return json.Marshal(b.tag)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBlockHash = "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+
+func TestParseBlockParameter(t *testing.T) {
+	hash := common.HexToHash(testBlockHash)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected BlockParameter
+		json     string
+	}{
+		{"Latest tag", "latest", LatestBlock, `"latest"`},
+		{"Tag in upper case", "Finalized", BlockTag(TagFinalized), `"finalized"`},
+		{"Pending tag", "pending", BlockTag(TagPending), `"pending"`},
+		{"Hex number", "0x1b4", BlockNumber(436), `"0x1b4"`},
+		{"Hex number with leading zeros", "0x01b4", BlockNumber(436), `"0x1b4"`},
+		{"Decimal number", "436", BlockNumber(436), `"0x1b4"`},
+		{"Genesis", "0", BlockNumber(0), `"0x0"`},
+		{"Block hash", testBlockHash, BlockHash(hash, false), `{"blockHash":"` + testBlockHash + `"}`},
+		{
+			"EIP-1898 hash object",
+			`{"blockHash":"` + testBlockHash + `","requireCanonical":true}`,
+			BlockHash(hash, true),
+			`{"blockHash":"` + testBlockHash + `","requireCanonical":true}`,
+		},
+		{"EIP-1898 number object", `{"blockNumber":"0x1b4"}`, BlockNumber(436), `"0x1b4"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := ParseBlockParameter(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, block)
+
+			encoded, err := json.Marshal(block)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.json, string(encoded))
+		})
+	}
+}
+
+func TestParseBlockParameter_Invalid(t *testing.T) {
+	tests := []struct {
+		value string
+		err   string
+	}{
+		{"", "empty block parameter"},
+		{"newest", "expected latest, earliest, pending, safe, finalized, a block number or a block hash"},
+		{"-1", "expected latest, earliest, pending, safe, finalized, a block number or a block hash"},
+		{"0x", "no digits after 0x"},
+		{"0xzz", "expected a hex number of at most 64 bits or a 32 byte block hash"},
+		{"0x1ffffffffffffffff", "expected a hex number of at most 64 bits or a 32 byte block hash"},
+		{"0x" + testBlockHash[4:] + "zz", "expected 32 hex encoded bytes"},
+		{`{"blockHash":"0x1234"}`, "expected 32 hex encoded bytes"},
+		{`{"blockNumber":"0x1","blockHash":"` + testBlockHash + `"}`, "mutually exclusive"},
+		{`{"blockNumber":"0x1","requireCanonical":true}`, "requireCanonical is only valid with blockHash"},
+		{`{"blockNumber":"12"}`, "invalid EIP-1898 blockNumber"},
+		{`{"block":"0x1"}`, "unknown field"},
+		{`{}`, "expected blockNumber or blockHash"},
+		{`{"blockHash":`, "invalid EIP-1898 block object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := ParseBlockParameter(tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestBlockParameter_WithRequireCanonical(t *testing.T) {
+	block, err := BlockHash(common.HexToHash(testBlockHash), false).WithRequireCanonical(true)
+	require.NoError(t, err)
+	assert.True(t, block.RequireCanonical())
+
+	_, err = LatestBlock.WithRequireCanonical(true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// ErrCircuitOpen is returned when a request is rejected by an open circuit breaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker stops traffic to a client that keeps failing.
+// It opens after too many consecutive failures or when the failure rate over
+// a sliding window of recent requests crosses a threshold. After a cooldown it
+// lets a limited number of probe requests through and closes again once they succeed.
+type CircuitBreaker struct {
+	name string
+	cfg  config.BreakerConfig
+
+	mu                  sync.Mutex
+	state               BreakerState
+	consecutiveFailures int
+	outcomes            []bool // ring buffer of recent outcomes, true means failure
+	outcomePos          int
+	outcomeCount        int
+	failureCount        int
+	openedAt            time.Time
+	probesInFlight      int
+	probeSuccesses      int
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker for the named client
+func NewCircuitBreaker(name string, cfg config.BreakerConfig) *CircuitBreaker {
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = 1
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+
+	metrics.SetCircuitState(name, int(BreakerClosed))
+
+	return &CircuitBreaker{
+		name:     name,
+		cfg:      cfg,
+		state:    BreakerClosed,
+		outcomes: make([]bool, cfg.WindowSize),
+		now:      time.Now,
+	}
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Ready reports whether the breaker would let a request through right now,
+// without reserving a probe slot
+func (b *CircuitBreaker) Ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return b.now().Sub(b.openedAt) >= b.cfg.Cooldown
+	case BreakerHalfOpen:
+		return b.probesInFlight < b.cfg.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+// Allow reports whether a request may be sent. In the half-open state it reserves
+// one of the probe slots, which is released by the next Record call.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if b.now().Sub(b.openedAt) < b.cfg.Cooldown {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probesInFlight >= b.cfg.HalfOpenProbes {
+			return false
+		}
+		b.probesInFlight++
+	}
+
+	return true
+}
+
+// RecordSuccess records a successful request
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		b.consecutiveFailures = 0
+		b.recordOutcome(false)
+	case BreakerHalfOpen:
+		b.releaseProbe()
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.cfg.HalfOpenProbes {
+			b.transition(BreakerClosed)
+		}
+	}
+}
+
+// RecordFailure records a failed request
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		b.consecutiveFailures++
+		b.recordOutcome(true)
+		if b.shouldTrip() {
+			b.transition(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		b.releaseProbe()
+		b.transition(BreakerOpen)
+	}
+}
+
+// RecordIgnored releases a probe slot for a request whose outcome says nothing
+// about the client, such as one cancelled by the caller
+func (b *CircuitBreaker) RecordIgnored() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.releaseProbe()
+	}
+}
+
+func (b *CircuitBreaker) shouldTrip() bool {
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.cfg.ConsecutiveFailures {
+		return true
+	}
+
+	if b.cfg.FailureRateThreshold <= 0 || b.outcomeCount < b.cfg.MinRequests {
+		return false
+	}
+	return float64(b.failureCount)/float64(b.outcomeCount) >= b.cfg.FailureRateThreshold
+}
+
+func (b *CircuitBreaker) recordOutcome(failed bool) {
+	if b.outcomeCount == len(b.outcomes) {
+		if b.outcomes[b.outcomePos] {
+			b.failureCount--
+		}
+	} else {
+		b.outcomeCount++
+	}
+
+	b.outcomes[b.outcomePos] = failed
+	if failed {
+		b.failureCount++
+	}
+	b.outcomePos = (b.outcomePos + 1) % len(b.outcomes)
+}
+
+func (b *CircuitBreaker) releaseProbe() {
+	if b.probesInFlight > 0 {
+		b.probesInFlight--
+	}
+}
+
+// transition moves the breaker to a new state and resets the counters of the old one
+func (b *CircuitBreaker) transition(state BreakerState) {
+	b.state = state
+	b.probesInFlight = 0
+	b.probeSuccesses = 0
+
+	switch state {
+	case BreakerOpen:
+		b.openedAt = b.now()
+	case BreakerClosed:
+		b.consecutiveFailures = 0
+		b.outcomePos = 0
+		b.outcomeCount = 0
+		b.failureCount = 0
+	}
+
+	metrics.SetCircuitState(b.name, int(state))
+	metrics.RecordCircuitTransition(b.name, state.String())
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBreaker(cfg config.BreakerConfig) (*CircuitBreaker, *time.Time) {
+	now := time.Unix(0, 0)
+	breaker := NewCircuitBreaker("test", cfg)
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreaker_TripsOnConsecutiveFailures(t *testing.T) {
+	breaker, now := newTestBreaker(config.BreakerConfig{
+		ConsecutiveFailures: 3,
+		WindowSize:          10,
+		Cooldown:            time.Second,
+		HalfOpenProbes:      1,
+	})
+
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	assert.Equal(t, BreakerClosed, breaker.State(), "a success resets the consecutive failure count")
+
+	breaker.RecordFailure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.Allow())
+	assert.False(t, breaker.Ready())
+
+	*now = now.Add(time.Second)
+	assert.True(t, breaker.Ready())
+	assert.True(t, breaker.Allow(), "one probe is let through after the cooldown")
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.False(t, breaker.Allow(), "only one probe may be in flight")
+
+	breaker.RecordSuccess()
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.True(t, breaker.Allow())
+}
+
+func TestCircuitBreaker_TripsOnFailureRate(t *testing.T) {
+	breaker, _ := newTestBreaker(config.BreakerConfig{
+		FailureRateThreshold: 0.5,
+		WindowSize:           4,
+		MinRequests:          4,
+		Cooldown:             time.Second,
+	})
+
+	breaker.RecordFailure()
+	breaker.RecordSuccess()
+	breaker.RecordSuccess()
+	assert.Equal(t, BreakerClosed, breaker.State(), "the rate is not evaluated below the minimum request count")
+
+	breaker.RecordFailure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+func TestCircuitBreaker_FailedProbeReopens(t *testing.T) {
+	breaker, now := newTestBreaker(config.BreakerConfig{
+		ConsecutiveFailures: 1,
+		WindowSize:          1,
+		Cooldown:            time.Second,
+	})
+
+	breaker.RecordFailure()
+	*now = now.Add(time.Second)
+	assert.True(t, breaker.Allow())
+
+	breaker.RecordFailure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.Allow(), "the cooldown restarts after a failed probe")
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// chainVerifyTimeout bounds the chain verification of all clients at startup
+	chainVerifyTimeout = 10 * time.Second
+	// chainVerifyRetryDelay spaces verification attempts while a client is still connecting
+	chainVerifyRetryDelay = 200 * time.Millisecond
+)
+
+// chainIdentityCalls fetch the chain id and network id of a client
+var chainIdentityCalls = []Call{
+	{Method: "eth_chainId", Params: []interface{}{}},
+	{Method: "net_version", Params: []interface{}{}},
+}
+
+// ChainMismatchError is reported for a client connected to a different chain than expected
+type ChainMismatchError struct {
+	ChainID           uint64
+	NetworkID         uint64
+	ExpectedChainID   uint64
+	ExpectedNetworkID uint64
+}
+
+func (e *ChainMismatchError) Error() string {
+	return fmt.Sprintf("wrong chain: chain id %d, network id %d, expected chain id %d, network id %d",
+		e.ChainID, e.NetworkID, e.ExpectedChainID, e.ExpectedNetworkID)
+}
+
+// verifyChains checks the chain of every client concurrently, quarantining clients on the wrong chain.
+// It fails when no client could be verified to be on the expected chain.
+func (p *PoolStruct) verifyChains() error {
+	ctx, cancel := context.WithTimeout(context.Background(), chainVerifyTimeout)
+	defer cancel()
+
+	clients := p.GetAllClients()
+	errs := make([]error, len(clients))
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = p.verifyChain(ctx, client)
+		}()
+	}
+	wg.Wait()
+
+	var failures []string
+	for i, client := range clients {
+		if errs[i] == nil {
+			log.Printf("Client %s is on chain %d\n", client.Name, p.config().ExpectedChainID)
+			continue
+		}
+		log.Printf("Chain verification failed for %s: %v\n", client.Name, errs[i])
+		failures = append(failures, fmt.Sprintf("%s: %v", client.Name, errs[i]))
+	}
+
+	if len(failures) == len(clients) {
+		return fmt.Errorf("no client is on the expected chain %d: %s", p.config().ExpectedChainID, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// detectMixedChains fetches the chain id of every client when no expected chain is configured and fails
+// when they report different chains, e.g. a testnet endpoint configured next to mainnet ones.
+// Clients that cannot be reached or do not report a chain id are skipped.
+func (p *PoolStruct) detectMixedChains() error {
+	ctx, cancel := context.WithTimeout(context.Background(), chainVerifyTimeout)
+	defer cancel()
+
+	clients := p.GetAllClients()
+	chainIDs := make([]uint64, len(clients))
+	errs := make([]error, len(clients))
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := client.sendBatch(ctx, chainIdentityCalls[:1])
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			chainIDs[i], errs[i] = parseChainID(results[0])
+		}()
+	}
+	wg.Wait()
+
+	byChain := make(map[uint64][]string)
+	for i, client := range clients {
+		if errs[i] != nil {
+			log.Printf("Could not read the chain id of %s: %v\n", client.Name, errs[i])
+			continue
+		}
+		byChain[chainIDs[i]] = append(byChain[chainIDs[i]], client.Name)
+	}
+	if len(byChain) <= 1 {
+		return nil
+	}
+
+	chains := make([]string, 0, len(byChain))
+	for _, chainID := range slices.Sorted(maps.Keys(byChain)) {
+		chains = append(chains, fmt.Sprintf("chain %d: %s", chainID, strings.Join(byChain[chainID], ", ")))
+	}
+	return fmt.Errorf("clients are on different chains (%s), set pool.expected_chain_id to the chain to serve",
+		strings.Join(chains, "; "))
+}
+
+// verifyChain fetches the chain identity of a client and quarantines it on a mismatch.
+// Transport errors are retried until ctx expires, so WebSocket clients get time to connect.
+func (p *PoolStruct) verifyChain(ctx context.Context, client *Client) error {
+	for {
+		results, err := client.sendBatch(ctx, chainIdentityCalls)
+		if err == nil {
+			err = p.checkChain(results)
+			p.applyChainCheck(client, err)
+			return err
+		}
+
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(chainVerifyRetryDelay):
+		}
+	}
+}
+
+// checkChain compares the results of chainIdentityCalls with the expected chain,
+// it returns a *ChainMismatchError when the client is on another chain
+func (p *PoolStruct) checkChain(results []CallResult) error {
+	for _, result := range results {
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+
+	chainID, err := parseChainID(results[0])
+	if err != nil {
+		return err
+	}
+
+	// net_version is a decimal string
+	var networkIDString string
+	if err := json.Unmarshal(results[1].Result, &networkIDString); err != nil {
+		return &ParseError{Err: fmt.Errorf("network id: %w", err)}
+	}
+	networkID, err := strconv.ParseUint(networkIDString, 10, 64)
+	if err != nil {
+		return &ParseError{Err: fmt.Errorf("network id: %w", err)}
+	}
+
+	cfg := p.config()
+	if chainID != cfg.ExpectedChainID || networkID != cfg.ExpectedNetworkID {
+		return &ChainMismatchError{
+			ChainID:           chainID,
+			NetworkID:         networkID,
+			ExpectedChainID:   cfg.ExpectedChainID,
+			ExpectedNetworkID: cfg.ExpectedNetworkID,
+		}
+	}
+	return nil
+}
+
+// parseChainID decodes the result of eth_chainId
+func parseChainID(result CallResult) (uint64, error) {
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	var chainIDHex string
+	if err := json.Unmarshal(result.Result, &chainIDHex); err != nil {
+		return 0, &ParseError{Err: fmt.Errorf("chain id: %w", err)}
+	}
+	chainID, err := hexutil.DecodeUint64(chainIDHex)
+	if err != nil {
+		return 0, &ParseError{Err: fmt.Errorf("chain id: %w", err)}
+	}
+	return chainID, nil
+}
+
+// applyChainCheck quarantines a client on the wrong chain and releases it once it reports the expected one.
+// Other errors leave the quarantine unchanged.
+func (p *PoolStruct) applyChainCheck(client *Client, err error) {
+	var mismatch *ChainMismatchError
+	switch {
+	case err == nil:
+		p.setQuarantined(client, false)
+	case errors.As(err, &mismatch):
+		p.setQuarantined(client, true)
+	}
+}
+
+func (p *PoolStruct) setQuarantined(client *Client, quarantined bool) {
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	if client.quarantined != quarantined {
+		if quarantined {
+			log.Printf("Quarantining client %s, it is not on chain %d\n", client.Name, p.config().ExpectedChainID)
+		} else {
+			log.Printf("Client %s is back on chain %d, lifting quarantine\n", client.Name, p.config().ExpectedChainID)
+		}
+	}
+	client.quarantined = quarantined
+	metrics.SetClientQuarantined(client.Name, quarantined)
+}
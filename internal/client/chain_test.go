@@ -0,0 +1,85 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChainServer(t *testing.T, chainID, networkID string) *rpctest.Server {
+	t.Helper()
+
+	server := newHeadServer(t, 1000, 1700000000)
+	server.HandleResult("eth_chainId", chainID)
+	server.HandleResult("net_version", networkID)
+	return server
+}
+
+var mainnetPool = config.PoolConfig{
+	ExpectedChainID:   1,
+	ExpectedNetworkID: 1,
+	Health:            config.HealthConfig{DegradedBlockLag: 2, MaxBlockLag: 10, DegradedTimeLag: time.Minute, MaxTimeLag: time.Minute},
+}
+
+func TestNewPool_QuarantinesWrongChain(t *testing.T) {
+	mainnet := newChainServer(t, "0x1", "1")
+	sepolia := newChainServer(t, "0xaa36a7", "11155111")
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: mainnet.WSURL(), Name: "mainnet", Timeout: time.Second},
+		{URL: sepolia.URL, Name: "sepolia", Timeout: time.Second},
+	}, mainnetPool)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	assert.Equal(t, []string{"mainnet"}, clientNames(pool.GetAvailableClients()))
+
+	sepoliaClient := pool.GetAllClients()[1]
+	assert.False(t, pool.CheckClientHealth(sepoliaClient), "health checks keep the client quarantined")
+	assert.Equal(t, []string{"mainnet"}, clientNames(pool.GetAvailableClients()))
+
+	sepolia.HandleResult("eth_chainId", "0x1")
+	sepolia.HandleResult("net_version", "1")
+	assert.True(t, pool.CheckClientHealth(sepoliaClient), "the quarantine is lifted once the client is on the expected chain")
+	assert.ElementsMatch(t, []string{"mainnet", "sepolia"}, clientNames(pool.GetAvailableClients()))
+}
+
+func TestNewPool_FailsWhenNoClientMatches(t *testing.T) {
+	sepolia := newChainServer(t, "0xaa36a7", "11155111")
+	mismatchedNetwork := newChainServer(t, "0x1", "5")
+
+	_, err := NewPool([]config.ClientConfig{
+		{URL: sepolia.URL, Name: "sepolia", Timeout: time.Second},
+		{URL: mismatchedNetwork.URL, Name: "mismatched", Timeout: time.Second},
+	}, mainnetPool)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no client is on the expected chain 1")
+	assert.Contains(t, err.Error(), "sepolia: wrong chain: chain id 11155111")
+	assert.Contains(t, err.Error(), "mismatched: wrong chain: chain id 1, network id 5")
+}
+
+func TestNewPool_FailsOnMixedChainsWithoutExpectedChain(t *testing.T) {
+	mainnet := newChainServer(t, "0x1", "1")
+	sepolia := newChainServer(t, "0xaa36a7", "11155111")
+	unknown := newHeadServer(t, 1000, 1700000000)
+
+	_, err := NewPool([]config.ClientConfig{
+		{URL: mainnet.URL, Name: "mainnet", Timeout: time.Second},
+		{URL: sepolia.URL, Name: "sepolia", Timeout: time.Second},
+	}, config.PoolConfig{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "chain 1: mainnet; chain 11155111: sepolia")
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: mainnet.URL, Name: "mainnet", Timeout: time.Second},
+		{URL: unknown.URL, Name: "unknown", Timeout: time.Second},
+	}, config.PoolConfig{})
+	require.NoError(t, err, "clients that do not report a chain id are skipped")
+	pool.Close()
+}
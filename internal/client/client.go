@@ -3,13 +3,13 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math/big"
-	"net/http"
-	"strings"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bersh/alluvial_test_1/internal/config"
@@ -18,14 +18,28 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// defaultFanOutTimeout bounds requests sent to several clients when the pool configuration does not set it
+const defaultFanOutTimeout = 30 * time.Second
+
+// ErrNoClientsAvailable is returned when no client of the pool can take a request
+var ErrNoClientsAvailable = errors.New("no Ethereum clients available")
+
 // PoolStruct defines the interface for the client pool
 //
 //go:generate mockery --name Pool
 type Pool interface {
-	QueryBalanceFromAllClients(ctx context.Context, address, blockParam string) ([]BalanceResponse, error)
+	QueryBalanceFromAllClients(ctx context.Context, address string, block BlockParameter) ([]BalanceResponse, error)
+	QueryBalanceFromClients(ctx context.Context, clients []*Client, address string, block BlockParameter) ([]BalanceResponse, error)
+	QueryBalanceHedged(ctx context.Context, address string, block BlockParameter, hedge config.HedgeConfig) (*BalanceResponse, error)
+	CallFromAllClients(ctx context.Context, method string, params interface{}) ([]CallResponse, error)
+	CallFromClients(ctx context.Context, clients []*Client, method string, params interface{}) ([]CallResponse, error)
+	BatchCallFromAllClients(ctx context.Context, calls []Call) ([]BatchResponse, error)
+	BatchCallFromClients(ctx context.Context, clients []*Client, calls []Call) ([]BatchResponse, error)
 	GetAvailableClients() []*Client
+	GetClientsForBlock(block BlockParameter) ([]*Client, error)
 	HasAvailableClients() bool
 	SetClientAvailability(clientName string, isAvailable bool)
+	RecordConsensus(agreeing, disagreeing []string)
 	GetAllClients() []*Client
 	CheckClientHealth(client *Client) bool
 	CheckAllHealth()
@@ -33,42 +47,158 @@ type Pool interface {
 
 // Client represents an Ethereum JSON-RPC client
 type Client struct {
-	URL         string
-	Name        string
-	HTTPClient  *http.Client
+	URL    string
+	Name   string
+	Weight int
+	// Tier is the client's priority tier, see config.ClientConfig.Tier
+	Tier        int
 	IsAvailable bool
+	// availabilityReason is what last changed IsAvailable, guarded by the pool lock
+	availabilityReason string
+	// IsDegraded is set for clients lagging behind the pool head, they only serve traffic when no healthy client is left
+	IsDegraded bool
+
+	// cfg is the configuration the client was created from, reloads replace clients whose configuration changed
+	cfg config.ClientConfig
+	// runtime is set for clients added through the admin API, reloads keep them unless the file defines the name
+	runtime bool
+
+	transport    transport
+	breaker      *CircuitBreaker
+	retry        *RetryPolicy
+	limiter      *rateLimiter
+	latency      *latencyTracker
+	maxBatchSize int
+	requestID    atomic.Uint64
+
+	headMu sync.RWMutex
+	head   BlockHead
+
+	// quarantined is set for clients on the wrong chain, guarded by the pool lock
+	quarantined bool
+	// override is the operator's routing decision, guarded by the pool lock
+	override Override
+	// stateHistory is how far back the client serves state, guarded by the pool lock
+	stateHistory StateHistory
+	// reputation tracks agreement with consensus and ejects outliers, guarded by the pool lock
+	reputation reputation
+	// health holds the client's recent health check results, guarded by the pool lock
+	health healthHistory
+	// passive tracks live request outcomes, observe reports each one to the pool the client belongs to
+	passive passiveHealth
+	observe func(failed bool)
+	// inFlight counts requests currently being executed, so the client can be drained
+	inFlight atomic.Int64
 }
 
-// PoolStruct manages multiple Ethereum clients
+// PoolStruct manages multiple Ethereum clients.
+// Clients can be added and removed while requests are in flight: the clients slice
+// is replaced on every change instead of being modified in place.
 type PoolStruct struct {
+	cfg          atomic.Pointer[config.PoolConfig]
 	clients      []*Client
 	clientsMutex sync.RWMutex
+	// mutateMutex serializes changes to the set of clients, which may span health checks and drains
+	mutateMutex sync.Mutex
+	// head is the reference head of the last round of health checks, see referenceHead
+	head BlockHead
 }
 
-// NewClient creates a new Ethereum client
-func NewClient(cfg config.ClientConfig) *Client {
+// NewClient creates a new Ethereum client.
+// The transport is chosen from the URL scheme: ws:// and wss:// use a persistent
+// WebSocket connection, ipc:// and unix:// a persistent Unix socket connection to a
+// local node, anything else is sent over HTTP. Every request, including
+// health checks, carries the credentials of the client's authenticator.
+func NewClient(cfg config.ClientConfig) (*Client, error) {
+	auth, err := NewAuthenticator(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("client %s: %w", cfg.Name, err)
+	}
+
 	return &Client{
+		cfg:         cfg,
 		URL:         cfg.URL,
 		Name:        cfg.Name,
-		HTTPClient:  &http.Client{Timeout: cfg.Timeout},
+		Weight:      max(cfg.Weight, 1),
+		Tier:        cfg.Tier,
 		IsAvailable: true,
-	}
+
+		transport:    newTransport(cfg, auth),
+		breaker:      NewCircuitBreaker(cfg.Name, cfg.Breaker),
+		retry:        NewRetryPolicy(cfg.Retry),
+		limiter:      newRateLimiter(cfg.Name, cfg.RequestsPerSecond, cfg.Burst),
+		latency:      newLatencyTracker(),
+		maxBatchSize: cfg.MaxBatchSize,
+		stateHistory: declaredStateHistory(cfg),
+	}, nil
 }
 
-// NewPool creates a pool of Ethereum clients
-func NewPool(clientConfigs []config.ClientConfig) (*PoolStruct, error) {
+// Close releases the client's transport resources
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// NewPool creates a pool of Ethereum clients.
+// When an expected chain id is configured every client is verified to be on it:
+// clients on another chain are quarantined, and NewPool fails when no client is on the expected chain.
+// Without one, NewPool fails when the clients report different chain ids.
+func NewPool(clientConfigs []config.ClientConfig, cfg config.PoolConfig) (*PoolStruct, error) {
 	if len(clientConfigs) == 0 {
 		return nil, fmt.Errorf("no client configurations provided")
 	}
 
+	pool := &PoolStruct{}
+	pool.cfg.Store(&cfg)
+
 	clients := make([]*Client, 0, len(clientConfigs))
 	for _, cfg := range clientConfigs {
-		clients = append(clients, NewClient(cfg))
+		client, err := pool.newClient(cfg)
+		if err != nil {
+			for _, created := range clients {
+				created.Close()
+			}
+			return nil, err
+		}
+		clients = append(clients, client)
 	}
 
-	return &PoolStruct{
-		clients: clients,
-	}, nil
+	pool.clients = clients
+
+	verify := pool.detectMixedChains
+	if cfg.ExpectedChainID != 0 {
+		verify = pool.verifyChains
+	}
+	if err := verify(); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// config returns the current pool configuration, the zero configuration when none was set
+func (p *PoolStruct) config() config.PoolConfig {
+	if cfg := p.cfg.Load(); cfg != nil {
+		return *cfg
+	}
+	return config.PoolConfig{}
+}
+
+// fanOutTimeout bounds requests sent to several clients at once
+func (p *PoolStruct) fanOutTimeout() time.Duration {
+	if timeout := p.config().FanOutTimeout; timeout > 0 {
+		return timeout
+	}
+	return defaultFanOutTimeout
+}
+
+// Close closes all clients in the pool
+func (p *PoolStruct) Close() {
+	for _, client := range p.GetAllClients() {
+		if err := client.Close(); err != nil {
+			log.Printf("Error closing client %s: %v\n", client.Name, err)
+		}
+	}
 }
 
 // HasAvailableClients checks if there's at least one available client
@@ -77,101 +207,231 @@ func (p *PoolStruct) HasAvailableClients() bool {
 	defer p.clientsMutex.RUnlock()
 
 	for _, client := range p.clients {
-		if client.IsAvailable {
+		if client.isServing() {
 			return true
 		}
 	}
 	return false
 }
 
-// GetAvailableClients returns all available clients
+// isServing reports whether the client passed its last health check, is on the
+// expected chain, is not ejected for disagreeing with consensus and its circuit breaker
+// accepts traffic, callers must hold the pool lock.
+// A manual override replaces the health check result and the ejection.
+func (c *Client) isServing() bool {
+	switch c.override {
+	case OverrideCordoned:
+		return false
+	case OverrideEnabled:
+		return !c.quarantined && c.breaker.Ready()
+	default:
+		return c.IsAvailable && !c.quarantined && !c.reputation.ejected && c.breaker.Ready()
+	}
+}
+
+// isDegraded reports whether the client should only serve as a fallback, callers must hold the pool lock
+func (c *Client) isDegraded() bool {
+	return c.IsDegraded && c.override != OverrideEnabled
+}
+
+// GetAvailableClients returns all available clients.
+// Throttled clients are skipped until their rate limit clears, without being marked unavailable.
+// Degraded clients are only returned when no healthy client is available.
+// Clients are taken from the highest priority tier first, lower priority tiers are only
+// added while the clients gathered so far cannot reach the pool's quorum.
 func (p *PoolStruct) GetAvailableClients() []*Client {
+	return p.availableClients(func(*Client) bool { return true })
+}
+
+// availableClients returns the available clients accepted by filter, picked like GetAvailableClients
+func (p *PoolStruct) availableClients(filter func(client *Client) bool) []*Client {
 	p.clientsMutex.RLock()
 	defer p.clientsMutex.RUnlock()
 
 	available := make([]*Client, 0)
+	degraded := make([]*Client, 0)
 	for _, client := range p.clients {
-		if client.IsAvailable {
+		if !client.isServing() || !client.limiter.Ready() || !filter(client) {
+			continue
+		}
+		if client.isDegraded() {
+			degraded = append(degraded, client)
+		} else {
 			available = append(available, client)
 		}
 	}
-	return available
+
+	if len(available) == 0 {
+		available = degraded
+	}
+	return selectTiers(available, p.config().Quorum)
 }
 
-// SetClientAvailability sets the availability status of a client
+// SetClientAvailability sets the availability status of a client until the next health check
+// or passive health check changes it
 func (p *PoolStruct) SetClientAvailability(clientName string, isAvailable bool) {
 	p.clientsMutex.Lock()
 	defer p.clientsMutex.Unlock()
 
-	for _, client := range p.clients {
-		if client.Name == clientName {
-			client.IsAvailable = isAvailable
-			metrics.SetClientAvailability(client.Name, isAvailable)
-			break
+	if client := p.findClient(clientName); client != nil {
+		client.setAvailability(isAvailable, reasonManual)
+	}
+}
+
+// Call sends a JSON-RPC request to the client and returns the raw result.
+// Transient failures are retried according to the client's retry policy,
+// requests are rejected with ErrRateLimited while the client is throttled
+// and with ErrCircuitOpen while the circuit breaker is open.
+func (c *Client) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := c.execute(ctx, method, func(ctx context.Context) error {
+		var err error
+		result, err = c.call(ctx, method, params)
+		return err
+	})
+	return result, err
+}
+
+// execute runs a request through the retry policy, checking the rate limiter and
+// circuit breaker before every attempt and feeding them the outcome.
+// Every attempt that reaches the client is timed under the given method name, and every request
+// is counted once under the client's tier.
+func (c *Client) execute(ctx context.Context, method string, attempt func(ctx context.Context) error) error {
+	metrics.RecordTierRequest(strconv.Itoa(c.Tier))
+	metrics.SetClientInFlight(c.Name, c.inFlight.Add(1))
+	defer func() { metrics.SetClientInFlight(c.Name, c.inFlight.Add(-1)) }()
+
+	return c.retry.Do(ctx, c.Name, func(ctx context.Context) error {
+		if !c.limiter.Allow() {
+			return fmt.Errorf("client %s: %w", c.Name, ErrRateLimited)
+		}
+		if !c.breaker.Allow() {
+			return fmt.Errorf("client %s: %w", c.Name, ErrCircuitOpen)
 		}
+
+		start := time.Now()
+		err := attempt(ctx)
+		duration := time.Since(start)
+		c.recordOutcome(err, duration)
+		metrics.ObserveUpstreamRequest(c.Name, method, upstreamOutcome(err), duration.Seconds())
+		return err
+	})
+}
+
+// BreakerState returns the state of the client's circuit breaker
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
+// Latency returns the moving average latency of the client, 0 when unknown
+func (c *Client) Latency() time.Duration {
+	return c.latency.EWMA()
+}
+
+// LatencyPercentile returns the q-th percentile of recent request latencies, 0 when unknown
+func (c *Client) LatencyPercentile(q float64) time.Duration {
+	return c.latency.Percentile(q)
+}
+
+// recordOutcome feeds the result of a request into the circuit breaker, passive health checks
+// and latency statistics. RPC errors mean the client answered, so they do not count as failures.
+// Rate-limit responses throttle the client instead of counting against its health.
+func (c *Client) recordOutcome(err error, duration time.Duration) {
+	if retryAfter, limited := rateLimitDelay(err); limited {
+		c.limiter.Throttle(retryAfter)
+		c.breaker.RecordIgnored()
+		return
+	}
+
+	var rpcErr *RPCError
+	switch {
+	case err == nil, errors.As(err, &rpcErr):
+		c.breaker.RecordSuccess()
+		c.latency.Observe(duration)
+		c.observeOutcome(false)
+	case errors.Is(err, context.Canceled):
+		c.breaker.RecordIgnored()
+	default:
+		c.breaker.RecordFailure()
+		c.observeOutcome(true)
 	}
 }
 
-// QueryBalance queries the balance from a specific client
-func (c *Client) QueryBalance(ctx context.Context, address, blockParam string) (*big.Int, error) {
-	payload := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"method":  "eth_getBalance",
-		"params":  []interface{}{address, blockParam},
-		"id":      1,
+// upstreamOutcome is the outcome label of an upstream request: success, canceled or the error type
+func upstreamOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return ErrorType(err)
 	}
+}
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling request: %w", err)
+// observeOutcome reports a request outcome to the passive health checks of the client's pool, if any
+func (c *Client) observeOutcome(failed bool) {
+	if c.observe != nil {
+		c.observe(failed)
 	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.URL, strings.NewReader(string(payloadBytes)))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+// call sends a JSON-RPC request without consulting the circuit breaker
+func (c *Client) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	payload := Request{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      c.nextRequestID(),
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	body, err := c.transport.roundTrip(ctx, []Request{payload}, false)
+	if err != nil {
+		c.recordError(err)
+		return nil, err
+	}
 
-	resp, err := c.HTTPClient.Do(req)
+	result, err := decodeResponse(body, payload.ID)
 	if err != nil {
-		metrics.RecordClientError(c.Name, "request_failed")
-		return nil, fmt.Errorf("request failed: %w", err)
+		c.recordError(err)
+		return nil, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Printf("Error closing body: %v\n", err)
-		}
-	}(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		metrics.RecordClientError(c.Name, "non_200_status")
-		return nil, fmt.Errorf("received non-200 status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	if result.Error != nil {
+		metrics.RecordClientError(c.Name, "rpc_error")
+		return nil, result.Error
 	}
 
-	var result struct {
-		JSONRPC string `json:"jsonrpc"`
-		ID      int    `json:"id"`
-		Result  string `json:"result"`
-		Error   struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
+	return result.Result, nil
+}
+
+// recordError counts a failed request, requests cancelled by the caller are not failures of the client
+func (c *Client) recordError(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
 	}
+	metrics.RecordClientError(c.Name, ErrorType(err))
+}
+
+// nextRequestID returns a unique JSON-RPC request id for this client
+func (c *Client) nextRequestID() json.RawMessage {
+	return json.RawMessage(strconv.FormatUint(c.requestID.Add(1), 10))
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		metrics.RecordClientError(c.Name, "parse_error")
-		return nil, fmt.Errorf("error parsing response: %w", err)
+// QueryBalance queries the balance from a specific client
+func (c *Client) QueryBalance(ctx context.Context, address string, block BlockParameter) (*big.Int, error) {
+	result, err := c.Call(ctx, "eth_getBalance", []interface{}{address, block})
+	if err != nil {
+		return nil, err
 	}
 
-	if result.Error.Message != "" {
-		metrics.RecordClientError(c.Name, "rpc_error")
-		return nil, fmt.Errorf("RPC error: %s (code: %d)", result.Error.Message, result.Error.Code)
+	var hexBalance string
+	if err := json.Unmarshal(result, &hexBalance); err != nil {
+		metrics.RecordClientError(c.Name, "decode_error")
+		return nil, fmt.Errorf("error parsing balance: %w", err)
 	}
 
-	balance, err := hexutil.DecodeBig(result.Result)
+	balance, err := hexutil.DecodeBig(hexBalance)
 	if err != nil {
 		metrics.RecordClientError(c.Name, "decode_error")
 		return nil, fmt.Errorf("error parsing balance: %w", err)
@@ -199,23 +459,30 @@ type BalanceResponse struct {
 }
 
 // QueryBalanceFromAllClients queries all available clients for balance
-func (p *PoolStruct) QueryBalanceFromAllClients(ctx context.Context, address, blockParam string) ([]BalanceResponse, error) {
-	clients := p.GetAvailableClients()
+func (p *PoolStruct) QueryBalanceFromAllClients(ctx context.Context, address string, block BlockParameter) ([]BalanceResponse, error) {
+	return p.QueryBalanceFromClients(ctx, p.GetAvailableClients(), address, block)
+}
+
+// QueryBalanceFromClients queries the given clients for balance.
+// The time spent waiting for the other clients after the fastest answer is recorded as fan-out overhead.
+func (p *PoolStruct) QueryBalanceFromClients(ctx context.Context, clients []*Client, address string, block BlockParameter) ([]BalanceResponse, error) {
 	if len(clients) == 0 {
-		return nil, fmt.Errorf("no Ethereum clients available")
+		return nil, ErrNoClientsAvailable
 	}
 
-	clientCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	clientCtx, cancel := context.WithTimeout(ctx, p.fanOutTimeout())
 	defer cancel()
 
 	g, ctx := errgroup.WithContext(clientCtx)
 	responses := make([]BalanceResponse, 0, len(clients))
 	var responsesMutex sync.Mutex
+	start := time.Now()
+	var fastest time.Duration
 
 	for _, client := range clients {
 		g.Go(func(client *Client) func() error {
 			return func() error {
-				balance, err := client.QueryBalance(ctx, address, blockParam)
+				balance, err := client.QueryBalance(ctx, address, block)
 				response := BalanceResponse{
 					ClientName: client.Name,
 					Balance:    balance,
@@ -224,6 +491,9 @@ func (p *PoolStruct) QueryBalanceFromAllClients(ctx context.Context, address, bl
 
 				responsesMutex.Lock()
 				if err == nil {
+					if len(responses) == 0 {
+						fastest = time.Since(start)
+					}
 					responses = append(responses, response)
 				} else {
 					log.Printf("Error from client %s: %v\n", client.Name, err)
@@ -237,6 +507,7 @@ func (p *PoolStruct) QueryBalanceFromAllClients(ctx context.Context, address, bl
 	if err := g.Wait(); err != nil {
 		return nil, fmt.Errorf("error while querying balances: %w", err)
 	}
+	observeFanOutOverhead("eth_getBalance", len(clients), len(responses), time.Since(start)-fastest)
 
 	if len(responses) == 0 {
 		return nil, fmt.Errorf("failed to retrieve balance from any client")
@@ -244,3 +515,82 @@ func (p *PoolStruct) QueryBalanceFromAllClients(ctx context.Context, address, bl
 
 	return responses, nil
 }
+
+// CallResponse represents a raw JSON-RPC result from a client
+type CallResponse struct {
+	ClientName string
+	Result     json.RawMessage
+	Error      error
+}
+
+// CallFromAllClients sends a JSON-RPC request to all available clients
+func (p *PoolStruct) CallFromAllClients(ctx context.Context, method string, params interface{}) ([]CallResponse, error) {
+	return p.CallFromClients(ctx, p.GetAvailableClients(), method, params)
+}
+
+// CallFromClients sends a JSON-RPC request to the given clients.
+// Successful responses are returned in the order they arrived.
+// The time spent waiting for the other clients after the fastest answer is recorded as fan-out overhead.
+func (p *PoolStruct) CallFromClients(ctx context.Context, clients []*Client, method string, params interface{}) ([]CallResponse, error) {
+	if len(clients) == 0 {
+		return nil, ErrNoClientsAvailable
+	}
+
+	clientCtx, cancel := context.WithTimeout(ctx, p.fanOutTimeout())
+	defer cancel()
+
+	g, ctx := errgroup.WithContext(clientCtx)
+	responses := make([]CallResponse, 0, len(clients))
+	var responsesMutex sync.Mutex
+	var rpcErr *RPCError
+	start := time.Now()
+	var fastest time.Duration
+
+	for _, client := range clients {
+		g.Go(func(client *Client) func() error {
+			return func() error {
+				result, err := client.Call(ctx, method, params)
+
+				responsesMutex.Lock()
+				if err == nil {
+					if len(responses) == 0 {
+						fastest = time.Since(start)
+					}
+					responses = append(responses, CallResponse{
+						ClientName: client.Name,
+						Result:     result,
+					})
+				} else {
+					log.Printf("Error from client %s: %v\n", client.Name, err)
+					errors.As(err, &rpcErr)
+				}
+				responsesMutex.Unlock()
+				return nil
+			}
+		}(client))
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("error while calling %s: %w", method, err)
+	}
+	observeFanOutOverhead(method, len(clients), len(responses), time.Since(start)-fastest)
+
+	if len(responses) == 0 {
+		// Deterministic errors such as reverted calls are surfaced to the caller
+		if rpcErr != nil {
+			return nil, fmt.Errorf("failed to retrieve result from any client: %w", rpcErr)
+		}
+		return nil, fmt.Errorf("failed to retrieve result from any client")
+	}
+
+	return responses, nil
+}
+
+// observeFanOutOverhead records how long a fan-out to several clients kept waiting after the
+// fastest client answered. Requests sent to a single client and requests nobody answered have no overhead.
+func observeFanOutOverhead(method string, clients, answered int, overhead time.Duration) {
+	if clients < 2 || answered == 0 {
+		return
+	}
+	metrics.ObserveFanOutOverhead(method, overhead.Seconds())
+}
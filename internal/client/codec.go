@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// defaultMaxResponseSize caps the response body read from a client when none is configured
+	defaultMaxResponseSize = 16 << 20
+	// maxErrorBodySize caps how much of a non-200 response body is kept for the error message
+	maxErrorBodySize = 4 << 10
+)
+
+// ProtocolError is returned when a client response is valid JSON but violates
+// the JSON-RPC 2.0 specification, or exceeds the response size limit
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "protocol error: " + e.Reason
+}
+
+// envelope is a response as received, with every member kept raw so that
+// absent members can be told apart from null ones
+type envelope struct {
+	JSONRPC *string         `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   json.RawMessage `json:"error"`
+}
+
+// errorObject is a JSON-RPC error object with its required members
+type errorObject struct {
+	Code    *int            `json:"code"`
+	Message *string         `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decodeResponse decodes a single response and checks that it answers the request with the given id.
+// Invalid JSON is reported as a *ParseError, any other violation as a *ProtocolError.
+func decodeResponse(body []byte, id json.RawMessage) (*Response, error) {
+	resp, err := decodeEnvelope(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A null id is only allowed on errors the client could not attribute to a request
+	if isNull(resp.ID) && resp.Error != nil {
+		return resp, nil
+	}
+	if idKey(resp.ID) != idKey(id) {
+		return nil, &ProtocolError{Reason: fmt.Sprintf("response id %s does not match request id %s", resp.ID, id)}
+	}
+	return resp, nil
+}
+
+// decodeBatchResponse decodes a batch response and matches its elements to the request ids.
+// The result is aligned with ids, with nil for requests the client did not answer.
+// A single error object instead of an array means the client rejected the whole batch,
+// it is returned as the *RPCError.
+func decodeBatchResponse(body []byte, ids []json.RawMessage) ([]*Response, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		resp, err := decodeEnvelope(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error == nil {
+			return nil, &ProtocolError{Reason: "single result in response to a batch"}
+		}
+		return nil, resp.Error
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(trimmed, &elements); err != nil {
+		if !json.Valid(trimmed) {
+			return nil, &ParseError{Err: err}
+		}
+		return nil, &ProtocolError{Reason: "batch response is not an array"}
+	}
+
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		positions[idKey(id)] = i
+	}
+
+	responses := make([]*Response, len(ids))
+	for _, element := range elements {
+		resp, err := decodeEnvelope(element)
+		if err != nil {
+			return nil, err
+		}
+		if isNull(resp.ID) && resp.Error != nil {
+			// Errors without an id cannot be matched, their request is reported as unanswered
+			continue
+		}
+
+		i, ok := positions[idKey(resp.ID)]
+		if !ok {
+			return nil, &ProtocolError{Reason: fmt.Sprintf("batch response contains unknown id %s", resp.ID)}
+		}
+		if responses[i] != nil {
+			return nil, &ProtocolError{Reason: fmt.Sprintf("batch response contains id %s more than once", resp.ID)}
+		}
+		responses[i] = resp
+	}
+
+	return responses, nil
+}
+
+// decodeEnvelope decodes and validates a single response object without checking its id
+func decodeEnvelope(data []byte) (*Response, error) {
+	if !json.Valid(data) {
+		var v interface{}
+		return nil, &ParseError{Err: json.Unmarshal(data, &v)}
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, &ProtocolError{Reason: "response is not a JSON object"}
+	}
+
+	if env.JSONRPC == nil || *env.JSONRPC != "2.0" {
+		return nil, &ProtocolError{Reason: `response jsonrpc member is not "2.0"`}
+	}
+	if env.ID == nil {
+		return nil, &ProtocolError{Reason: "response has no id"}
+	}
+	if !isValidID(env.ID) {
+		return nil, &ProtocolError{Reason: fmt.Sprintf("response id %s is not a string, number or null", env.ID)}
+	}
+
+	hasResult := env.Result != nil
+	hasError := env.Error != nil && !isNull(env.Error)
+	switch {
+	case hasResult && hasError:
+		return nil, &ProtocolError{Reason: "response has both result and error"}
+	case !hasResult && !hasError:
+		return nil, &ProtocolError{Reason: "response has neither result nor error"}
+	}
+
+	resp := &Response{JSONRPC: *env.JSONRPC, ID: env.ID, Result: env.Result}
+	if hasError {
+		var obj errorObject
+		if err := json.Unmarshal(env.Error, &obj); err != nil || obj.Code == nil || obj.Message == nil {
+			return nil, &ProtocolError{Reason: "response error is not an object with an integer code and a message"}
+		}
+		resp.Error = &RPCError{Code: *obj.Code, Message: *obj.Message, Data: obj.Data}
+	}
+
+	return resp, nil
+}
+
+// isValidID reports whether a raw id is a string, a number or null as allowed by JSON-RPC 2.0
+func isValidID(id json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(id)
+	if len(trimmed) == 0 {
+		return false
+	}
+	switch trimmed[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	default:
+		return false
+	}
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// readLimited reads a response body, failing with a *ProtocolError when it is larger than limit bytes
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, &ProtocolError{Reason: fmt.Sprintf("response exceeds %d bytes", limit)}
+	}
+	return body, nil
+}
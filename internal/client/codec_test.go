@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	id := json.RawMessage(`7`)
+
+	tests := []struct {
+		name      string
+		body      string
+		result    string
+		rpcCode   int
+		errorType string
+	}{
+		{name: "Result", body: `{"jsonrpc":"2.0","id":7,"result":"0x1"}`, result: `"0x1"`},
+		{name: "Null result", body: `{"jsonrpc":"2.0","id":7,"result":null}`, result: `null`},
+		{name: "Result with null error", body: `{"jsonrpc":"2.0","id":7,"result":"0x1","error":null}`, result: `"0x1"`},
+		{name: "Error", body: `{"jsonrpc":"2.0","id":7,"error":{"code":-32000,"message":"execution reverted","data":"0x08"}}`, rpcCode: -32000},
+		{name: "Error without id", body: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`, rpcCode: -32700},
+		{name: "Invalid JSON", body: `{"jsonrpc":"2.0","id":7,`, errorType: "parse_error"},
+		{name: "Not an object", body: `"0x1"`, errorType: "protocol_error"},
+		{name: "Missing version", body: `{"id":7,"result":"0x1"}`, errorType: "protocol_error"},
+		{name: "Wrong version", body: `{"jsonrpc":"1.0","id":7,"result":"0x1"}`, errorType: "protocol_error"},
+		{name: "Missing id", body: `{"jsonrpc":"2.0","result":"0x1"}`, errorType: "protocol_error"},
+		{name: "Other id", body: `{"jsonrpc":"2.0","id":8,"result":"0x1"}`, errorType: "protocol_error"},
+		{name: "Object id", body: `{"jsonrpc":"2.0","id":{},"result":"0x1"}`, errorType: "protocol_error"},
+		{name: "Null id on result", body: `{"jsonrpc":"2.0","id":null,"result":"0x1"}`, errorType: "protocol_error"},
+		{name: "Result and error", body: `{"jsonrpc":"2.0","id":7,"result":"0x1","error":{"code":1,"message":"x"}}`, errorType: "protocol_error"},
+		{name: "Neither result nor error", body: `{"jsonrpc":"2.0","id":7}`, errorType: "protocol_error"},
+		{name: "Error without code", body: `{"jsonrpc":"2.0","id":7,"error":{"message":"x"}}`, errorType: "protocol_error"},
+		{name: "Error with fractional code", body: `{"jsonrpc":"2.0","id":7,"error":{"code":1.5,"message":"x"}}`, errorType: "protocol_error"},
+		{name: "Error as string", body: `{"jsonrpc":"2.0","id":7,"error":"boom"}`, errorType: "protocol_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := decodeResponse([]byte(tt.body), id)
+
+			if tt.errorType != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.errorType, ErrorType(err))
+				return
+			}
+
+			require.NoError(t, err)
+			if tt.rpcCode != 0 {
+				require.NotNil(t, resp.Error)
+				assert.Equal(t, tt.rpcCode, resp.Error.Code)
+				return
+			}
+			assert.Nil(t, resp.Error)
+			assert.JSONEq(t, tt.result, string(resp.Result))
+		})
+	}
+}
+
+func TestDecodeBatchResponse(t *testing.T) {
+	ids := []json.RawMessage{json.RawMessage(`1`), json.RawMessage(`2`), json.RawMessage(`3`)}
+
+	responses, err := decodeBatchResponse([]byte(`[
+		{"jsonrpc":"2.0","id":3,"result":"0x3"},
+		{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}},
+		{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}
+	]`), ids)
+	require.NoError(t, err)
+	require.Len(t, responses, 3)
+	assert.Equal(t, -32601, responses[0].Error.Code)
+	assert.Nil(t, responses[1], "unanswered requests are left nil")
+	assert.JSONEq(t, `"0x3"`, string(responses[2].Result))
+
+	_, err = decodeBatchResponse([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32005,"message":"batch too large"}}`), ids)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr, "a single error object rejects the whole batch")
+	assert.Equal(t, -32005, rpcErr.Code)
+
+	for name, body := range map[string]string{
+		"Single result":  `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+		"Unknown id":     `[{"jsonrpc":"2.0","id":9,"result":"0x1"}]`,
+		"Duplicate id":   `[{"jsonrpc":"2.0","id":1,"result":"0x1"},{"jsonrpc":"2.0","id":1,"result":"0x1"}]`,
+		"Invalid member": `[{"jsonrpc":"2.0","id":1}]`,
+		"Not an array":   `"0x1"`,
+	} {
+		_, err := decodeBatchResponse([]byte(body), ids)
+		assert.Equal(t, "protocol_error", ErrorType(err), name)
+	}
+
+	_, err = decodeBatchResponse([]byte(`[{"jsonrpc"`), ids)
+	assert.Equal(t, "parse_error", ErrorType(err))
+}
+
+func TestReadLimited(t *testing.T) {
+	body, err := readLimited(strings.NewReader("12345"), 5)
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(body))
+
+	_, err = readLimited(strings.NewReader("123456"), 5)
+	assert.Equal(t, "protocol_error", ErrorType(err))
+}
+
+func TestClient_RejectsOversizedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat("0", 1024) + `"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.ClientConfig{
+		URL:             server.URL,
+		Name:            "oversized",
+		Timeout:         time.Second,
+		MaxResponseSize: 512,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+	var protocolErr *ProtocolError
+	require.ErrorAs(t, err, &protocolErr)
+	assert.Equal(t, "protocol_error", ErrorType(err))
+	assert.False(t, IsRetryable(err))
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`), []byte(`1`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":"a","error":{"code":-32000,"message":"reverted","data":"0x"}}`), []byte(`"a"`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`), []byte(`1`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"result":null,"error":null}`), []byte(`1`))
+	f.Add([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`), []byte(`1`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1e0,"result":{}}`), []byte(`1`))
+
+	f.Fuzz(func(t *testing.T, body, id []byte) {
+		resp, err := decodeResponse(body, id)
+		if err != nil {
+			if resp != nil {
+				t.Fatalf("response returned together with error %v", err)
+			}
+			if errorType := ErrorType(err); errorType != "parse_error" && errorType != "protocol_error" {
+				t.Fatalf("unexpected error type %s for %v", errorType, err)
+			}
+			return
+		}
+
+		if resp.JSONRPC != "2.0" {
+			t.Fatalf("accepted jsonrpc %q", resp.JSONRPC)
+		}
+		if (resp.Result != nil) == (resp.Error != nil) {
+			t.Fatalf("accepted response with result %s and error %v", resp.Result, resp.Error)
+		}
+		if idKey(resp.ID) != idKey(id) && !(isNull(resp.ID) && resp.Error != nil) {
+			t.Fatalf("accepted id %s for request id %s", resp.ID, id)
+		}
+	})
+}
+
+func FuzzDecodeBatchResponse(f *testing.F) {
+	f.Add([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x1"},{"jsonrpc":"2.0","id":2,"error":{"code":1,"message":"x"}}]`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x1"},{"jsonrpc":"2.0","id":1,"result":"0x1"}]`))
+
+	ids := []json.RawMessage{json.RawMessage(`1`), json.RawMessage(`2`)}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		responses, err := decodeBatchResponse(body, ids)
+		if err != nil {
+			return
+		}
+
+		if len(responses) != len(ids) {
+			t.Fatalf("got %d responses for %d requests", len(responses), len(ids))
+		}
+		for i, resp := range responses {
+			if resp != nil && idKey(resp.ID) != idKey(ids[i]) {
+				t.Fatalf("response with id %s matched to request id %s", resp.ID, ids[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportError is a failure to deliver a request to a client or to read its response
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("request failed: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the request timed out
+func (e *TransportError) Timeout() bool {
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) && netErr.Timeout()
+}
+
+// HTTPStatusError is returned when a client answers with a non-200 HTTP status
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the Retry-After header, 0 when absent
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("received non-200 status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// ParseError is returned when a client response is not valid JSON-RPC
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing response: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// retryableRPCCodes are JSON-RPC error codes providers use for transient conditions
+var retryableRPCCodes = map[int]bool{
+	CodeInternalError: true,
+}
+
+// IsRetryable reports whether a failed request may succeed if sent again.
+// Transport failures, timeouts and 5xx responses are transient; malformed
+// responses and deterministic RPC errors such as invalid params are not.
+// Rate-limit responses throttle the client, so they are not retried against it.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) {
+		return false
+	}
+	if _, limited := rateLimitDelay(err); limited {
+		return false
+	}
+
+	var transportErr *TransportError
+	var statusErr *HTTPStatusError
+	var rpcErr *RPCError
+
+	switch {
+	case errors.As(err, &transportErr):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode == http.StatusRequestTimeout ||
+			statusErr.StatusCode >= http.StatusInternalServerError
+	case errors.As(err, &rpcErr):
+		return retryableRPCCodes[rpcErr.Code]
+	default:
+		return false
+	}
+}
+
+// ErrorType classifies an error into the label used by the client_errors_total metric
+func ErrorType(err error) string {
+	var transportErr *TransportError
+	var statusErr *HTTPStatusError
+	var parseErr *ParseError
+	var protocolErr *ProtocolError
+	var rpcErr *RPCError
+
+	if _, limited := rateLimitDelay(err); limited {
+		return "rate_limited"
+	}
+
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrRateLimited):
+		return "throttled"
+	case errors.As(err, &transportErr):
+		if transportErr.Timeout() {
+			return "timeout"
+		}
+		return "request_failed"
+	case errors.As(err, &statusErr):
+		return "non_200_status"
+	case errors.As(err, &parseErr):
+		return "parse_error"
+	case errors.As(err, &protocolErr):
+		return "protocol_error"
+	case errors.As(err, &rpcErr):
+		return "rpc_error"
+	default:
+		return "unknown"
+	}
+}
@@ -1,81 +1,323 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/bersh/alluvial_test_1/internal/metrics"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/sync/errgroup"
 )
 
-// CheckAllHealth checks the health of all clients
+// defaultHealthCheckTimeout bounds a health probe when the pool configuration does not set it
+const defaultHealthCheckTimeout = 5 * time.Second
+
+// healthCheckMethod is the method label of health probes in upstream metrics
+const healthCheckMethod = "health_check"
+
+// BlockHead is the latest block reported by a client
+type BlockHead struct {
+	Number    uint64
+	Timestamp uint64
+}
+
+// Outcomes of a health check, reported in the client's health history and metrics
+const (
+	healthHealthy     = "healthy"
+	healthDegraded    = "degraded"
+	healthLagging     = "lagging"
+	healthSyncing     = "syncing"
+	healthWrongChain  = "wrong_chain"
+	healthRateLimited = "rate_limited"
+	healthFailed      = "failed"
+)
+
+// probeResult is the outcome of a single health probe
+type probeResult struct {
+	head    BlockHead
+	syncing bool
+	err     error
+	// chainErr is set when the client's chain could not be verified or does not match
+	chainErr error
+	latency  time.Duration
+}
+
+// CheckAllHealth probes all clients and waits for the results, see checkAll
 func (p *PoolStruct) CheckAllHealth() {
+	p.checkAll(context.Background())
+}
+
+// checkAll probes all clients concurrently, at most the configured number at a time, and waits for the results.
+// Each client's head is compared with the reference head of the round, see referenceHead, so lagging
+// and syncing nodes are marked degraded or unavailable.
+// The state history of clients of undeclared node kind is detected on their first successful probe.
+// Nothing is applied when ctx is cancelled before the probes finish, so a shutdown does not mark clients unavailable.
+func (p *PoolStruct) checkAll(ctx context.Context) {
 	log.Println("Running health check for all clients...")
 
 	clients := p.GetAllClients()
-	for _, client := range clients {
-		go func(c *Client) {
-			isHealthy := p.CheckClientHealth(c)
-			p.SetClientAvailability(c.Name, isHealthy)
-			log.Printf("Client %s health: %v\n", c.Name, isHealthy)
-		}(client)
+	results := make([]probeResult, len(clients))
+
+	var g errgroup.Group
+	if limit := p.config().Health.Concurrency; limit > 0 {
+		g.SetLimit(limit)
+	}
+	for i, client := range clients {
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+			results[i] = p.probe(ctx, client)
+			p.detectState(ctx, client, results[i])
+			return nil
+		})
+	}
+	g.Wait()
+
+	if ctx.Err() != nil {
+		log.Printf("Health check interrupted: %v\n", ctx.Err())
+		return
+	}
+
+	poolHead := p.updatePoolHead(results)
+	for i, client := range clients {
+		isHealthy := p.applyProbe(client, results[i], poolHead)
+		log.Printf("Client %s health: %v\n", client.Name, isHealthy)
 	}
 }
 
-// CheckClientHealth checks if a specific client is healthy
+// CheckClientHealth probes a single client, updates its availability and reports whether it is available.
+// Its head is compared with the reference head of the last round of checks, which a single client cannot raise.
+// Until a round has produced a pool head there is nothing to compare with, so a client added through the admin
+// API or a reload before then is not found lagging until the next round of checks.
 func (p *PoolStruct) CheckClientHealth(client *Client) bool {
-	payload := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"method":  "eth_blockNumber",
-		"params":  []interface{}{},
-		"id":      1,
-	}
+	return p.checkClientHealth(context.Background(), client)
+}
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Error marshaling health check payload for %s: %v\n", client.Name, err)
-		return false
+// checkClientHealth is CheckClientHealth bounded by ctx as well as the probe timeout
+func (p *PoolStruct) checkClientHealth(ctx context.Context, client *Client) bool {
+	result := p.probe(ctx, client)
+	p.detectState(ctx, client, result)
+	return p.applyProbe(client, result, p.poolHead())
+}
+
+// detectState detects the state history of a client once a probe found it in sync on the expected chain
+func (p *PoolStruct) detectState(ctx context.Context, client *Client, result probeResult) {
+	if result.err == nil && result.chainErr == nil && !result.syncing {
+		p.detectStateHistory(ctx, client, result.head.Number)
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// probe fetches the client's latest block and sync status in one batch,
+// together with its chain identity when an expected chain is configured.
+// The probe goes through the client's own transport, so WebSocket clients
+// are checked over their persistent connection. It bypasses the circuit breaker,
+// which recovers on its own through half-open probes.
+func (p *PoolStruct) probe(ctx context.Context, client *Client) probeResult {
+	start := time.Now()
+	result := p.runProbe(ctx, client)
+	result.latency = time.Since(start)
+	metrics.ObserveUpstreamRequest(client.Name, healthCheckMethod, upstreamOutcome(result.err), result.latency.Seconds())
+	return result
+}
+
+func (p *PoolStruct) runProbe(ctx context.Context, client *Client) probeResult {
+	timeout := p.config().Health.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "POST", client.URL, strings.NewReader(string(payloadBytes)))
+	calls := []Call{
+		{Method: "eth_getBlockByNumber", Params: []interface{}{"latest", false}},
+		{Method: "eth_syncing", Params: []interface{}{}},
+	}
+	verifyChain := p.config().ExpectedChainID != 0
+	if verifyChain {
+		calls = append(calls, chainIdentityCalls...)
+	}
+
+	results, err := client.sendBatch(ctx, calls)
 	if err != nil {
-		log.Printf("Error creating health check request for %s: %v\n", client.Name, err)
-		return false
+		return probeResult{err: err}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	var chainErr error
+	if verifyChain {
+		chainErr = p.checkChain(results[2:])
+	}
 
-	resp, err := client.HTTPClient.Do(req)
-	if err != nil {
-		log.Printf("Health check request failed for %s: %v\n", client.Name, err)
-		metrics.RecordClientError(client.Name, "health_check")
-		return false
+	for _, result := range results[:2] {
+		if result.Error != nil {
+			return probeResult{err: result.Error, chainErr: chainErr}
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Health check received non-200 status code from %s: %d\n", client.Name, resp.StatusCode)
-		return false
+	var block struct {
+		Number    hexutil.Uint64 `json:"number"`
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+	if err := json.Unmarshal(results[0].Result, &block); err != nil {
+		return probeResult{err: &ParseError{Err: fmt.Errorf("latest block: %w", err)}, chainErr: chainErr}
 	}
 
-	// Read and parse response
-	var response map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		log.Printf("Error parsing health check response from %s: %v\n", client.Name, err)
-		return false
+	// eth_syncing returns false when the node is in sync and a progress object otherwise
+	syncing := !bytes.Equal(bytes.TrimSpace(results[1].Result), []byte("false"))
+
+	return probeResult{
+		head:     BlockHead{Number: uint64(block.Number), Timestamp: uint64(block.Timestamp)},
+		syncing:  syncing,
+		chainErr: chainErr,
 	}
+}
 
-	// Check if there's an error in the response
-	if _, ok := response["error"]; ok {
-		log.Printf("Health check received error response from %s: %v\n", client.Name, response["error"])
-		return false
+// updatePoolHead replaces the pool head with the reference head of a round of probes and returns it.
+// The pool head is recomputed every round, so a height reported by a misbehaving or removed client
+// is forgotten instead of marking every other client as lagging. A round without any usable probe keeps it.
+func (p *PoolStruct) updatePoolHead(results []probeResult) BlockHead {
+	head, ok := referenceHead(results)
+
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	if ok {
+		p.head = head
 	}
+	return p.head
+}
+
+// poolHead returns the reference head of the last round of checks
+func (p *PoolStruct) poolHead() BlockHead {
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+	return p.head
+}
+
+// referenceHead returns the highest head reached by at least half of the successful probes of in-sync
+// clients on the expected chain, so one client reporting a bogus height cannot make the others lag.
+// It reports false when no probe qualifies.
+func referenceHead(results []probeResult) (BlockHead, bool) {
+	heads := make([]BlockHead, 0, len(results))
+	for _, result := range results {
+		if result.err == nil && result.chainErr == nil && !result.syncing {
+			heads = append(heads, result.head)
+		}
+	}
+	if len(heads) == 0 {
+		return BlockHead{}, false
+	}
+
+	sort.Slice(heads, func(i, j int) bool { return heads[i].Number > heads[j].Number })
+	return heads[(len(heads)+1)/2-1], true
+}
+
+// applyProbe updates the client's head, availability and degradation from a probe result,
+// adds it to the client's health history and reports whether the client is available
+func (p *PoolStruct) applyProbe(client *Client, result probeResult, poolHead BlockHead) bool {
+	outcome, available := p.evaluateProbe(client, result, poolHead)
+	p.recordHealth(client, result, outcome, available)
+	return available
+}
+
+// evaluateProbe updates the client from a probe result and returns the outcome of the check
+// and whether the client is available
+func (p *PoolStruct) evaluateProbe(client *Client, result probeResult, poolHead BlockHead) (string, bool) {
+	if result.chainErr != nil {
+		var mismatch *ChainMismatchError
+		if errors.As(result.chainErr, &mismatch) {
+			log.Printf("Client %s is on the wrong chain: %v\n", client.Name, result.chainErr)
+			p.applyChainCheck(client, result.chainErr)
+			p.setClientHealth(client, false, false)
+			return healthWrongChain, false
+		}
+		if result.err == nil {
+			result.err = result.chainErr
+		}
+	} else if result.err == nil && p.config().ExpectedChainID != 0 {
+		p.applyChainCheck(client, nil)
+	}
+
+	if result.err != nil {
+		// A rate-limited client is alive, it is skipped by selection until the limit clears
+		if retryAfter, limited := rateLimitDelay(result.err); limited {
+			log.Printf("Health check for %s was rate limited: %v\n", client.Name, result.err)
+			client.limiter.Throttle(retryAfter)
+			return healthRateLimited, p.clientAvailable(client)
+		}
+
+		log.Printf("Health check failed for %s: %v\n", client.Name, result.err)
+		metrics.RecordClientError(client.Name, "health_check")
+		p.setClientHealth(client, false, false)
+		return healthFailed, false
+	}
+
+	client.setHead(result.head)
+
+	blockLag := uint64(0)
+	if poolHead.Number > result.head.Number {
+		blockLag = poolHead.Number - result.head.Number
+	}
+	timeLag := time.Duration(0)
+	if poolHead.Timestamp > result.head.Timestamp {
+		timeLag = time.Duration(poolHead.Timestamp-result.head.Timestamp) * time.Second
+	}
+	metrics.SetClientHeadLag(client.Name, float64(blockLag), timeLag.Seconds())
+
+	health := p.config().Health
+	switch {
+	case result.syncing:
+		log.Printf("Client %s is syncing\n", client.Name)
+		p.setClientHealth(client, false, false)
+		return healthSyncing, false
+	case blockLag > uint64(health.MaxBlockLag) || timeLag > health.MaxTimeLag:
+		log.Printf("Client %s is %d blocks (%v) behind the pool head, marking unavailable\n", client.Name, blockLag, timeLag)
+		p.setClientHealth(client, false, false)
+		return healthLagging, false
+	case blockLag > uint64(health.DegradedBlockLag) || timeLag > health.DegradedTimeLag:
+		log.Printf("Client %s is %d blocks (%v) behind the pool head, marking degraded\n", client.Name, blockLag, timeLag)
+		p.setClientHealth(client, true, true)
+		return healthDegraded, true
+	default:
+		p.setClientHealth(client, true, false)
+		return healthHealthy, true
+	}
+}
+
+// setClientHealth updates the availability and degradation of a client
+func (p *PoolStruct) setClientHealth(client *Client, isAvailable, isDegraded bool) {
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	client.setAvailability(isAvailable, reasonHealthCheck)
+	client.IsDegraded = isDegraded
+	metrics.SetClientDegraded(client.Name, isDegraded)
+}
+
+// clientAvailable reads the client's availability under the pool lock
+func (p *PoolStruct) clientAvailable(client *Client) bool {
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+	return client.IsAvailable
+}
+
+// Head returns the latest block reported by the client's last successful health check
+func (c *Client) Head() BlockHead {
+	c.headMu.RLock()
+	defer c.headMu.RUnlock()
+	return c.head
+}
+
+func (c *Client) setHead(head BlockHead) {
+	c.headMu.Lock()
+	c.head = head
+	c.headMu.Unlock()
 
-	return true
+	metrics.SetClientHead(c.Name, float64(head.Number))
 }
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHeadServer(t *testing.T, number, timestamp uint64) *rpctest.Server {
+	t.Helper()
+
+	server := rpctest.NewServer()
+	t.Cleanup(server.Close)
+	server.HandleHead(number, timestamp)
+	return server
+}
+
+func TestPoolStruct_CheckAllHealth(t *testing.T) {
+	const now = 1700000000
+
+	leader := newHeadServer(t, 1000, now)
+	behind := newHeadServer(t, 999, now-12)
+	lagging := newHeadServer(t, 995, now-60)
+	stale := newHeadServer(t, 900, now-1200)
+	syncing := newHeadServer(t, 1000, now)
+	syncing.HandleResult("eth_syncing", map[string]string{"currentBlock": "0x3e8", "highestBlock": "0x7d0"})
+	broken := rpctest.NewServer()
+	defer broken.Close()
+
+	var configs []config.ClientConfig
+	for name, server := range map[string]*rpctest.Server{
+		"leader": leader, "behind": behind, "lagging": lagging, "stale": stale, "syncing": syncing, "broken": broken,
+	} {
+		configs = append(configs, config.ClientConfig{URL: server.URL, Name: name, Timeout: time.Second})
+	}
+
+	pool, err := NewPool(configs, config.PoolConfig{Health: config.HealthConfig{
+		DegradedBlockLag: 2,
+		MaxBlockLag:      10,
+		DegradedTimeLag:  30 * time.Second,
+		MaxTimeLag:       5 * time.Minute,
+	}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pool.CheckAllHealth()
+
+	status := map[string][2]bool{}
+	for _, client := range pool.GetAllClients() {
+		status[client.Name] = [2]bool{client.IsAvailable, client.IsDegraded}
+	}
+
+	assert.Equal(t, [2]bool{true, false}, status["leader"])
+	assert.Equal(t, [2]bool{true, false}, status["behind"])
+	assert.Equal(t, [2]bool{true, true}, status["lagging"], "5 blocks behind is degraded")
+	assert.Equal(t, [2]bool{false, false}, status["stale"], "100 blocks behind is unavailable")
+	assert.Equal(t, [2]bool{false, false}, status["syncing"])
+	assert.Equal(t, [2]bool{false, false}, status["broken"])
+
+	assert.Equal(t, BlockHead{Number: 999, Timestamp: now - 12}, pool.head, "the highest head reached by half of the clients")
+	assert.ElementsMatch(t, []string{"leader", "behind"}, clientNames(pool.GetAvailableClients()),
+		"degraded clients are only used when no healthy client is left")
+
+	for _, client := range pool.GetAllClients() {
+		if client.Name == "leader" || client.Name == "behind" {
+			pool.SetClientAvailability(client.Name, false)
+		}
+	}
+	assert.Equal(t, []string{"lagging"}, clientNames(pool.GetAvailableClients()))
+}
+
+func TestPoolStruct_PoolHeadFollowsTheCurrentRound(t *testing.T) {
+	const now = 1700000000
+
+	honest := []*rpctest.Server{newHeadServer(t, 100, now), newHeadServer(t, 100, now)}
+	liar := newHeadServer(t, 100000, now+1000000)
+	ahead := newHeadServer(t, 500, now+4800)
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: honest[0].URL, Name: "honest-1", Timeout: time.Second},
+		{URL: honest[1].URL, Name: "honest-2", Timeout: time.Second},
+		{URL: liar.URL, Name: "liar", Timeout: time.Second},
+	}, config.PoolConfig{Health: config.HealthConfig{MaxBlockLag: 10, MaxTimeLag: 5 * time.Minute}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pool.CheckAllHealth()
+	assert.Equal(t, uint64(100), pool.head.Number)
+	assert.ElementsMatch(t, []string{"honest-1", "honest-2", "liar"}, availableNames(pool),
+		"a single client reporting a bogus height does not make the others lag")
+
+	var liarClient *Client
+	for _, client := range pool.GetAllClients() {
+		if client.Name == "liar" {
+			liarClient = client
+		}
+	}
+	pool.CheckClientHealth(liarClient)
+	assert.Equal(t, uint64(100), pool.head.Number, "checking a single client does not raise the pool head")
+
+	// Two clients cannot outvote one another, the one ahead sets the head until it is removed
+	require.NoError(t, pool.RemoveClient(context.Background(), "liar"))
+	require.NoError(t, pool.RemoveClient(context.Background(), "honest-2"))
+	_, err = pool.AddClient(context.Background(), config.ClientConfig{URL: ahead.URL, Name: "ahead", Timeout: time.Second})
+	require.NoError(t, err)
+
+	pool.CheckAllHealth()
+	assert.Equal(t, uint64(500), pool.head.Number)
+	assert.Equal(t, []string{"ahead"}, availableNames(pool))
+
+	require.NoError(t, pool.RemoveClient(context.Background(), "ahead"))
+	pool.CheckAllHealth()
+	assert.Equal(t, uint64(100), pool.head.Number, "the head of a removed client is forgotten")
+	assert.Equal(t, []string{"honest-1"}, availableNames(pool))
+}
+
+func TestPoolStruct_CheckClientHealthBeforeTheFirstRound(t *testing.T) {
+	const now = 1700000000
+
+	leader := newHeadServer(t, 100, now)
+	behind := newHeadServer(t, 50, now-600)
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: leader.URL, Name: "leader", Timeout: time.Second},
+		{URL: behind.URL, Name: "behind", Timeout: time.Second},
+	}, config.PoolConfig{Health: config.HealthConfig{MaxBlockLag: 10, MaxTimeLag: 5 * time.Minute}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	var behindClient *Client
+	for _, client := range pool.GetAllClients() {
+		if client.Name == "behind" {
+			behindClient = client
+		}
+	}
+
+	assert.True(t, pool.CheckClientHealth(behindClient), "without a pool head a client cannot be found lagging")
+
+	pool.CheckAllHealth()
+	assert.Equal(t, []string{"leader"}, availableNames(pool))
+	assert.False(t, pool.CheckClientHealth(behindClient), "a round of checks sets the pool head single checks compare with")
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// QueryBalanceHedged queries the balance from the fastest available client first and
+// sends the same request to the next fastest client only when no answer arrived within
+// the hedge delay, up to hedge.MaxRequests requests in total. A failed request triggers
+// the next one immediately. Requests still in flight are cancelled once a valid answer arrives.
+func (p *PoolStruct) QueryBalanceHedged(ctx context.Context, address string, block BlockParameter, hedge config.HedgeConfig) (*BalanceResponse, error) {
+	clients, err := p.GetClientsForBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return nil, ErrNoClientsAvailable
+	}
+
+	sortByLatency(clients)
+	maxRequests := min(max(hedge.MaxRequests, 1), len(clients))
+
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan BalanceResponse, maxRequests)
+	launch := func(client *Client) {
+		go func() {
+			balance, err := client.QueryBalance(hedgeCtx, address, block)
+			results <- BalanceResponse{ClientName: client.Name, Balance: balance, Error: err}
+		}()
+	}
+
+	launch(clients[0])
+	launched, pending := 1, 1
+
+	var lastErr error
+	for pending > 0 {
+		var hedgeTimer *time.Timer
+		var hedgeC <-chan time.Time
+		if launched < maxRequests {
+			hedgeTimer = time.NewTimer(hedgeDelay(clients[launched-1], hedge))
+			hedgeC = hedgeTimer.C
+		}
+
+		select {
+		case resp := <-results:
+			pending--
+			if resp.Error == nil {
+				stopTimer(hedgeTimer)
+				return &resp, nil
+			}
+			lastErr = resp.Error
+			log.Printf("Error from client %s: %v\n", resp.ClientName, resp.Error)
+
+			if launched < maxRequests {
+				launch(clients[launched])
+				launched++
+				pending++
+			}
+		case <-hedgeC:
+			launch(clients[launched])
+			launched++
+			pending++
+		case <-ctx.Done():
+			stopTimer(hedgeTimer)
+			return nil, fmt.Errorf("hedged balance query: %w", ctx.Err())
+		}
+		stopTimer(hedgeTimer)
+	}
+
+	return nil, fmt.Errorf("failed to retrieve balance from any client: %w", lastErr)
+}
+
+// hedgeDelay returns how long to wait for a client before hedging to the next one
+func hedgeDelay(client *Client, hedge config.HedgeConfig) time.Duration {
+	if hedge.UseP95 {
+		if p95 := client.LatencyPercentile(0.95); p95 > 0 {
+			return p95
+		}
+	}
+	return hedge.Delay
+}
+
+// sortByLatency orders clients from the lowest to the highest moving average latency.
+// Clients without latency data go last so that known fast clients are preferred.
+func sortByLatency(clients []*Client) {
+	slices.SortStableFunc(clients, func(a, b *Client) int {
+		la, lb := a.Latency(), b.Latency()
+		switch {
+		case la == lb:
+			return 0
+		case la == 0:
+			return 1
+		case lb == 0:
+			return -1
+		case la < lb:
+			return -1
+		default:
+			return 1
+		}
+	})
+}
+
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBalanceServer(t *testing.T, delay time.Duration, balance string) *rpctest.Server {
+	t.Helper()
+
+	server := rpctest.NewServer()
+	t.Cleanup(server.Close)
+
+	server.Handle("eth_getBalance", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		time.Sleep(delay)
+		return balance, nil
+	})
+	return server
+}
+
+func TestPoolStruct_QueryBalanceHedged(t *testing.T) {
+	slow := newBalanceServer(t, 300*time.Millisecond, "0x1")
+	fast := newBalanceServer(t, 0, "0x2")
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: slow.URL, Name: "slow", Timeout: 5 * time.Second},
+		{URL: fast.URL, Name: "fast", Timeout: 5 * time.Second},
+	}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	// NewPool reads the chain id of its clients
+	slowStartup, fastStartup := slow.Requests(), fast.Requests()
+
+	hedge := config.HedgeConfig{Delay: 20 * time.Millisecond, MaxRequests: 2}
+
+	start := time.Now()
+	response, err := pool.QueryBalanceHedged(context.Background(), "0xabc", LatestBlock, hedge)
+	require.NoError(t, err)
+
+	assert.Equal(t, "fast", response.ClientName, "the hedged request should win")
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, fastStartup+1, fast.Requests())
+
+	// The fast client now has latency data and is tried first, so no hedge is needed
+	response, err = pool.QueryBalanceHedged(context.Background(), "0xabc", LatestBlock, hedge)
+	require.NoError(t, err)
+	assert.Equal(t, "fast", response.ClientName)
+	assert.Equal(t, slowStartup+1, slow.Requests())
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// httpTransport sends every JSON-RPC payload as a separate HTTP POST request
+type httpTransport struct {
+	url             string
+	auth            Authenticator
+	maxResponseSize int64
+	httpClient      *http.Client
+}
+
+func newHTTPTransport(cfg config.ClientConfig, auth Authenticator) *httpTransport {
+	return &httpTransport{
+		url:             cfg.URL,
+		auth:            auth,
+		maxResponseSize: maxResponseSize(cfg),
+		httpClient:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// roundTrip posts a JSON-RPC payload to the client and returns the response body
+func (t *httpTransport) roundTrip(ctx context.Context, requests []Request, batch bool) ([]byte, error) {
+	payloadBytes, err := json.Marshal(requestPayload(requests, batch))
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if err := t.auth.Apply(req.Header); err != nil {
+		return nil, fmt.Errorf("error authenticating request: %w", err)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, &TransportError{Err: err}
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Error closing body: %v\n", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return nil, &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	body, err := readLimited(resp.Body, t.maxResponseSize)
+	if err != nil {
+		var protocolErr *ProtocolError
+		if errors.As(err, &protocolErr) {
+			return nil, err
+		}
+		return nil, &TransportError{Err: fmt.Errorf("error reading response: %w", err)}
+	}
+
+	return body, nil
+}
+
+func (t *httpTransport) Close() error {
+	t.httpClient.CloseIdleConnections()
+	return nil
+}
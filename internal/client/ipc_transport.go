@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// ipcSchemes are the URL schemes of clients reached through a Unix socket
+var ipcSchemes = []string{"ipc://", "unix://"}
+
+// isIPCURL reports whether a client URL points to a Unix socket
+func isIPCURL(url string) bool {
+	return ipcPath(url) != ""
+}
+
+// ipcPath returns the socket path of an ipc:// or unix:// URL, e.g. /data/geth.ipc for ipc:///data/geth.ipc
+func ipcPath(url string) string {
+	for _, scheme := range ipcSchemes {
+		if len(url) > len(scheme) && strings.EqualFold(url[:len(scheme)], scheme) {
+			return url[len(scheme):]
+		}
+	}
+	return ""
+}
+
+// newIPCTransport creates a stream transport over the Unix socket of a node running next to the proxy,
+// such as geth.ipc. The socket is local, so no credentials are sent.
+func newIPCTransport(cfg config.ClientConfig) *streamTransport {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	path := ipcPath(cfg.URL)
+	limit := maxResponseSize(cfg)
+
+	return newStreamTransport(cfg.Name, "IPC", limit, func(ctx context.Context) (streamConn, error) {
+		conn, err := dialer.DialContext(ctx, "unix", path)
+		if err != nil {
+			return nil, err
+		}
+		return newIPCConn(conn, limit), nil
+	})
+}
+
+// ipcConn carries JSON-RPC messages over a Unix socket as a stream of JSON values,
+// the framing geth and reth use on their IPC endpoints
+type ipcConn struct {
+	conn    net.Conn
+	reader  *messageLimitReader
+	decoder *json.Decoder
+	writeMu sync.Mutex
+}
+
+func newIPCConn(conn net.Conn, maxResponseSize int64) *ipcConn {
+	reader := &messageLimitReader{r: conn, limit: maxResponseSize}
+	return &ipcConn{conn: conn, reader: reader, decoder: json.NewDecoder(reader)}
+}
+
+func (c *ipcConn) ReadMessage() ([]byte, error) {
+	var message json.RawMessage
+	if err := c.decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+	c.reader.start = c.decoder.InputOffset()
+	return message, nil
+}
+
+func (c *ipcConn) WriteMessage(payload []byte, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(deadline)
+	_, err := c.conn.Write(append(payload, '\n'))
+	return err
+}
+
+func (c *ipcConn) Close() error {
+	return c.conn.Close()
+}
+
+// messageLimitReader fails with errMessageTooLarge once the message being decoded from
+// a stream has grown beyond the limit, so a misbehaving peer cannot exhaust memory
+type messageLimitReader struct {
+	r     io.Reader
+	limit int64
+	// read is the number of bytes read from the stream, start the offset of the current message
+	read  int64
+	start int64
+}
+
+func (r *messageLimitReader) Read(p []byte) (int, error) {
+	if r.read-r.start > r.limit {
+		return 0, errMessageTooLarge
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIPCServer starts a fake node listening on a Unix socket and returns it with its ipc:// URL
+func newIPCServer(t *testing.T) (*rpctest.Server, string) {
+	t.Helper()
+
+	server := rpctest.NewServer()
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "geth.ipc")
+	require.NoError(t, server.ListenIPC(path))
+	return server, "ipc://" + path
+}
+
+func newIPCTestClient(t *testing.T, url string, maxResponseSize int) *Client {
+	t.Helper()
+
+	client, err := NewClient(config.ClientConfig{
+		URL:             url,
+		Name:            "ipc-test",
+		Timeout:         time.Second,
+		MaxResponseSize: maxResponseSize,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	require.Eventually(t, func() bool {
+		_, err := client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "ipc transport did not connect")
+
+	return client
+}
+
+func TestIPCTransport_MultiplexesRequests(t *testing.T) {
+	server, url := newIPCServer(t)
+	server.HandleResult("eth_blockNumber", "0x10")
+	server.HandleResult("eth_getBalance", "0x3e8")
+
+	client := newIPCTestClient(t, url, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			balance, err := client.QueryBalance(context.Background(), "0xabc", LatestBlock)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, big.NewInt(1000).Cmp(balance))
+		}()
+	}
+	wg.Wait()
+
+	results, err := client.BatchCall(context.Background(), []Call{
+		{Method: "eth_getBalance", Params: []interface{}{"0xabc", "latest"}},
+		{Method: "eth_chainId", Params: []interface{}{}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.JSONEq(t, `"0x3e8"`, string(results[0].Result))
+	var rpcErr *RPCError
+	assert.ErrorAs(t, results[1].Error, &rpcErr)
+
+	assert.Equal(t, int64(1), server.Connections(), "requests should share one connection")
+}
+
+func TestIPCTransport_Reconnects(t *testing.T) {
+	server, url := newIPCServer(t)
+	server.HandleResult("eth_blockNumber", "0x10")
+
+	client := newIPCTestClient(t, strings.Replace(url, "ipc://", "unix://", 1), 0)
+
+	server.DropConnections()
+
+	assert.Eventually(t, func() bool {
+		_, err := client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "ipc transport did not reconnect")
+	assert.Equal(t, int64(2), server.Connections())
+}
+
+func TestIPCTransport_ConnectsOnceSocketAppears(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geth.ipc")
+
+	client, err := NewClient(config.ClientConfig{URL: "ipc://" + path, Name: "ipc-test", Timeout: time.Second})
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+	var transportErr *TransportError
+	require.ErrorAs(t, err, &transportErr, "requests fail fast while the node is down")
+
+	server := rpctest.NewServer()
+	defer server.Close()
+	server.HandleResult("eth_blockNumber", "0x10")
+	require.NoError(t, server.ListenIPC(path))
+
+	assert.Eventually(t, func() bool {
+		_, err := client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "ipc transport did not connect to the new socket")
+}
+
+func TestIPCTransport_RejectsOversizedResponse(t *testing.T) {
+	server, url := newIPCServer(t)
+	server.HandleResult("eth_blockNumber", "0x10")
+	server.Handle("eth_getLogs", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		return strings.Repeat("a", 4096), nil
+	})
+
+	client := newIPCTestClient(t, url, 1024)
+
+	_, err := client.Call(context.Background(), "eth_getLogs", []interface{}{})
+	var protocolErr *ProtocolError
+	require.ErrorAs(t, err, &protocolErr)
+
+	// The connection is dropped and re-established for the next request
+	assert.Eventually(t, func() bool {
+		_, err := client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// latencySamples is the number of recent request durations kept per client
+	latencySamples = 128
+	// latencyEWMAWeight is the weight of the newest sample in the moving average
+	latencyEWMAWeight = 0.2
+)
+
+// latencyTracker keeps recent request durations of a client
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	ewma    float64
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{samples: make([]time.Duration, 0, latencySamples)}
+}
+
+// Observe records the duration of a completed request
+func (l *latencyTracker) Observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+	} else {
+		l.samples[l.next] = d
+	}
+	l.next = (l.next + 1) % latencySamples
+
+	if l.ewma == 0 {
+		l.ewma = float64(d)
+	} else {
+		l.ewma = latencyEWMAWeight*float64(d) + (1-latencyEWMAWeight)*l.ewma
+	}
+}
+
+// EWMA returns the exponentially weighted moving average latency, 0 when nothing was observed
+func (l *latencyTracker) EWMA() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Duration(l.ewma)
+}
+
+// Percentile returns the q-th percentile (0 < q <= 1) of recent latencies, 0 when nothing was observed
+func (l *latencyTracker) Percentile(q float64) time.Duration {
+	l.mu.Lock()
+	sorted := slices.Clone(l.samples)
+	l.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0
+	}
+	slices.Sort(sorted)
+
+	index := int(q*float64(len(sorted))+0.5) - 1
+	index = max(0, min(index, len(sorted)-1))
+	return sorted[index]
+}
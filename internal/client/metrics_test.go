@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var initMetrics sync.Once
+
+func TestPoolStruct_RecordsUpstreamMetrics(t *testing.T) {
+	initMetrics.Do(metrics.Init)
+
+	fast := newHeadServer(t, 100, 1700000000)
+	fast.HandleResult("eth_getBalance", "0x1")
+	slow := newHeadServer(t, 100, 1700000000)
+	slow.Handle("eth_getBalance", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		time.Sleep(50 * time.Millisecond)
+		return "0x1", nil
+	})
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: fast.URL, Name: "metrics-fast", Timeout: time.Second},
+		{URL: slow.URL, Name: "metrics-slow", Timeout: time.Second},
+	}, config.PoolConfig{Health: config.HealthConfig{MaxTimeLag: time.Minute}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	// Metrics are global, so the test compares counts before and after the requests
+	counts := func() []uint64 {
+		var counts []uint64
+		for _, name := range []string{"metrics-fast", "metrics-slow"} {
+			counts = append(counts,
+				sampleCount(t, metrics.M.UpstreamDuration.WithLabelValues(name, "eth_getBalance", "success")),
+				sampleCount(t, metrics.M.UpstreamDuration.WithLabelValues(name, healthCheckMethod, "success")))
+		}
+		return append(counts, sampleCount(t, metrics.M.FanOutOverhead.WithLabelValues("eth_getBalance")))
+	}
+	before := counts()
+
+	pool.CheckAllHealth()
+	_, err = pool.QueryBalanceFromAllClients(context.Background(), "0xabc", LatestBlock)
+	require.NoError(t, err)
+
+	after := counts()
+	for i := range before {
+		assert.Equal(t, before[i]+1, after[i], "every request and probe is observed once")
+	}
+	for _, name := range []string{"metrics-fast", "metrics-slow"} {
+		assert.Equal(t, 0.0, testutil.ToFloat64(metrics.M.ClientInFlight.WithLabelValues(name)), name)
+	}
+}
+
+func TestPoolStruct_RecordsTierOfDispatchedRequests(t *testing.T) {
+	initMetrics.Do(metrics.Init)
+
+	primary := newHeadServer(t, 100, 1700000000)
+	primary.HandleResult("eth_getBalance", "0x1")
+	fallback := newHeadServer(t, 100, 1700000000)
+
+	// Tiers no other test uses, as metrics are global
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: primary.URL, Name: "tier-primary", Timeout: time.Second, Tier: 5},
+		{URL: fallback.URL, Name: "tier-fallback", Timeout: time.Second, Tier: 6},
+	}, config.PoolConfig{Quorum: 1, Health: config.HealthConfig{MaxTimeLag: time.Minute}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	counts := func() [2]float64 {
+		return [2]float64{
+			testutil.ToFloat64(metrics.M.TierRequests.WithLabelValues("5")),
+			testutil.ToFloat64(metrics.M.TierRequests.WithLabelValues("6")),
+		}
+	}
+	before := counts()
+
+	pool.CheckAllHealth()
+	clients := pool.GetAvailableClients()
+	require.Equal(t, []string{"tier-primary"}, clientNames(clients))
+	assert.Equal(t, before, counts(), "selecting clients and health checks are not requests")
+
+	_, err = pool.QueryBalanceFromClients(context.Background(), clients, "0xabc", LatestBlock)
+	require.NoError(t, err)
+	assert.Equal(t, [2]float64{before[0] + 1, before[1]}, counts())
+}
+
+// sampleCount returns the number of observations of a histogram series
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+
+	var sample dto.Metric
+	require.NoError(t, observer.(prometheus.Metric).Write(&sample))
+	return sample.GetHistogram().GetSampleCount()
+}
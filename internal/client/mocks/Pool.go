@@ -3,9 +3,10 @@
 package mocks
 
 import (
-	context "context"
-
 	client "github.com/bersh/alluvial_test_1/internal/client"
+	config "github.com/bersh/alluvial_test_1/internal/config"
+
+	context "context"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -15,6 +16,126 @@ type Pool struct {
 	mock.Mock
 }
 
+// BatchCallFromAllClients provides a mock function with given fields: ctx, calls
+func (_m *Pool) BatchCallFromAllClients(ctx context.Context, calls []client.Call) ([]client.BatchResponse, error) {
+	ret := _m.Called(ctx, calls)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BatchCallFromAllClients")
+	}
+
+	var r0 []client.BatchResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []client.Call) ([]client.BatchResponse, error)); ok {
+		return rf(ctx, calls)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []client.Call) []client.BatchResponse); ok {
+		r0 = rf(ctx, calls)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]client.BatchResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []client.Call) error); ok {
+		r1 = rf(ctx, calls)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BatchCallFromClients provides a mock function with given fields: ctx, clients, calls
+func (_m *Pool) BatchCallFromClients(ctx context.Context, clients []*client.Client, calls []client.Call) ([]client.BatchResponse, error) {
+	ret := _m.Called(ctx, clients, calls)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BatchCallFromClients")
+	}
+
+	var r0 []client.BatchResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*client.Client, []client.Call) ([]client.BatchResponse, error)); ok {
+		return rf(ctx, clients, calls)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []*client.Client, []client.Call) []client.BatchResponse); ok {
+		r0 = rf(ctx, clients, calls)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]client.BatchResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []*client.Client, []client.Call) error); ok {
+		r1 = rf(ctx, clients, calls)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CallFromAllClients provides a mock function with given fields: ctx, method, params
+func (_m *Pool) CallFromAllClients(ctx context.Context, method string, params interface{}) ([]client.CallResponse, error) {
+	ret := _m.Called(ctx, method, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CallFromAllClients")
+	}
+
+	var r0 []client.CallResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) ([]client.CallResponse, error)); ok {
+		return rf(ctx, method, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) []client.CallResponse); ok {
+		r0 = rf(ctx, method, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]client.CallResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, interface{}) error); ok {
+		r1 = rf(ctx, method, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CallFromClients provides a mock function with given fields: ctx, clients, method, params
+func (_m *Pool) CallFromClients(ctx context.Context, clients []*client.Client, method string, params interface{}) ([]client.CallResponse, error) {
+	ret := _m.Called(ctx, clients, method, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CallFromClients")
+	}
+
+	var r0 []client.CallResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*client.Client, string, interface{}) ([]client.CallResponse, error)); ok {
+		return rf(ctx, clients, method, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []*client.Client, string, interface{}) []client.CallResponse); ok {
+		r0 = rf(ctx, clients, method, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]client.CallResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []*client.Client, string, interface{}) error); ok {
+		r1 = rf(ctx, clients, method, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CheckAllHealth provides a mock function with no fields
 func (_m *Pool) CheckAllHealth() {
 	_m.Called()
@@ -78,6 +199,36 @@ func (_m *Pool) GetAvailableClients() []*client.Client {
 	return r0
 }
 
+// GetClientsForBlock provides a mock function with given fields: block
+func (_m *Pool) GetClientsForBlock(block client.BlockParameter) ([]*client.Client, error) {
+	ret := _m.Called(block)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetClientsForBlock")
+	}
+
+	var r0 []*client.Client
+	var r1 error
+	if rf, ok := ret.Get(0).(func(client.BlockParameter) ([]*client.Client, error)); ok {
+		return rf(block)
+	}
+	if rf, ok := ret.Get(0).(func(client.BlockParameter) []*client.Client); ok {
+		r0 = rf(block)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*client.Client)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(client.BlockParameter) error); ok {
+		r1 = rf(block)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // HasAvailableClients provides a mock function with no fields
 func (_m *Pool) HasAvailableClients() bool {
 	ret := _m.Called()
@@ -96,9 +247,9 @@ func (_m *Pool) HasAvailableClients() bool {
 	return r0
 }
 
-// QueryBalanceFromAllClients provides a mock function with given fields: ctx, address, blockParam
-func (_m *Pool) QueryBalanceFromAllClients(ctx context.Context, address string, blockParam string) ([]client.BalanceResponse, error) {
-	ret := _m.Called(ctx, address, blockParam)
+// QueryBalanceFromAllClients provides a mock function with given fields: ctx, address, block
+func (_m *Pool) QueryBalanceFromAllClients(ctx context.Context, address string, block client.BlockParameter) ([]client.BalanceResponse, error) {
+	ret := _m.Called(ctx, address, block)
 
 	if len(ret) == 0 {
 		panic("no return value specified for QueryBalanceFromAllClients")
@@ -106,19 +257,79 @@ func (_m *Pool) QueryBalanceFromAllClients(ctx context.Context, address string,
 
 	var r0 []client.BalanceResponse
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]client.BalanceResponse, error)); ok {
-		return rf(ctx, address, blockParam)
+	if rf, ok := ret.Get(0).(func(context.Context, string, client.BlockParameter) ([]client.BalanceResponse, error)); ok {
+		return rf(ctx, address, block)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, client.BlockParameter) []client.BalanceResponse); ok {
+		r0 = rf(ctx, address, block)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]client.BalanceResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, client.BlockParameter) error); ok {
+		r1 = rf(ctx, address, block)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryBalanceFromClients provides a mock function with given fields: ctx, clients, address, block
+func (_m *Pool) QueryBalanceFromClients(ctx context.Context, clients []*client.Client, address string, block client.BlockParameter) ([]client.BalanceResponse, error) {
+	ret := _m.Called(ctx, clients, address, block)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryBalanceFromClients")
+	}
+
+	var r0 []client.BalanceResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*client.Client, string, client.BlockParameter) ([]client.BalanceResponse, error)); ok {
+		return rf(ctx, clients, address, block)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) []client.BalanceResponse); ok {
-		r0 = rf(ctx, address, blockParam)
+	if rf, ok := ret.Get(0).(func(context.Context, []*client.Client, string, client.BlockParameter) []client.BalanceResponse); ok {
+		r0 = rf(ctx, clients, address, block)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]client.BalanceResponse)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, address, blockParam)
+	if rf, ok := ret.Get(1).(func(context.Context, []*client.Client, string, client.BlockParameter) error); ok {
+		r1 = rf(ctx, clients, address, block)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryBalanceHedged provides a mock function with given fields: ctx, address, block, hedge
+func (_m *Pool) QueryBalanceHedged(ctx context.Context, address string, block client.BlockParameter, hedge config.HedgeConfig) (*client.BalanceResponse, error) {
+	ret := _m.Called(ctx, address, block, hedge)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryBalanceHedged")
+	}
+
+	var r0 *client.BalanceResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, client.BlockParameter, config.HedgeConfig) (*client.BalanceResponse, error)); ok {
+		return rf(ctx, address, block, hedge)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, client.BlockParameter, config.HedgeConfig) *client.BalanceResponse); ok {
+		r0 = rf(ctx, address, block, hedge)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*client.BalanceResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, client.BlockParameter, config.HedgeConfig) error); ok {
+		r1 = rf(ctx, address, block, hedge)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -126,6 +337,11 @@ func (_m *Pool) QueryBalanceFromAllClients(ctx context.Context, address string,
 	return r0, r1
 }
 
+// RecordConsensus provides a mock function with given fields: agreeing, disagreeing
+func (_m *Pool) RecordConsensus(agreeing []string, disagreeing []string) {
+	_m.Called(agreeing, disagreeing)
+}
+
 // SetClientAvailability provides a mock function with given fields: clientName, isAvailable
 func (_m *Pool) SetClientAvailability(clientName string, isAvailable bool) {
 	_m.Called(clientName, isAvailable)
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// defaultHealthHistorySize is the number of check results kept per client when the pool configuration does not set it
+const defaultHealthHistorySize = 20
+
+// HealthResult is the outcome of one health check of a client
+type HealthResult struct {
+	Time time.Time `json:"time"`
+	// Outcome is healthy, degraded, lagging, syncing, wrong_chain, rate_limited or failed
+	Outcome   string  `json:"outcome"`
+	Available bool    `json:"available"`
+	LatencyMs float64 `json:"latency_ms"`
+	HeadBlock uint64  `json:"head_block,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// healthHistory is a ring buffer of a client's most recent health check results, guarded by the pool lock
+type healthHistory struct {
+	results []HealthResult
+	pos     int
+	count   int
+}
+
+// add appends a result, dropping the oldest one once the history holds size results
+func (h *healthHistory) add(result HealthResult, size int) {
+	size = max(size, 1)
+	if len(h.results) != size {
+		// Resizing keeps the most recent results that fit
+		kept := h.list()
+		h.results = make([]HealthResult, size)
+		h.pos, h.count = 0, 0
+		for _, r := range kept[max(len(kept)-size, 0):] {
+			h.push(r)
+		}
+	}
+	h.push(result)
+}
+
+func (h *healthHistory) push(result HealthResult) {
+	h.results[h.pos] = result
+	h.pos = (h.pos + 1) % len(h.results)
+	h.count = min(h.count+1, len(h.results))
+}
+
+// list returns the results from oldest to newest
+func (h *healthHistory) list() []HealthResult {
+	results := make([]HealthResult, 0, h.count)
+	for i := h.count; i > 0; i-- {
+		results = append(results, h.results[(h.pos-i+len(h.results))%len(h.results)])
+	}
+	return results
+}
+
+// last returns the most recent result, if any
+func (h *healthHistory) last() (HealthResult, bool) {
+	if h.count == 0 {
+		return HealthResult{}, false
+	}
+	return h.results[(h.pos-1+len(h.results))%len(h.results)], true
+}
+
+// recordHealth adds the result of a check to the client's history and metrics
+func (p *PoolStruct) recordHealth(client *Client, result probeResult, outcome string, available bool) {
+	entry := HealthResult{
+		Time:      time.Now(),
+		Outcome:   outcome,
+		Available: available,
+		LatencyMs: float64(result.latency) / float64(time.Millisecond),
+		HeadBlock: result.head.Number,
+	}
+	err := result.err
+	if err == nil {
+		err = result.chainErr
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	size := p.config().Health.HistorySize
+	if size <= 0 {
+		size = defaultHealthHistorySize
+	}
+
+	p.clientsMutex.Lock()
+	client.health.add(entry, size)
+	p.clientsMutex.Unlock()
+
+	metrics.ObserveHealthCheck(client.Name, outcome, result.latency.Seconds())
+}
+
+// HealthHistory returns the most recent health check results of the named client, oldest first
+func (p *PoolStruct) HealthHistory(name string) ([]HealthResult, error) {
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+
+	client := p.findClient(name)
+	if client == nil {
+		return nil, fmt.Errorf("%w: %s", ErrClientNotFound, name)
+	}
+	return client.health.list(), nil
+}
+
+// LastHealthChecks returns the most recent health check result of every client checked at least once, by name
+func (p *PoolStruct) LastHealthChecks() map[string]HealthResult {
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+
+	results := make(map[string]HealthResult, len(p.clients))
+	for _, client := range p.clients {
+		if result, ok := client.health.last(); ok {
+			results[client.Name] = result
+		}
+	}
+	return results
+}
+
+// HealthMonitor runs the pool's health checks in the background, one round right away and then
+// one every interval. Intervals are jittered by the pool's health jitter ratio.
+type HealthMonitor struct {
+	pool     *PoolStruct
+	interval atomic.Int64
+	// reset reschedules the pending check after the interval changed
+	reset  chan struct{}
+	random func() float64
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewHealthMonitor creates a stopped health monitor for the pool
+func NewHealthMonitor(pool *PoolStruct, interval time.Duration) *HealthMonitor {
+	m := &HealthMonitor{
+		pool:   pool,
+		reset:  make(chan struct{}, 1),
+		random: rand.Float64,
+	}
+	m.interval.Store(int64(interval))
+	return m
+}
+
+// Start runs health checks until ctx is cancelled or Stop is called. Starting a running monitor does nothing.
+func (m *HealthMonitor) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel != nil {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	go m.run(ctx, m.done)
+}
+
+// Stop cancels the round of checks in progress and waits for the monitor to return.
+// Results of an interrupted round are discarded.
+func (m *HealthMonitor) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+	log.Println("Health monitor stopped")
+}
+
+// SetInterval changes the interval between checks, the pending check is rescheduled on the new interval
+func (m *HealthMonitor) SetInterval(interval time.Duration) {
+	m.interval.Store(int64(interval))
+	select {
+	case m.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (m *HealthMonitor) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		m.pool.checkAll(ctx)
+		if !m.wait(ctx) {
+			return
+		}
+	}
+}
+
+// wait sleeps until the next check is due and reports false when the monitor was stopped first
+func (m *HealthMonitor) wait(ctx context.Context) bool {
+	timer := time.NewTimer(m.nextDelay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case <-m.reset:
+			timer.Reset(m.nextDelay())
+		}
+	}
+}
+
+// nextDelay is the interval moved by up to the jitter ratio earlier or later
+func (m *HealthMonitor) nextDelay() time.Duration {
+	interval := time.Duration(m.interval.Load())
+	jitter := m.pool.config().Health.Jitter
+	return interval + time.Duration(float64(interval)*jitter*(2*m.random()-1))
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHistory_KeepsMostRecentResults(t *testing.T) {
+	var history healthHistory
+	_, ok := history.last()
+	assert.False(t, ok)
+
+	for block := uint64(1); block <= 5; block++ {
+		history.add(HealthResult{HeadBlock: block}, 3)
+	}
+	assert.Equal(t, []uint64{3, 4, 5}, headBlocks(history.list()))
+	last, ok := history.last()
+	require.True(t, ok)
+	assert.Equal(t, uint64(5), last.HeadBlock)
+
+	history.add(HealthResult{HeadBlock: 6}, 2)
+	assert.Equal(t, []uint64{5, 6}, headBlocks(history.list()), "shrinking keeps the most recent results")
+	history.add(HealthResult{HeadBlock: 7}, 4)
+	assert.Equal(t, []uint64{5, 6, 7}, headBlocks(history.list()))
+}
+
+func headBlocks(results []HealthResult) []uint64 {
+	blocks := make([]uint64, len(results))
+	for i, result := range results {
+		blocks[i] = result.HeadBlock
+	}
+	return blocks
+}
+
+func TestPoolStruct_CheckAllHealthLimitsConcurrency(t *testing.T) {
+	var probing, maxProbing atomic.Int64
+	var configs []config.ClientConfig
+	for i := 0; i < 6; i++ {
+		server := newHeadServer(t, 100, 1700000000)
+		server.Handle("eth_getBlockByNumber", func(json.RawMessage) (interface{}, *rpctest.Error) {
+			n := probing.Add(1)
+			defer probing.Add(-1)
+			for current := maxProbing.Load(); n > current && !maxProbing.CompareAndSwap(current, n); current = maxProbing.Load() {
+			}
+			time.Sleep(20 * time.Millisecond)
+			return map[string]string{"number": "0x64", "timestamp": "0x6553f100"}, nil
+		})
+		configs = append(configs, config.ClientConfig{URL: server.URL, Name: fmt.Sprintf("node-%d", i), Timeout: time.Second})
+	}
+
+	pool, err := NewPool(configs, config.PoolConfig{Health: config.HealthConfig{
+		Concurrency: 2,
+		HistorySize: 5,
+		MaxBlockLag: 10,
+		MaxTimeLag:  time.Minute,
+	}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pool.CheckAllHealth()
+	pool.CheckAllHealth()
+
+	assert.LessOrEqual(t, maxProbing.Load(), int64(2))
+	assert.Len(t, pool.GetAvailableClients(), 6)
+
+	history, err := pool.HealthHistory("node-0")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, healthHealthy, history[1].Outcome)
+	assert.True(t, history[1].Available)
+	assert.Equal(t, uint64(100), history[1].HeadBlock)
+	assert.Positive(t, history[1].LatencyMs)
+
+	assert.Len(t, pool.LastHealthChecks(), 6)
+
+	_, err = pool.HealthHistory("missing")
+	assert.ErrorIs(t, err, ErrClientNotFound)
+}
+
+func TestHealthMonitor_RunsOnIntervalUntilStopped(t *testing.T) {
+	server := newHeadServer(t, 100, 1700000000)
+	broken := rpctest.NewServer()
+	defer broken.Close()
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: server.URL, Name: "node", Timeout: time.Second},
+		{URL: broken.URL, Name: "broken", Timeout: time.Second},
+	}, config.PoolConfig{Health: config.HealthConfig{Jitter: 0.5, HistorySize: 10, MaxTimeLag: time.Minute}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	monitor := NewHealthMonitor(pool, time.Hour)
+	monitor.Start(context.Background())
+	defer monitor.Stop()
+
+	assert.Eventually(t, func() bool {
+		history, err := pool.HealthHistory("node")
+		return err == nil && len(history) == 1
+	}, time.Second, 5*time.Millisecond, "the first round runs right away")
+
+	monitor.SetInterval(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		history, err := pool.HealthHistory("node")
+		return err == nil && len(history) >= 3
+	}, time.Second, 5*time.Millisecond, "the pending round is rescheduled on the new interval")
+
+	history, err := pool.HealthHistory("broken")
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	assert.Equal(t, healthFailed, history[0].Outcome)
+	assert.NotEmpty(t, history[0].Error)
+
+	monitor.Stop()
+	history, err = pool.HealthHistory("node")
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	after, err := pool.HealthHistory("node")
+	require.NoError(t, err)
+	assert.Len(t, after, len(history), "no checks run once the monitor is stopped")
+}
+
+func TestHealthMonitor_StopInterruptsChecks(t *testing.T) {
+	server := newHeadServer(t, 100, 1700000000)
+	probed := make(chan struct{}, 1)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	server.Handle("eth_getBlockByNumber", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		select {
+		case probed <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil, nil
+	})
+
+	pool, err := NewPool([]config.ClientConfig{{URL: server.URL, Name: "hanging", Timeout: time.Minute}},
+		config.PoolConfig{Health: config.HealthConfig{Timeout: time.Minute}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	monitor := NewHealthMonitor(pool, time.Hour)
+	monitor.Start(context.Background())
+	<-probed
+
+	stopped := make(chan struct{})
+	go func() {
+		monitor.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not interrupt the running check")
+	}
+
+	history, err := pool.HealthHistory("hanging")
+	require.NoError(t, err)
+	assert.Empty(t, history, "interrupted checks are discarded")
+	assert.True(t, pool.HasAvailableClients(), "a shutdown does not mark clients unavailable")
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// What last changed a client's availability, the reason label of the client_availability metric
+const (
+	reasonHealthCheck = "health_check"
+	reasonPassive     = "passive"
+	reasonManual      = "manual"
+)
+
+// passiveHealth tracks the outcome of live requests to a client over a sliding window,
+// so that a client failing real traffic is taken out of rotation right away
+type passiveHealth struct {
+	mu                  sync.Mutex
+	consecutiveFailures int
+	outcomes            []bool // ring buffer of recent outcomes, true means failure
+	outcomePos          int
+	outcomeCount        int
+	failureCount        int
+}
+
+// record adds the outcome of a request and returns why the client should be marked unavailable,
+// empty while it stays within the thresholds
+func (h *passiveHealth) record(failed bool, cfg config.PassiveHealthConfig) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	windowSize := max(cfg.WindowSize, 1)
+	if len(h.outcomes) != windowSize {
+		h.outcomes = make([]bool, windowSize)
+		h.outcomePos, h.outcomeCount, h.failureCount = 0, 0, 0
+	}
+
+	if h.outcomeCount == len(h.outcomes) {
+		if h.outcomes[h.outcomePos] {
+			h.failureCount--
+		}
+	} else {
+		h.outcomeCount++
+	}
+	h.outcomes[h.outcomePos] = failed
+	h.outcomePos = (h.outcomePos + 1) % len(h.outcomes)
+
+	if !failed {
+		h.consecutiveFailures = 0
+		return ""
+	}
+	h.failureCount++
+	h.consecutiveFailures++
+
+	if cfg.ConsecutiveFailures > 0 && h.consecutiveFailures >= cfg.ConsecutiveFailures {
+		return fmt.Sprintf("%d consecutive failures", h.consecutiveFailures)
+	}
+	rate := float64(h.failureCount) / float64(h.outcomeCount)
+	if cfg.FailureRateThreshold > 0 && h.outcomeCount >= max(cfg.MinRequests, 1) && rate >= cfg.FailureRateThreshold {
+		return fmt.Sprintf("%d of the last %d requests failed", h.failureCount, h.outcomeCount)
+	}
+	return ""
+}
+
+// reset forgets the recorded outcomes, so a client coming back is judged on fresh traffic
+func (h *passiveHealth) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clear(h.outcomes)
+	h.consecutiveFailures, h.outcomePos, h.outcomeCount, h.failureCount = 0, 0, 0, 0
+}
+
+// newClient creates a client whose live requests feed the pool's passive health checks
+func (p *PoolStruct) newClient(cfg config.ClientConfig) (*Client, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.observe = func(failed bool) { p.observeRequest(client, failed) }
+	return client, nil
+}
+
+// observeRequest records the outcome of a live request and marks the client unavailable once
+// it crosses the passive health thresholds. It stays unavailable until a health check passes.
+func (p *PoolStruct) observeRequest(client *Client, failed bool) {
+	cause := client.passive.record(failed, p.config().Health.Passive)
+	if cause == "" {
+		return
+	}
+
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	if !client.IsAvailable {
+		return
+	}
+	log.Printf("Marking client %s unavailable from live traffic: %s\n", client.Name, cause)
+	client.setAvailability(false, reasonPassive)
+}
+
+// setAvailability updates the client's availability and the reason it changed, callers must hold the pool lock.
+// A client coming back starts with a clean passive health window.
+func (c *Client) setAvailability(isAvailable bool, reason string) {
+	if isAvailable && !c.IsAvailable {
+		c.passive.reset()
+	}
+	c.IsAvailable = isAvailable
+	c.availabilityReason = reason
+	metrics.SetClientAvailability(c.Name, isAvailable, reason)
+}
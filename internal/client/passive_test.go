@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassiveHealth_Thresholds(t *testing.T) {
+	cfg := config.PassiveHealthConfig{ConsecutiveFailures: 3, WindowSize: 4}
+
+	var health passiveHealth
+	assert.Empty(t, health.record(true, cfg))
+	assert.Empty(t, health.record(true, cfg))
+	assert.Empty(t, health.record(false, cfg), "a success ends the streak")
+	assert.Empty(t, health.record(true, cfg))
+	assert.Empty(t, health.record(true, cfg))
+	assert.Equal(t, "3 consecutive failures", health.record(true, cfg))
+
+	cfg = config.PassiveHealthConfig{FailureRateThreshold: 0.5, WindowSize: 4, MinRequests: 4}
+	health.reset()
+	assert.Empty(t, health.record(true, cfg))
+	assert.Empty(t, health.record(false, cfg))
+	assert.Empty(t, health.record(false, cfg))
+	assert.Equal(t, "2 of the last 4 requests failed", health.record(true, cfg))
+	assert.Empty(t, health.record(false, cfg), "successes do not mark the client down")
+
+	cfg = config.PassiveHealthConfig{WindowSize: 4, MinRequests: 1}
+	health.reset()
+	for i := 0; i < 10; i++ {
+		assert.Empty(t, health.record(true, cfg), "zero thresholds disable passive health checks")
+	}
+}
+
+func TestPoolStruct_PassiveHealthMarksFailingClientsDown(t *testing.T) {
+	var failing atomic.Bool
+	server := newHeadServer(t, 100, 1700000000)
+	server.Handle("eth_getBalance", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		if failing.Load() {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return "0x1", nil
+	})
+	backup := newHeadServer(t, 100, 1700000000)
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: server.URL, Name: "flaky", Timeout: 50 * time.Millisecond},
+		{URL: backup.URL, Name: "backup", Timeout: time.Second},
+	}, config.PoolConfig{Health: config.HealthConfig{
+		MaxTimeLag: time.Minute,
+		Passive:    config.PassiveHealthConfig{ConsecutiveFailures: 3, WindowSize: 10, MinRequests: 10},
+	}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pool.CheckAllHealth()
+	var flaky *Client
+	for _, client := range pool.GetAllClients() {
+		if client.Name == "flaky" {
+			flaky = client
+		}
+	}
+
+	_, err = flaky.QueryBalance(context.Background(), "0xabc", LatestBlock)
+	require.NoError(t, err)
+
+	failing.Store(true)
+	for i := 0; i < 2; i++ {
+		_, err = flaky.QueryBalance(context.Background(), "0xabc", LatestBlock)
+		require.Error(t, err)
+	}
+	assert.ElementsMatch(t, []string{"flaky", "backup"}, availableNames(pool))
+
+	_, err = flaky.QueryBalance(context.Background(), "0xabc", LatestBlock)
+	require.Error(t, err)
+	assert.Equal(t, []string{"backup"}, availableNames(pool), "the third failure in a row marks the client down right away")
+
+	status, err := pool.ClientStatus("flaky")
+	require.NoError(t, err)
+	assert.False(t, status.Available)
+	assert.Equal(t, reasonPassive, status.AvailabilityReason)
+
+	// Health checks decide when the client comes back
+	failing.Store(false)
+	pool.CheckAllHealth()
+	status, err = pool.ClientStatus("flaky")
+	require.NoError(t, err)
+	assert.True(t, status.Available)
+	assert.Equal(t, reasonHealthCheck, status.AvailabilityReason)
+
+	failing.Store(true)
+	_, err = flaky.QueryBalance(context.Background(), "0xabc", LatestBlock)
+	require.Error(t, err)
+	assert.Contains(t, availableNames(pool), "flaky", "failures before the client came back are forgotten")
+
+	pool.SetClientAvailability("flaky", false)
+	status, err = pool.ClientStatus("flaky")
+	require.NoError(t, err)
+	assert.Equal(t, reasonManual, status.AvailabilityReason)
+}
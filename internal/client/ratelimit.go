@@ -0,0 +1,170 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+const (
+	// defaultThrottleDuration is how long a client is skipped after a rate-limit
+	// response that does not say when to come back
+	defaultThrottleDuration = time.Second
+	// maxThrottleDuration caps the Retry-After values we honour
+	maxThrottleDuration = 5 * time.Minute
+)
+
+// ErrRateLimited is returned when a request is held back because the client is throttled
+var ErrRateLimited = errors.New("client is rate limited")
+
+// rateLimitRPCCodes are JSON-RPC error codes providers use to report an exceeded quota
+var rateLimitRPCCodes = map[int]bool{
+	-32005: true, // Infura and EIP-1474 "limit exceeded"
+	-32029: true, // "too many requests" used by several public endpoints
+	429:    true, // Alchemy mirrors the HTTP status in the error code
+}
+
+// rateLimiter is a token bucket limiting the requests we send to a client, combined
+// with the back-off window requested by the client itself through rate-limit responses
+type rateLimiter struct {
+	name  string
+	rate  float64
+	burst float64
+
+	mu             sync.Mutex
+	tokens         float64
+	last           time.Time
+	throttledUntil time.Time
+
+	now func() time.Time
+}
+
+// newRateLimiter creates a full bucket refilled at rps tokens per second, rps 0 disables the bucket
+func newRateLimiter(name string, rps float64, burst int) *rateLimiter {
+	metrics.SetClientThrottled(name, false)
+
+	l := &rateLimiter{
+		name:  name,
+		rate:  rps,
+		burst: float64(max(burst, 1)),
+		now:   time.Now,
+	}
+	l.tokens = l.burst
+	l.last = l.now()
+	return l
+}
+
+// Ready reports whether a request could be sent right now, without taking a token
+func (l *rateLimiter) Ready() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.throttled(now) {
+		return false
+	}
+	l.refill(now)
+	return l.rate <= 0 || l.tokens >= 1
+}
+
+// Allow takes a token for a request, it returns false while the client is throttled or the bucket is empty
+func (l *rateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.throttled(now) {
+		return false
+	}
+	if l.rate <= 0 {
+		return true
+	}
+
+	l.refill(now)
+	if l.tokens < 1 {
+		metrics.RecordClientThrottle(l.name, "local")
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Throttle stops requests to the client for the given duration after it reported a rate limit
+func (l *rateLimiter) Throttle(d time.Duration) {
+	if d <= 0 {
+		d = defaultThrottleDuration
+	}
+	d = min(d, maxThrottleDuration)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := l.now().Add(d); until.After(l.throttledUntil) {
+		l.throttledUntil = until
+	}
+	metrics.SetClientThrottled(l.name, true)
+	metrics.RecordClientThrottle(l.name, "upstream")
+}
+
+// throttled reports whether the client asked us to back off, callers must hold l.mu
+func (l *rateLimiter) throttled(now time.Time) bool {
+	if l.throttledUntil.IsZero() {
+		return false
+	}
+	if now.Before(l.throttledUntil) {
+		return true
+	}
+
+	l.throttledUntil = time.Time{}
+	metrics.SetClientThrottled(l.name, false)
+	return false
+}
+
+// refill adds the tokens accumulated since the last call, callers must hold l.mu
+func (l *rateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.tokens+elapsed.Seconds()*l.rate, l.burst)
+	}
+	l.last = now
+}
+
+// rateLimitDelay reports whether the client rejected a request for exceeding its quota,
+// with an HTTP 429 or a provider-specific JSON-RPC error, and how long it asked us to wait
+func rateLimitDelay(err error) (time.Duration, bool) {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+		return statusErr.RetryAfter, true
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return 0, rateLimitRPCCodes[rpcErr.Code] || strings.Contains(strings.ToLower(rpcErr.Message), "rate limit")
+	}
+
+	return 0, false
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
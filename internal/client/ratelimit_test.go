@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRateLimiter(rps float64, burst int) (*rateLimiter, *time.Time) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter("test", rps, burst)
+	limiter.now = func() time.Time { return now }
+	limiter.last = now
+	return limiter, &now
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	limiter, now := newTestRateLimiter(2, 2)
+
+	assert.True(t, limiter.Allow())
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Ready(), "the burst is used up")
+	assert.False(t, limiter.Allow())
+
+	*now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.Ready(), "one token is refilled after half a second")
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow())
+
+	*now = now.Add(time.Hour)
+	assert.True(t, limiter.Allow())
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow(), "the bucket never holds more than the burst")
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	limiter, _ := newTestRateLimiter(0, 1)
+	for i := 0; i < 100; i++ {
+		assert.True(t, limiter.Allow())
+	}
+}
+
+func TestRateLimiter_Throttle(t *testing.T) {
+	limiter, now := newTestRateLimiter(0, 1)
+
+	limiter.Throttle(3 * time.Second)
+	assert.False(t, limiter.Ready())
+	assert.False(t, limiter.Allow())
+
+	limiter.Throttle(time.Second)
+	*now = now.Add(2 * time.Second)
+	assert.False(t, limiter.Ready(), "a shorter throttle does not cut an earlier one short")
+
+	*now = now.Add(time.Second)
+	assert.True(t, limiter.Ready())
+	assert.True(t, limiter.Allow())
+
+	limiter.Throttle(0)
+	assert.False(t, limiter.Ready())
+	*now = now.Add(defaultThrottleDuration)
+	assert.True(t, limiter.Ready(), "a throttle without a delay lasts the default duration")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 7*time.Second, parseRetryAfter("7", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestRateLimitDelay(t *testing.T) {
+	delay, limited := rateLimitDelay(&HTTPStatusError{StatusCode: 429, RetryAfter: 5 * time.Second})
+	assert.True(t, limited)
+	assert.Equal(t, 5*time.Second, delay)
+
+	_, limited = rateLimitDelay(&RPCError{Code: -32005, Message: "daily request count exceeded"})
+	assert.True(t, limited)
+
+	_, limited = rateLimitDelay(&RPCError{Code: -32000, Message: "Rate limit reached"})
+	assert.True(t, limited)
+
+	_, limited = rateLimitDelay(&HTTPStatusError{StatusCode: 503})
+	assert.False(t, limited)
+
+	_, limited = rateLimitDelay(&RPCError{Code: CodeInvalidParams, Message: "invalid argument"})
+	assert.False(t, limited)
+
+	assert.Equal(t, "rate_limited", ErrorType(&HTTPStatusError{StatusCode: 429}))
+	assert.Equal(t, "throttled", ErrorType(ErrRateLimited))
+}
+
+func TestClient_ThrottledAfter429(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	pool, err := NewPool([]config.ClientConfig{{
+		URL:     server.URL,
+		Name:    "limited",
+		Timeout: time.Second,
+		Breaker: config.BreakerConfig{ConsecutiveFailures: 1, WindowSize: 1, Cooldown: time.Minute},
+		Retry:   config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 2},
+	}}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	// NewPool reads the chain id of its clients
+	startup := requests.Load()
+
+	client := pool.GetAllClients()[0]
+	_, err = client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+
+	var statusErr *HTTPStatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, time.Minute, statusErr.RetryAfter)
+	assert.Equal(t, startup+1, requests.Load(), "rate-limit responses are not retried")
+	assert.Equal(t, BreakerClosed, client.BreakerState(), "rate limits do not count as failures")
+
+	assert.Empty(t, pool.GetAvailableClients(), "throttled clients are skipped by selection")
+	assert.True(t, pool.HasAvailableClients(), "throttled clients are not marked unavailable")
+	assert.True(t, pool.CheckClientHealth(client))
+
+	_, err = client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, startup+2, requests.Load(), "only the health check reached the client")
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// Reconfigure applies a reloaded configuration to the pool while requests are in flight.
+// Clients whose configuration did not change are kept together with their health, breaker
+// and override state. New clients and clients whose configuration changed are created and
+// health checked before they take traffic, replacements inherit the override of the client
+// they replace. Clients added through the admin API are kept with their override unless the
+// configuration defines a client of the same name. Replaced and removed clients are drained and
+// closed in the background.
+// When a client cannot be created nothing is changed.
+func (p *PoolStruct) Reconfigure(clientConfigs []config.ClientConfig, cfg config.PoolConfig) error {
+	if len(clientConfigs) == 0 {
+		return fmt.Errorf("no client configurations provided")
+	}
+
+	p.mutateMutex.Lock()
+	defer p.mutateMutex.Unlock()
+
+	current := make(map[string]*Client)
+	for _, client := range p.GetAllClients() {
+		current[client.Name] = client
+	}
+
+	next := make([]*Client, 0, len(clientConfigs))
+	var created []*Client
+	for _, clientConfig := range clientConfigs {
+		if existing := current[clientConfig.Name]; existing != nil && reflect.DeepEqual(existing.cfg, clientConfig) {
+			next = append(next, existing)
+			continue
+		}
+
+		client, err := p.newClient(clientConfig)
+		if err != nil {
+			for _, client := range created {
+				client.Close()
+			}
+			return err
+		}
+		created = append(created, client)
+		next = append(next, client)
+	}
+
+	configured := make(map[string]bool, len(clientConfigs))
+	for _, clientConfig := range clientConfigs {
+		configured[clientConfig.Name] = true
+	}
+	kept := 0
+	for _, client := range p.GetAllClients() {
+		if client.runtime && !configured[client.Name] {
+			next = append(next, client)
+			kept++
+		}
+	}
+
+	// Nothing can fail from here on. The pool configuration is applied first,
+	// so new clients are probed against the new chain and lag settings.
+	p.cfg.Store(&cfg)
+
+	var wg sync.WaitGroup
+	for _, client := range created {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.CheckClientHealth(client)
+		}()
+	}
+	wg.Wait()
+
+	p.clientsMutex.Lock()
+	for _, client := range created {
+		if replaced := current[client.Name]; replaced != nil {
+			client.override = replaced.override
+		}
+	}
+	var retired []*Client
+	for _, client := range p.clients {
+		if !slices.Contains(next, client) {
+			retired = append(retired, client)
+		}
+	}
+	p.clients = next
+	p.clientsMutex.Unlock()
+
+	names := make(map[string]bool, len(next))
+	for _, client := range next {
+		names[client.Name] = true
+	}
+	for _, client := range retired {
+		go p.retire(client, !names[client.Name])
+	}
+
+	log.Printf("Pool reconfigured: %d clients, %d created, %d retired, %d added through the admin API kept\n",
+		len(next), len(created), len(retired), kept)
+	return nil
+}
+
+// retire closes a client that is no longer in the pool once its in-flight requests have finished.
+// The series of a removed client are deleted, a replaced client's name lives on in its replacement.
+func (p *PoolStruct) retire(client *Client, removed bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.fanOutTimeout())
+	defer cancel()
+
+	if err := client.waitIdle(ctx); err != nil {
+		log.Printf("Closing retired client %s with %d requests in flight: %v\n", client.Name, client.inFlight.Load(), err)
+	}
+	if err := client.Close(); err != nil {
+		log.Printf("Error closing client %s: %v\n", client.Name, err)
+	}
+	if removed {
+		metrics.RemoveClient(client.Name)
+	}
+}
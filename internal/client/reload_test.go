@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolStruct_Reconfigure(t *testing.T) {
+	kept := newHeadServer(t, 100, 1700000000)
+	changed := newHeadServer(t, 100, 1700000000)
+	removed := newHeadServer(t, 100, 1700000000)
+	added := newHeadServer(t, 100, 1700000000)
+
+	configs := []config.ClientConfig{
+		{URL: kept.URL, Name: "kept", Timeout: time.Second},
+		{URL: changed.URL, Name: "changed", Timeout: time.Second},
+		{URL: removed.URL, Name: "removed", Timeout: time.Second},
+	}
+	pool, err := NewPool(configs, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	before := map[string]*Client{}
+	for _, client := range pool.GetAllClients() {
+		before[client.Name] = client
+	}
+	_, err = pool.SetOverride("changed", OverrideCordoned)
+	require.NoError(t, err)
+
+	// A client that cannot be created rejects the whole reload
+	invalid := append(configs[:1:1], config.ClientConfig{
+		URL: added.URL, Name: "added", Timeout: time.Second,
+		Auth: config.AuthConfig{Type: config.AuthJWT, JWTSecretFile: "/nonexistent/jwt.hex"},
+	})
+	require.Error(t, pool.Reconfigure(invalid, config.PoolConfig{FanOutTimeout: time.Second}))
+	assert.Len(t, pool.GetAllClients(), 3)
+	assert.Equal(t, config.PoolConfig{}, pool.config(), "the pool configuration is left untouched")
+
+	err = pool.Reconfigure([]config.ClientConfig{
+		{URL: kept.URL, Name: "kept", Timeout: time.Second},
+		{URL: changed.URL, Name: "changed", Timeout: 2 * time.Second},
+		{URL: added.URL, Name: "added", Timeout: time.Second},
+	}, config.PoolConfig{FanOutTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, pool.fanOutTimeout())
+
+	after := map[string]*Client{}
+	for _, client := range pool.GetAllClients() {
+		after[client.Name] = client
+	}
+	require.Len(t, after, 3)
+	assert.Same(t, before["kept"], after["kept"], "unchanged clients keep their state")
+	assert.NotSame(t, before["changed"], after["changed"], "changed clients are replaced")
+	assert.NotContains(t, after, "removed")
+
+	status, err := pool.ClientStatus("changed")
+	require.NoError(t, err)
+	assert.Equal(t, OverrideCordoned, status.Override, "replacements inherit the override")
+
+	status, err = pool.ClientStatus("added")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), status.HeadBlock, "new clients are health checked before they take traffic")
+	assert.ElementsMatch(t, []string{"kept", "added"}, availableNames(pool))
+}
+
+func TestPoolStruct_ReconfigureKeepsClientsAddedAtRuntime(t *testing.T) {
+	file := newHeadServer(t, 100, 1700000000)
+	runtime := newHeadServer(t, 100, 1700000000)
+	shadowed := newHeadServer(t, 100, 1700000000)
+
+	configs := []config.ClientConfig{{URL: file.URL, Name: "file", Timeout: time.Second}}
+	pool, err := NewPool(configs, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	_, err = pool.AddClient(context.Background(), config.ClientConfig{URL: runtime.URL, Name: "runtime", Timeout: time.Second})
+	require.NoError(t, err)
+	_, err = pool.AddClient(context.Background(), config.ClientConfig{URL: shadowed.URL, Name: "shadowed", Timeout: time.Second})
+	require.NoError(t, err)
+	_, err = pool.SetOverride("runtime", OverrideCordoned)
+	require.NoError(t, err)
+
+	before := map[string]*Client{}
+	for _, client := range pool.GetAllClients() {
+		before[client.Name] = client
+	}
+
+	// The file now defines a client named like one added at runtime, the file wins
+	require.NoError(t, pool.Reconfigure(append(configs, config.ClientConfig{URL: file.URL, Name: "shadowed", Timeout: time.Second}),
+		config.PoolConfig{}))
+
+	after := map[string]*Client{}
+	for _, client := range pool.GetAllClients() {
+		after[client.Name] = client
+	}
+	require.Len(t, after, 3)
+	assert.Same(t, before["runtime"], after["runtime"], "clients added at runtime survive a reload")
+	assert.NotSame(t, before["shadowed"], after["shadowed"])
+	assert.Equal(t, file.URL, after["shadowed"].URL)
+
+	status, err := pool.ClientStatus("runtime")
+	require.NoError(t, err)
+	assert.Equal(t, OverrideCordoned, status.Override, "and keep their override")
+
+	// Removing a client added at runtime still takes it out for good
+	require.NoError(t, pool.RemoveClient(context.Background(), "runtime"))
+	require.NoError(t, pool.Reconfigure(configs, config.PoolConfig{}))
+	assert.ElementsMatch(t, []string{"file"}, clientNames(pool.GetAllClients()))
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"log"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// reputation tracks how often a client agreed with the consensus result over a sliding window of votes.
+// It is guarded by the pool lock.
+type reputation struct {
+	votes         []bool // ring buffer of recent votes, true means the client was outvoted
+	votePos       int
+	voteCount     int
+	disagreements int
+
+	// ejected is set while the client is taken out of rotation for disagreeing with consensus
+	ejected bool
+	// ejections counts ejections in a row, each one lasts longer than the one before
+	ejections int
+	// cleanVotes counts votes since the last ejection, a full clean window forgives one ejection
+	cleanVotes int
+}
+
+// record adds a vote to the window, resizing it when the configured window size changed
+func (r *reputation) record(disagreed bool, windowSize int) {
+	windowSize = max(windowSize, 1)
+	if len(r.votes) != windowSize {
+		r.votes = make([]bool, windowSize)
+		r.resetVotes()
+	}
+
+	if r.voteCount == len(r.votes) {
+		if r.votes[r.votePos] {
+			r.disagreements--
+		}
+	} else {
+		r.voteCount++
+	}
+	r.votes[r.votePos] = disagreed
+	r.votePos = (r.votePos + 1) % len(r.votes)
+	if disagreed {
+		r.disagreements++
+	}
+
+	r.cleanVotes++
+	if r.cleanVotes >= windowSize && r.ejections > 0 {
+		r.ejections--
+		r.cleanVotes = 0
+	}
+}
+
+// resetVotes empties the window
+func (r *reputation) resetVotes() {
+	clear(r.votes)
+	r.votePos, r.voteCount, r.disagreements = 0, 0, 0
+}
+
+// score is the ratio of votes in the window the client agreed with consensus, 1 without votes
+func (r *reputation) score() float64 {
+	if r.voteCount == 0 {
+		return 1
+	}
+	return 1 - float64(r.disagreements)/float64(r.voteCount)
+}
+
+// outlier reports whether the client's disagreement rate over the window reached the threshold
+func (r *reputation) outlier(cfg config.ReputationConfig) bool {
+	if cfg.DisagreementThreshold <= 0 || r.ejected || r.voteCount < max(cfg.MinVotes, 1) {
+		return false
+	}
+	return 1-r.score() >= cfg.DisagreementThreshold
+}
+
+// RecordConsensus records the outcome of a consensus vote: the agreeing clients returned the
+// consensus result, the disagreeing ones were outvoted. A client whose disagreement rate over
+// the window reaches the threshold is ejected, Envoy outlier detection style: it gets no traffic
+// for the base ejection time multiplied by its number of ejections in a row, and is reinstated
+// with a clean window afterwards. No more than the maximum ejection percentage of the pool is
+// ejected at the same time.
+func (p *PoolStruct) RecordConsensus(agreeing, disagreeing []string) {
+	cfg := p.config().Reputation
+
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	for _, vote := range []struct {
+		names     []string
+		disagreed bool
+	}{{agreeing, false}, {disagreeing, true}} {
+		for _, name := range vote.names {
+			client := p.findClient(name)
+			if client == nil {
+				continue
+			}
+			client.reputation.record(vote.disagreed, cfg.WindowSize)
+			metrics.SetClientReputation(name, client.reputation.score())
+
+			if vote.disagreed && client.reputation.outlier(cfg) {
+				p.eject(client, cfg)
+			}
+		}
+	}
+}
+
+// eject takes a client out of rotation unless that would exceed the ejection cap, callers must hold the pool lock
+func (p *PoolStruct) eject(client *Client, cfg config.ReputationConfig) {
+	ejected := 0
+	for _, c := range p.clients {
+		if c.reputation.ejected {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > len(p.clients)*cfg.MaxEjectionPercent {
+		log.Printf("Client %s disagrees with consensus in %.0f%% of votes, not ejecting it: %d of %d clients are ejected already\n",
+			client.Name, 100*(1-client.reputation.score()), ejected, len(p.clients))
+		return
+	}
+
+	rep := &client.reputation
+	rep.ejections++
+	duration := min(cfg.BaseEjectionTime*time.Duration(rep.ejections), cfg.MaxEjectionTime)
+	log.Printf("Ejecting client %s for %v, it disagreed with consensus in %.0f%% of the last %d votes\n",
+		client.Name, duration, 100*(1-rep.score()), rep.voteCount)
+
+	rep.ejected = true
+	rep.cleanVotes = 0
+	metrics.RecordClientEjection(client.Name)
+	metrics.SetClientEjected(client.Name, true)
+
+	time.AfterFunc(duration, func() { p.reinstate(client) })
+}
+
+// reinstate puts an ejected client back into rotation
+func (p *PoolStruct) reinstate(client *Client) {
+	p.clientsMutex.Lock()
+	defer p.clientsMutex.Unlock()
+
+	if !client.reputation.ejected {
+		return
+	}
+	// The window starts over, so the client is judged on fresh votes once it is back
+	client.reputation.ejected = false
+	client.reputation.resetVotes()
+	metrics.SetClientEjected(client.Name, false)
+	metrics.SetClientReputation(client.Name, client.reputation.score())
+	log.Printf("Reinstating client %s after its ejection\n", client.Name)
+}
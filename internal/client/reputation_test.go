@@ -0,0 +1,90 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputation_SlidingWindow(t *testing.T) {
+	var rep reputation
+	assert.Equal(t, 1.0, rep.score(), "clients without votes have a perfect score")
+
+	for _, disagreed := range []bool{true, false, false, true} {
+		rep.record(disagreed, 4)
+	}
+	assert.Equal(t, 0.5, rep.score())
+
+	rep.record(false, 4)
+	rep.record(false, 4)
+	assert.Equal(t, 0.75, rep.score(), "the oldest votes leave the window")
+
+	cfg := config.ReputationConfig{DisagreementThreshold: 0.25, MinVotes: 4}
+	assert.True(t, rep.outlier(cfg))
+	cfg.MinVotes = 5
+	assert.False(t, rep.outlier(cfg), "the rate is only evaluated with enough votes")
+	cfg = config.ReputationConfig{MinVotes: 1}
+	assert.False(t, rep.outlier(cfg), "a zero threshold disables ejection")
+}
+
+func TestPoolStruct_EjectsClientsDisagreeingWithConsensus(t *testing.T) {
+	servers := map[string]string{}
+	var configs []config.ClientConfig
+	for _, name := range []string{"honest-1", "honest-2", "liar", "flaky"} {
+		servers[name] = newHeadServer(t, 100, 1700000000).URL
+		configs = append(configs, config.ClientConfig{URL: servers[name], Name: name, Timeout: time.Second})
+	}
+
+	pool, err := NewPool(configs, config.PoolConfig{
+		Reputation: config.ReputationConfig{
+			DisagreementThreshold: 0.5,
+			WindowSize:            10,
+			MinVotes:              4,
+			BaseEjectionTime:      50 * time.Millisecond,
+			MaxEjectionTime:       time.Second,
+			MaxEjectionPercent:    25,
+		},
+	})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		pool.RecordConsensus([]string{"honest-1", "honest-2"}, []string{"liar", "flaky"})
+	}
+	assert.ElementsMatch(t, []string{"honest-1", "honest-2", "liar", "flaky"}, availableNames(pool), "too few votes to judge")
+
+	pool.RecordConsensus([]string{"honest-1", "honest-2"}, []string{"liar", "flaky"})
+	assert.ElementsMatch(t, []string{"honest-1", "honest-2", "flaky"}, availableNames(pool),
+		"only a quarter of the pool may be ejected at once")
+
+	status, err := pool.ClientStatus("liar")
+	require.NoError(t, err)
+	assert.True(t, status.Ejected)
+	assert.Equal(t, 0.0, status.Reputation)
+
+	// An override wins over the ejection
+	_, err = pool.SetOverride("liar", OverrideEnabled)
+	require.NoError(t, err)
+	assert.Contains(t, availableNames(pool), "liar")
+	_, err = pool.SetOverride("liar", OverrideNone)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		status, err := pool.ClientStatus("liar")
+		return err == nil && !status.Ejected && status.Reputation == 1
+	}, time.Second, 5*time.Millisecond, "ejected clients are reinstated with a clean window")
+
+	// A second ejection in a row lasts twice as long
+	for i := 0; i < 4; i++ {
+		pool.RecordConsensus([]string{"honest-1", "honest-2", "flaky"}, []string{"liar"})
+	}
+	start := time.Now()
+	assert.NotContains(t, availableNames(pool), "liar")
+	assert.Eventually(t, func() bool {
+		return len(availableNames(pool)) == 4
+	}, time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
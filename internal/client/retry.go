@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// RetryPolicy retries transient upstream failures with exponential backoff and jitter
+type RetryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	jitter         float64
+
+	random func() float64
+}
+
+// NewRetryPolicy creates a retry policy, a policy with one attempt never retries
+func NewRetryPolicy(cfg config.RetryConfig) *RetryPolicy {
+	policy := &RetryPolicy{
+		maxAttempts:    max(cfg.MaxAttempts, 1),
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		multiplier:     cfg.Multiplier,
+		jitter:         cfg.Jitter,
+		random:         rand.Float64,
+	}
+	if policy.multiplier < 1 {
+		policy.multiplier = 1
+	}
+	return policy
+}
+
+// Do calls attempt until it succeeds, fails with an error that is not retryable,
+// or the attempts run out. It gives up early when the next backoff would not
+// finish before the context deadline and returns the last error.
+func (p *RetryPolicy) Do(ctx context.Context, clientName string, attempt func(ctx context.Context) error) error {
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil || n >= p.maxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		delay := p.backoff(n)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		metrics.RecordClientRetry(clientName, ErrorType(err))
+	}
+}
+
+// backoff returns the delay before the retry following the given attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if p.maxBackoff > 0 {
+		delay = math.Min(delay, float64(p.maxBackoff))
+	}
+
+	// Jitter spreads retries from concurrent requests by shortening each delay by up to the jitter ratio
+	delay -= delay * p.jitter * p.random()
+
+	return time.Duration(delay)
+}
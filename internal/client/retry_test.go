@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"Transport failure", &TransportError{Err: errors.New("connection refused")}, true},
+		{"Timeout", &TransportError{Err: context.DeadlineExceeded}, true},
+		{"Cancelled by caller", &TransportError{Err: context.Canceled}, false},
+		{"Too many requests", &HTTPStatusError{StatusCode: 429}, false},
+		{"Bad gateway", &HTTPStatusError{StatusCode: 502}, true},
+		{"Unauthorized", &HTTPStatusError{StatusCode: 401}, false},
+		{"Malformed response", &ParseError{Err: errors.New("unexpected EOF")}, false},
+		{"Invalid envelope", &ProtocolError{Reason: "response has no id"}, false},
+		{"Invalid params", &RPCError{Code: CodeInvalidParams, Message: "invalid argument"}, false},
+		{"Limit exceeded", &RPCError{Code: -32005, Message: "limit exceeded"}, false},
+		{"Throttled locally", fmt.Errorf("client a: %w", ErrRateLimited), false},
+		{"Wrapped RPC error", fmt.Errorf("call failed: %w", &RPCError{Code: CodeInternalError}), true},
+		{"Circuit open", fmt.Errorf("client a: %w", ErrCircuitOpen), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.retryable, IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := NewRetryPolicy(config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+	})
+
+	t.Run("Retries transient failures until success", func(t *testing.T) {
+		attempts := 0
+		err := policy.Do(context.Background(), "test", func(context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return &HTTPStatusError{StatusCode: 503}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("Stops after the last attempt", func(t *testing.T) {
+		attempts := 0
+		err := policy.Do(context.Background(), "test", func(context.Context) error {
+			attempts++
+			return &TransportError{Err: errors.New("connection reset")}
+		})
+		var transportErr *TransportError
+		assert.ErrorAs(t, err, &transportErr)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("Does not retry deterministic errors", func(t *testing.T) {
+		attempts := 0
+		err := policy.Do(context.Background(), "test", func(context.Context) error {
+			attempts++
+			return &RPCError{Code: CodeInvalidParams}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("Gives up when the backoff outlives the deadline", func(t *testing.T) {
+		slowPolicy := NewRetryPolicy(config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Hour})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		err := slowPolicy.Do(ctx, "test", func(context.Context) error {
+			attempts++
+			return &HTTPStatusError{StatusCode: 503}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
@@ -0,0 +1,289 @@
+// Package rpctest provides a fake Ethereum JSON-RPC provider for tests.
+// A single Server answers plain HTTP POST requests as well as WebSocket
+// connections on the same address, so it can stand in for either kind of upstream.
+// It can additionally listen on a Unix socket to stand in for a node's IPC endpoint.
+package rpctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// Error is a JSON-RPC error returned by a handler
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// HandlerFunc answers a single JSON-RPC method call
+type HandlerFunc func(params json.RawMessage) (interface{}, *Error)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Server is a fake JSON-RPC provider
+type Server struct {
+	*httptest.Server
+
+	mu         sync.RWMutex
+	handlers   map[string]HandlerFunc
+	conns      map[io.Closer]struct{}
+	listeners  []net.Listener
+	lastHeader http.Header
+
+	upgrader    websocket.Upgrader
+	requests    atomic.Int64
+	connections atomic.Int64
+}
+
+// NewServer starts a fake provider. Methods without a handler return a method-not-found error.
+func NewServer() *Server {
+	s := &Server{
+		handlers: make(map[string]HandlerFunc),
+		conns:    make(map[io.Closer]struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle registers the handler for a method
+func (s *Server) Handle(method string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// HandleResult registers a handler that always returns the given result
+func (s *Server) HandleResult(method string, result interface{}) {
+	s.Handle(method, func(json.RawMessage) (interface{}, *Error) {
+		return result, nil
+	})
+}
+
+// HandleHead makes the server report an in-sync node whose latest block has the given number and timestamp
+func (s *Server) HandleHead(number, timestamp uint64) {
+	s.HandleResult("eth_getBlockByNumber", map[string]string{
+		"number":    fmt.Sprintf("0x%x", number),
+		"timestamp": fmt.Sprintf("0x%x", timestamp),
+	})
+	s.HandleResult("eth_syncing", false)
+}
+
+// WSURL returns the WebSocket address of the server
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// Requests returns the number of JSON-RPC payloads received, a batch counts once
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+// Connections returns the number of WebSocket and IPC connections accepted so far
+func (s *Server) Connections() int64 {
+	return s.connections.Load()
+}
+
+// LastHeader returns the headers of the last HTTP request or WebSocket handshake
+func (s *Server) LastHeader() http.Header {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastHeader
+}
+
+// DropConnections closes all open WebSocket and IPC connections
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+// Close stops listening, drops WebSocket and IPC connections and shuts the server down
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	s.listeners = nil
+	s.mu.Unlock()
+
+	s.DropConnections()
+	s.Server.Close()
+}
+
+// ListenIPC additionally serves JSON-RPC on a Unix socket at path, framed as a stream of
+// JSON values like a geth IPC endpoint. Clients reach it at "ipc://" + path.
+func (s *Server) ListenIPC(path string) error {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveIPC(conn)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) serveIPC(conn net.Conn) {
+	s.connections.Add(1)
+	s.track(conn)
+	defer s.untrack(conn)
+
+	var writeMu sync.Mutex
+	decoder := json.NewDecoder(conn)
+	for {
+		var message json.RawMessage
+		if err := decoder.Decode(&message); err != nil {
+			return
+		}
+		go func() {
+			reply := s.process(message)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			conn.Write(append(reply, '\n'))
+		}()
+	}
+}
+
+// track registers an open connection so that it can be dropped
+func (s *Server) track(conn io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[conn] = struct{}{}
+}
+
+func (s *Server) untrack(conn io.Closer) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.lastHeader = r.Header.Clone()
+	s.mu.Unlock()
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.process(body))
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.connections.Add(1)
+	s.track(conn)
+	defer s.untrack(conn)
+
+	var writeMu sync.Mutex
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		// Answer concurrently so responses can arrive out of order, like a real provider
+		go func() {
+			reply := s.process(message)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			conn.WriteMessage(websocket.TextMessage, reply)
+		}()
+	}
+}
+
+// process answers a single request or a batch payload
+func (s *Server) process(body []byte) []byte {
+	s.requests.Add(1)
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []request
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return s.encode(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: -32700, Message: "parse error"}})
+		}
+		responses := make([]response, len(batch))
+		for i, req := range batch {
+			responses[i] = s.call(req)
+		}
+		return s.encode(responses)
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return s.encode(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: -32700, Message: "parse error"}})
+	}
+	return s.encode(s.call(req))
+}
+
+func (s *Server) call(req request) response {
+	s.mu.RLock()
+	handler, ok := s.handlers[req.Method]
+	s.mu.RUnlock()
+
+	if !ok {
+		return response{JSONRPC: "2.0", ID: req.ID, Error: &Error{Code: -32601, Message: fmt.Sprintf("method %s not found", req.Method)}}
+	}
+
+	result, rpcErr := handler(req.Params)
+	if rpcErr != nil {
+		return response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	// A nil result is encoded as null, which is a valid result and must not be omitted
+	return response{JSONRPC: "2.0", ID: req.ID, Result: s.encode(result)}
+}
+
+func (s *Server) encode(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("rpctest: encoding response: %v", err))
+	}
+	return data
+}
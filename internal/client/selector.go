@@ -0,0 +1,136 @@
+package client
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync/atomic"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// Selector picks the clients a request is sent to from the available ones
+type Selector interface {
+	Select(clients []*Client) []*Client
+}
+
+// NewSelector creates the selector for a routing strategy
+func NewSelector(cfg config.RoutingConfig) (Selector, error) {
+	count := max(cfg.Count, 1)
+
+	switch cfg.Strategy {
+	case "", config.RoutingFanOut:
+		return FanOutSelector{}, nil
+	case config.RoutingRoundRobin:
+		return &RoundRobinSelector{count: count}, nil
+	case config.RoutingLeastLatency:
+		return LeastLatencySelector{count: count}, nil
+	case config.RoutingWeightedRandom:
+		return WeightedRandomSelector{count: count}, nil
+	case config.RoutingRandomN:
+		return RandomNSelector{count: count}, nil
+	default:
+		return nil, fmt.Errorf("unknown routing strategy %q", cfg.Strategy)
+	}
+}
+
+// FanOutSelector sends every request to all available clients
+type FanOutSelector struct{}
+
+func (FanOutSelector) Select(clients []*Client) []*Client {
+	return clients
+}
+
+// RoundRobinSelector rotates through the available clients, sending each request to the next count clients
+type RoundRobinSelector struct {
+	count int
+	next  atomic.Uint64
+}
+
+func (s *RoundRobinSelector) Select(clients []*Client) []*Client {
+	if len(clients) <= s.count {
+		return clients
+	}
+
+	start := int(s.next.Add(1)-1) % len(clients)
+	selected := make([]*Client, 0, s.count)
+	for i := 0; i < s.count; i++ {
+		selected = append(selected, clients[(start+i)%len(clients)])
+	}
+	return selected
+}
+
+// LeastLatencySelector sends each request to the count clients with the lowest moving average latency.
+// Clients without latency data are treated as fastest so that they get measured.
+type LeastLatencySelector struct {
+	count int
+}
+
+func (s LeastLatencySelector) Select(clients []*Client) []*Client {
+	if len(clients) <= s.count {
+		return clients
+	}
+
+	sorted := slices.Clone(clients)
+	slices.SortStableFunc(sorted, func(a, b *Client) int {
+		la, lb := a.Latency(), b.Latency()
+		switch {
+		case la < lb:
+			return -1
+		case la > lb:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return sorted[:s.count]
+}
+
+// WeightedRandomSelector sends each request to count clients drawn at random
+// without replacement, in proportion to their configured weights
+type WeightedRandomSelector struct {
+	count int
+}
+
+func (s WeightedRandomSelector) Select(clients []*Client) []*Client {
+	if len(clients) <= s.count {
+		return clients
+	}
+
+	candidates := slices.Clone(clients)
+	selected := make([]*Client, 0, s.count)
+	for len(selected) < s.count {
+		total := 0
+		for _, client := range candidates {
+			total += max(client.Weight, 1)
+		}
+
+		pick := rand.IntN(total)
+		for i, client := range candidates {
+			pick -= max(client.Weight, 1)
+			if pick < 0 {
+				selected = append(selected, client)
+				candidates = slices.Delete(candidates, i, i+1)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// RandomNSelector sends each request to count clients picked uniformly at random
+type RandomNSelector struct {
+	count int
+}
+
+func (s RandomNSelector) Select(clients []*Client) []*Client {
+	if len(clients) <= s.count {
+		return clients
+	}
+
+	shuffled := slices.Clone(clients)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled[:s.count]
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClients(names ...string) []*Client {
+	clients := make([]*Client, len(names))
+	for i, name := range names {
+		clients[i] = &Client{Name: name, IsAvailable: true, Weight: 1, latency: newLatencyTracker()}
+	}
+	return clients
+}
+
+func clientNames(clients []*Client) []string {
+	names := make([]string, len(clients))
+	for i, client := range clients {
+		names[i] = client.Name
+	}
+	return names
+}
+
+func TestNewSelector(t *testing.T) {
+	for _, strategy := range []string{"", config.RoutingFanOut, config.RoutingRoundRobin,
+		config.RoutingLeastLatency, config.RoutingWeightedRandom, config.RoutingRandomN} {
+		selector, err := NewSelector(config.RoutingConfig{Strategy: strategy, Count: 1})
+		assert.NoError(t, err, strategy)
+		assert.NotNil(t, selector, strategy)
+	}
+
+	_, err := NewSelector(config.RoutingConfig{Strategy: "bogus"})
+	assert.Error(t, err)
+}
+
+func TestFanOutSelector(t *testing.T) {
+	clients := newTestClients("a", "b", "c")
+	assert.Equal(t, clients, FanOutSelector{}.Select(clients))
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	clients := newTestClients("a", "b", "c")
+	selector := &RoundRobinSelector{count: 2}
+
+	assert.Equal(t, []string{"a", "b"}, clientNames(selector.Select(clients)))
+	assert.Equal(t, []string{"b", "c"}, clientNames(selector.Select(clients)))
+	assert.Equal(t, []string{"c", "a"}, clientNames(selector.Select(clients)))
+	assert.Equal(t, []string{"a", "b"}, clientNames(selector.Select(clients)))
+}
+
+func TestLeastLatencySelector(t *testing.T) {
+	clients := newTestClients("slow", "fast", "new")
+	clients[0].latency.Observe(300 * time.Millisecond)
+	clients[1].latency.Observe(20 * time.Millisecond)
+
+	selector := LeastLatencySelector{count: 2}
+	assert.Equal(t, []string{"new", "fast"}, clientNames(selector.Select(clients)))
+}
+
+func TestWeightedRandomSelector(t *testing.T) {
+	clients := newTestClients("heavy", "light")
+	clients[0].Weight = 99
+
+	selector := WeightedRandomSelector{count: 1}
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		selected := selector.Select(clients)
+		require.Len(t, selected, 1)
+		counts[selected[0].Name]++
+	}
+	assert.Greater(t, counts["heavy"], 900)
+
+	both := WeightedRandomSelector{count: 2}.Select(clients)
+	assert.ElementsMatch(t, []string{"heavy", "light"}, clientNames(both))
+}
+
+func TestRandomNSelector(t *testing.T) {
+	clients := newTestClients("a", "b", "c", "d")
+	selected := RandomNSelector{count: 2}.Select(clients)
+
+	require.Len(t, selected, 2)
+	assert.NotEqual(t, selected[0].Name, selected[1].Name)
+	assert.Len(t, clients, 4)
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// defaultStateWindow is the number of recent blocks whose state a full node keeps, as geth does by default
+	defaultStateWindow = 128
+	// stateDetectTimeout bounds the search for the oldest block a client still has state for
+	stateDetectTimeout = 30 * time.Second
+)
+
+// ErrNoStateHistory is returned for queries at a block older than the state kept by every client in the pool
+var ErrNoStateHistory = errors.New("no client keeps the state of this block")
+
+// missingStateMessages identify RPC errors of nodes that pruned the state of the requested block
+var missingStateMessages = []string{
+	"missing trie node",
+	"state is not available",
+	"state not available",
+	"historical state",
+	"state histories",
+	"pruned",
+}
+
+// StateHistory describes how far back a client serves state
+type StateHistory struct {
+	// Known is false until the node kind was declared or detected, such clients get every query
+	Known   bool
+	Archive bool
+	// Window is the number of blocks behind its head for which a full node serves state
+	Window uint64
+}
+
+func (h StateHistory) String() string {
+	switch {
+	case !h.Known:
+		return "unknown"
+	case h.Archive:
+		return config.NodeKindArchive
+	default:
+		return config.NodeKindFull
+	}
+}
+
+// declaredStateHistory returns the state history declared in a client's configuration
+func declaredStateHistory(cfg config.ClientConfig) StateHistory {
+	switch cfg.NodeKind {
+	case config.NodeKindArchive:
+		return StateHistory{Known: true, Archive: true}
+	case config.NodeKindFull:
+		return StateHistory{Known: true, Window: stateWindowOrDefault(cfg.StateWindow)}
+	default:
+		return StateHistory{}
+	}
+}
+
+func stateWindowOrDefault(window uint64) uint64 {
+	if window > 0 {
+		return window
+	}
+	return defaultStateWindow
+}
+
+// hasState reports whether the client can serve state queries at the block, callers must hold the pool lock.
+// Clients with unknown history, tags, hashes and blocks beyond the client's head are given the benefit of the doubt.
+func (c *Client) hasState(block BlockParameter) bool {
+	number, ok := block.Number()
+	if !ok || !c.stateHistory.Known || c.stateHistory.Archive {
+		return true
+	}
+
+	head := c.Head().Number
+	return head == 0 || number+c.stateHistory.Window >= head
+}
+
+// GetClientsForBlock returns the available clients that can serve state queries at the block,
+// picked from tiers like GetAvailableClients. Full nodes are skipped for blocks older than their
+// state window, so historical queries only reach archive nodes. It fails with ErrNoStateHistory
+// when no client in the pool keeps the state of the block, available or not, and with
+// ErrNoClientsAvailable when the clients that keep it are all unavailable.
+func (p *PoolStruct) GetClientsForBlock(block BlockParameter) ([]*Client, error) {
+	clients := p.availableClients(func(client *Client) bool { return client.hasState(block) })
+	if len(clients) > 0 {
+		return clients, nil
+	}
+
+	p.clientsMutex.RLock()
+	defer p.clientsMutex.RUnlock()
+
+	for _, client := range p.clients {
+		if client.hasState(block) {
+			return nil, fmt.Errorf("%w for block %s, the clients keeping its state are unavailable", ErrNoClientsAvailable, block)
+		}
+	}
+	return nil, fmt.Errorf("%w: block %s is older than the state kept by the configured clients, an archive node is required", ErrNoStateHistory, block)
+}
+
+// detectStateHistory finds out whether a client of undeclared node kind is an archive node and, if not,
+// how far back it serves state. Nothing is recorded when the search is inconclusive, e.g. while the
+// chain is younger than the default state window or the client fails, so the next health check retries.
+func (p *PoolStruct) detectStateHistory(ctx context.Context, client *Client, head uint64) {
+	p.clientsMutex.RLock()
+	known := client.stateHistory.Known
+	p.clientsMutex.RUnlock()
+	if known || head <= defaultStateWindow {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stateDetectTimeout)
+	defer cancel()
+
+	history, err := searchStateHistory(ctx, client, head)
+	if err != nil {
+		log.Printf("Could not detect the state history of client %s: %v\n", client.Name, err)
+		return
+	}
+
+	p.clientsMutex.Lock()
+	client.stateHistory = history
+	p.clientsMutex.Unlock()
+
+	if history.Archive {
+		log.Printf("Client %s is an archive node\n", client.Name)
+	} else {
+		log.Printf("Client %s is a full node serving state for the last %d blocks\n", client.Name, history.Window)
+	}
+}
+
+// searchStateHistory probes the state of the first block, which only archive nodes keep, and
+// otherwise bisects for the oldest block whose state the client still serves
+func searchStateHistory(ctx context.Context, client *Client, head uint64) (StateHistory, error) {
+	available, err := hasStateAt(ctx, client, 1)
+	if err != nil {
+		return StateHistory{}, err
+	}
+	if available {
+		return StateHistory{Known: true, Archive: true}, nil
+	}
+
+	// State is missing at missing and present at present, the head is assumed to have state
+	missing, present := uint64(1), head
+	for present-missing > 1 {
+		mid := missing + (present-missing)/2
+		available, err := hasStateAt(ctx, client, mid)
+		if err != nil {
+			return StateHistory{}, err
+		}
+		if available {
+			present = mid
+		} else {
+			missing = mid
+		}
+	}
+
+	return StateHistory{Known: true, Window: head - present}, nil
+}
+
+// hasStateAt asks the client for a balance at the block. It reports false when the client
+// answers with a missing state error and fails on any other error.
+func hasStateAt(ctx context.Context, client *Client, number uint64) (bool, error) {
+	_, err := client.call(ctx, "eth_getBalance", []interface{}{common.Address{}, hexutil.Uint64(number)})
+	if err == nil {
+		return true, nil
+	}
+	if isMissingState(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// isMissingState reports whether an error is a node's answer to a query at a block whose state it pruned
+func isMissingState(err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+
+	message := strings.ToLower(rpcErr.Message)
+	for _, fragment := range missingStateMessages {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStateServer starts a node at the given head that serves state from the oldest block on
+func newStateServer(t *testing.T, head, oldest uint64) *rpctest.Server {
+	t.Helper()
+
+	server := newHeadServer(t, head, 1700000000)
+	server.Handle("eth_getBalance", func(params json.RawMessage) (interface{}, *rpctest.Error) {
+		var args []json.RawMessage
+		var number hexutil.Uint64
+		if err := json.Unmarshal(params, &args); err != nil || len(args) != 2 || json.Unmarshal(args[1], &number) != nil {
+			return "0x1", nil
+		}
+		if uint64(number) < oldest {
+			return nil, &rpctest.Error{Code: -32000, Message: "missing trie node 0xabc (path ) state 0xdef is not available"}
+		}
+		return "0x1", nil
+	})
+	return server
+}
+
+func TestPoolStruct_DetectsStateHistory(t *testing.T) {
+	archive := newStateServer(t, 10000, 0)
+	full := newStateServer(t, 10000, 9872)
+	failing := newHeadServer(t, 10000, 1700000000)
+	failing.Handle("eth_getBalance", func(json.RawMessage) (interface{}, *rpctest.Error) {
+		return nil, &rpctest.Error{Code: -32603, Message: "internal error"}
+	})
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: archive.URL, Name: "archive", Timeout: time.Second},
+		{URL: full.URL, Name: "full", Timeout: time.Second},
+		{URL: failing.URL, Name: "failing", Timeout: time.Second},
+	}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pool.CheckAllHealth()
+
+	expected := map[string]StateHistory{
+		"archive": {Known: true, Archive: true},
+		"full":    {Known: true, Window: 128},
+		"failing": {},
+	}
+	for _, client := range pool.GetAllClients() {
+		assert.Equal(t, expected[client.Name], client.stateHistory, client.Name)
+	}
+
+	status, err := pool.ClientStatus("full")
+	require.NoError(t, err)
+	assert.Equal(t, "full", status.StateHistory)
+	assert.Equal(t, uint64(128), status.StateWindow)
+
+	// Detection runs once, later health checks do not probe state again
+	requests := full.Requests()
+	pool.CheckAllHealth()
+	assert.Equal(t, requests+1, full.Requests())
+}
+
+func TestPoolStruct_GetClientsForBlock(t *testing.T) {
+	archive := newHeadServer(t, 10000, 1700000000)
+	full := newHeadServer(t, 10000, 1700000000)
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: archive.URL, Name: "archive", Timeout: time.Second, NodeKind: config.NodeKindArchive},
+		{URL: full.URL, Name: "full", Timeout: time.Second, NodeKind: config.NodeKindFull},
+	}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+	pool.CheckAllHealth()
+
+	names := func(block BlockParameter) []string {
+		clients, err := pool.GetClientsForBlock(block)
+		require.NoError(t, err)
+		var names []string
+		for _, client := range clients {
+			names = append(names, client.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"archive", "full"}, names(LatestBlock))
+	assert.Equal(t, []string{"archive", "full"}, names(BlockNumber(10000-128)), "recent blocks are within the state window")
+	assert.Equal(t, []string{"archive"}, names(BlockNumber(0x100)), "historical queries only reach archive nodes")
+
+	_, err = pool.SetOverride("archive", OverrideCordoned)
+	require.NoError(t, err)
+	_, err = pool.GetClientsForBlock(BlockNumber(0x100))
+	assert.ErrorIs(t, err, ErrNoClientsAvailable, "an unavailable archive node is not a configuration error")
+	assert.NotErrorIs(t, err, ErrNoStateHistory)
+	assert.Contains(t, err.Error(), "block 0x100")
+	assert.Equal(t, []string{"full"}, names(LatestBlock), "the full node still serves recent blocks")
+
+	require.NoError(t, pool.RemoveClient(context.Background(), "archive"))
+	_, err = pool.GetClientsForBlock(BlockNumber(0x100))
+	assert.ErrorIs(t, err, ErrNoStateHistory)
+}
@@ -0,0 +1,273 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+const (
+	minReconnectDelay  = 500 * time.Millisecond
+	maxReconnectDelay  = 30 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+var (
+	errNotConnected     = errors.New("not connected")
+	errConnectionClosed = errors.New("connection closed")
+	// errMessageTooLarge is returned by a connection reading a message above the response size limit
+	errMessageTooLarge = errors.New("message too large")
+)
+
+// streamConn is a persistent connection carrying JSON-RPC messages in both directions
+type streamConn interface {
+	// ReadMessage returns the next message, a single response or a batch. Messages above
+	// the response size limit fail with errMessageTooLarge.
+	ReadMessage() ([]byte, error)
+	// WriteMessage sends a payload, it may be called concurrently
+	WriteMessage(payload []byte, deadline time.Time) error
+	Close() error
+}
+
+// streamResponse is a single JSON-RPC response delivered to a waiting request
+type streamResponse struct {
+	payload json.RawMessage
+	err     error
+}
+
+// streamTransport keeps a persistent connection to the client and multiplexes concurrent
+// requests over it by JSON-RPC id. The connection is re-established with exponential
+// backoff whenever it drops. Connections are opened by dial, so the same transport serves
+// WebSocket and IPC clients.
+type streamTransport struct {
+	name string
+	// kind names the connection type in logs
+	kind            string
+	maxResponseSize int64
+	dial            func(ctx context.Context) (streamConn, error)
+
+	mu      sync.Mutex
+	conn    streamConn
+	pending map[string]chan streamResponse
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newStreamTransport(name, kind string, maxResponseSize int64, dial func(ctx context.Context) (streamConn, error)) *streamTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &streamTransport{
+		name:            name,
+		kind:            kind,
+		maxResponseSize: maxResponseSize,
+		dial:            dial,
+		pending:         make(map[string]chan streamResponse),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+
+	t.wg.Add(1)
+	go t.run()
+
+	return t
+}
+
+// run keeps the connection open until the transport is closed
+func (t *streamTransport) run() {
+	defer t.wg.Done()
+
+	delay := minReconnectDelay
+	for {
+		conn, err := t.dial(t.ctx)
+		if err != nil {
+			if t.ctx.Err() != nil {
+				return
+			}
+			metrics.RecordClientError(t.name, "dial_failed")
+			log.Printf("%s dial to %s failed, retrying in %v: %v\n", t.kind, t.name, delay, err)
+
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxReconnectDelay)
+			continue
+		}
+
+		delay = minReconnectDelay
+		log.Printf("%s connection to %s established\n", t.kind, t.name)
+
+		err = t.serve(conn)
+		if t.ctx.Err() != nil {
+			return
+		}
+		metrics.RecordClientError(t.name, "connection_lost")
+		log.Printf("%s connection to %s lost, reconnecting: %v\n", t.kind, t.name, err)
+	}
+}
+
+// serve reads from an established connection until it fails.
+// A message larger than the response size limit closes the connection,
+// failing the requests in flight with a *ProtocolError.
+func (t *streamTransport) serve(conn streamConn) (err error) {
+	t.mu.Lock()
+	t.conn = conn
+	t.mu.Unlock()
+
+	defer func() {
+		var pendingErr error = errConnectionClosed
+		if errors.Is(err, errMessageTooLarge) {
+			pendingErr = &ProtocolError{Reason: fmt.Sprintf("response exceeds %d bytes", t.maxResponseSize)}
+		}
+
+		t.mu.Lock()
+		t.conn = nil
+		t.failPending(pendingErr)
+		t.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		t.dispatch(message)
+	}
+}
+
+// dispatch delivers a response or each element of a batch response to its waiting request
+func (t *streamTransport) dispatch(message []byte) {
+	message = bytes.TrimSpace(message)
+	if len(message) > 0 && message[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(message, &batch); err != nil {
+			log.Printf("Error parsing %s batch from %s: %v\n", t.kind, t.name, err)
+			return
+		}
+		for _, item := range batch {
+			t.deliver(item)
+		}
+		return
+	}
+
+	t.deliver(message)
+}
+
+func (t *streamTransport) deliver(message json.RawMessage) {
+	var envelope struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil || len(envelope.ID) == 0 {
+		// Subscription notifications and unattributable messages are not requests we wait for
+		return
+	}
+
+	key := idKey(envelope.ID)
+
+	t.mu.Lock()
+	ch, ok := t.pending[key]
+	delete(t.pending, key)
+	t.mu.Unlock()
+
+	if ok {
+		ch <- streamResponse{payload: append(json.RawMessage(nil), message...)}
+	}
+}
+
+// failPending fails all waiting requests, callers must hold t.mu
+func (t *streamTransport) failPending(err error) {
+	for key, ch := range t.pending {
+		ch <- streamResponse{err: err}
+		delete(t.pending, key)
+	}
+}
+
+// roundTrip writes the payload to the shared connection and waits for a response to every request id
+func (t *streamTransport) roundTrip(ctx context.Context, requests []Request, batch bool) ([]byte, error) {
+	payloadBytes, err := json.Marshal(requestPayload(requests, batch))
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	keys := make([]string, len(requests))
+	waiters := make([]chan streamResponse, len(requests))
+
+	t.mu.Lock()
+	conn := t.conn
+	if conn == nil {
+		t.mu.Unlock()
+		return nil, &TransportError{Err: fmt.Errorf("%s %w", t.kind, errNotConnected)}
+	}
+	for i, req := range requests {
+		keys[i] = idKey(req.ID)
+		waiters[i] = make(chan streamResponse, 1)
+		t.pending[keys[i]] = waiters[i]
+	}
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		for _, key := range keys {
+			delete(t.pending, key)
+		}
+		t.mu.Unlock()
+	}()
+
+	deadline := time.Now().Add(streamWriteTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	if err := conn.WriteMessage(payloadBytes, deadline); err != nil {
+		return nil, &TransportError{Err: err}
+	}
+
+	responses := make([]json.RawMessage, len(requests))
+	for i, waiter := range waiters {
+		select {
+		case resp := <-waiter:
+			var protocolErr *ProtocolError
+			if errors.As(resp.err, &protocolErr) {
+				return nil, resp.err
+			}
+			if resp.err != nil {
+				return nil, &TransportError{Err: resp.err}
+			}
+			responses[i] = resp.payload
+		case <-ctx.Done():
+			return nil, &TransportError{Err: ctx.Err()}
+		}
+	}
+
+	if !batch {
+		return responses[0], nil
+	}
+	return json.Marshal(responses)
+}
+
+// Close stops reconnecting and closes the connection, failing requests in flight
+func (t *streamTransport) Close() error {
+	t.closeOnce.Do(func() {
+		t.cancel()
+
+		t.mu.Lock()
+		if t.conn != nil {
+			t.conn.Close()
+		}
+		t.mu.Unlock()
+
+		t.wg.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,35 @@
+package client
+
+import "slices"
+
+// selectTiers returns the clients of the highest priority tiers that together reach the quorum.
+// Tiers are added in order of priority, a tier is either used entirely or not at all, so that the
+// routing strategy still balances between its clients. When all tiers together fall short of the
+// quorum every client is returned and consensus works with what is left.
+func selectTiers(clients []*Client, quorum int) []*Client {
+	if len(clients) == 0 {
+		return clients
+	}
+
+	tiers := make([]int, 0, 1)
+	for _, client := range clients {
+		if !slices.Contains(tiers, client.Tier) {
+			tiers = append(tiers, client.Tier)
+		}
+	}
+	slices.Sort(tiers)
+
+	selected := make([]*Client, 0, len(clients))
+	for _, tier := range tiers {
+		for _, client := range clients {
+			if client.Tier == tier {
+				selected = append(selected, client)
+			}
+		}
+
+		if len(selected) >= quorum {
+			break
+		}
+	}
+	return selected
+}
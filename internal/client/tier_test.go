@@ -0,0 +1,72 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectTiers(t *testing.T) {
+	clients := []*Client{
+		{Name: "paid", Tier: 1},
+		{Name: "free-1", Tier: 0},
+		{Name: "backup", Tier: 2},
+		{Name: "free-2", Tier: 0},
+	}
+
+	names := func(clients []*Client) []string {
+		var names []string
+		for _, client := range clients {
+			names = append(names, client.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name     string
+		clients  []*Client
+		quorum   int
+		expected []string
+	}{
+		{name: "Primary tier reaches quorum", clients: clients, quorum: 2, expected: []string{"free-1", "free-2"}},
+		{name: "Unset quorum uses the primary tier", clients: clients, quorum: 0, expected: []string{"free-1", "free-2"}},
+		{name: "Spills over one tier at a time", clients: clients, quorum: 3, expected: []string{"free-1", "free-2", "paid"}},
+		{name: "All tiers below quorum", clients: clients, quorum: 10, expected: []string{"free-1", "free-2", "paid", "backup"}},
+		{name: "Primary tier unavailable", clients: clients[:3:3], quorum: 1, expected: []string{"free-1"}},
+		{name: "Only fallback left", clients: clients[2:3], quorum: 2, expected: []string{"backup"}},
+		{name: "No clients", clients: nil, quorum: 1, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, names(selectTiers(tt.clients, tt.quorum)))
+		})
+	}
+}
+
+func TestPoolStruct_SpillsOverToLowerTiers(t *testing.T) {
+	free1 := newHeadServer(t, 100, 1700000000)
+	free2 := newHeadServer(t, 100, 1700000000)
+	paid := newHeadServer(t, 100, 1700000000)
+
+	pool, err := NewPool([]config.ClientConfig{
+		{URL: paid.URL, Name: "paid", Timeout: time.Second, Tier: 1},
+		{URL: free1.URL, Name: "free-1", Timeout: time.Second},
+		{URL: free2.URL, Name: "free-2", Timeout: time.Second},
+	}, config.PoolConfig{Quorum: 2})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	assert.Equal(t, []string{"free-1", "free-2"}, availableNames(pool))
+
+	_, err = pool.SetOverride("free-2", OverrideCordoned)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"free-1", "paid"}, availableNames(pool), "the paid tier fills the quorum")
+
+	_, err = pool.SetOverride("free-2", OverrideNone)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"free-1", "free-2"}, availableNames(pool), "traffic returns to the primary tier")
+}
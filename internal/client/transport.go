@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// transport delivers JSON-RPC payloads to an upstream client
+type transport interface {
+	// roundTrip sends the requests, as a batch payload when batch is set,
+	// and returns the raw response payload
+	roundTrip(ctx context.Context, requests []Request, batch bool) ([]byte, error)
+	Close() error
+}
+
+// newTransport picks the transport matching the scheme of the client URL
+func newTransport(cfg config.ClientConfig, auth Authenticator) transport {
+	url := strings.ToLower(cfg.URL)
+	switch {
+	case strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://"):
+		return newWSTransport(cfg, auth)
+	case isIPCURL(url):
+		return newIPCTransport(cfg)
+	default:
+		return newHTTPTransport(cfg, auth)
+	}
+}
+
+// maxResponseSize returns the response size limit of a client
+func maxResponseSize(cfg config.ClientConfig) int64 {
+	if cfg.MaxResponseSize <= 0 {
+		return defaultMaxResponseSize
+	}
+	return int64(cfg.MaxResponseSize)
+}
+
+// requestPayload returns the value to encode for a request or batch
+func requestPayload(requests []Request, batch bool) interface{} {
+	if batch {
+		return requests
+	}
+	return requests[0]
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsPongTimeout  = 2 * wsPingInterval
+)
+
+// newWSTransport creates a stream transport over a persistent WebSocket connection.
+// Every handshake is authenticated with fresh credentials.
+func newWSTransport(cfg config.ClientConfig, auth Authenticator) *streamTransport {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: cfg.Timeout,
+	}
+	limit := maxResponseSize(cfg)
+
+	return newStreamTransport(cfg.Name, "WebSocket", limit, func(ctx context.Context) (streamConn, error) {
+		header := make(http.Header)
+		if err := auth.Apply(header); err != nil {
+			return nil, fmt.Errorf("error authenticating handshake: %w", err)
+		}
+
+		conn, _, err := dialer.DialContext(ctx, cfg.URL, header)
+		if err != nil {
+			return nil, err
+		}
+		return newWSConn(conn, limit), nil
+	})
+}
+
+// wsConn is a WebSocket connection kept alive with pings, so that dead peers are detected
+type wsConn struct {
+	conn *websocket.Conn
+
+	// writeMu serialises writes, gorilla/websocket supports only one concurrent writer
+	writeMu   sync.Mutex
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWSConn(conn *websocket.Conn, maxResponseSize int64) *wsConn {
+	c := &wsConn{conn: conn, stop: make(chan struct{})}
+
+	conn.SetReadLimit(maxResponseSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	go c.ping()
+	return c
+}
+
+// ping keeps the connection alive and detects dead peers
+func (c *wsConn) ping() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+			c.writeMu.Unlock()
+			if err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return nil, errMessageTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	return message, nil
+}
+
+func (c *wsConn) WriteMessage(payload []byte, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(deadline)
+	return c.conn.WriteMessage(websocket.TextMessage, payload)
+}
+
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return c.conn.Close()
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWSTestClient(t *testing.T, server *rpctest.Server) *Client {
+	t.Helper()
+
+	client, err := NewClient(config.ClientConfig{
+		URL:     server.WSURL(),
+		Name:    "ws-test",
+		Timeout: time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	require.Eventually(t, func() bool {
+		_, err := client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "websocket transport did not connect")
+
+	return client
+}
+
+func TestWSTransport_MultiplexesRequests(t *testing.T) {
+	server := rpctest.NewServer()
+	defer server.Close()
+
+	server.HandleResult("eth_blockNumber", "0x10")
+	server.Handle("eth_getBalance", func(params json.RawMessage) (interface{}, *rpctest.Error) {
+		var args []string
+		json.Unmarshal(params, &args)
+		if args[0] == "0xbad" {
+			return nil, &rpctest.Error{Code: -32602, Message: "invalid address"}
+		}
+		return "0x3e8", nil
+	})
+
+	client := newWSTestClient(t, server)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			balance, err := client.QueryBalance(context.Background(), "0xabc", LatestBlock)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, big.NewInt(1000).Cmp(balance))
+		}()
+	}
+	wg.Wait()
+
+	results, err := client.BatchCall(context.Background(), []Call{
+		{Method: "eth_getBalance", Params: []interface{}{"0xabc", "latest"}},
+		{Method: "eth_getBalance", Params: []interface{}{"0xbad", "latest"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.JSONEq(t, `"0x3e8"`, string(results[0].Result))
+
+	var rpcErr *RPCError
+	assert.ErrorAs(t, results[1].Error, &rpcErr)
+
+	assert.Equal(t, int64(1), server.Connections(), "requests should share one connection")
+}
+
+func TestWSTransport_HealthCheckReusesConnection(t *testing.T) {
+	server := rpctest.NewServer()
+	defer server.Close()
+
+	server.HandleResult("eth_blockNumber", "0x10")
+	server.HandleHead(16, 1700000000)
+
+	client := newWSTestClient(t, server)
+	pool := &PoolStruct{clients: []*Client{client}}
+
+	assert.True(t, pool.CheckClientHealth(client))
+	assert.True(t, pool.CheckClientHealth(client))
+	assert.Equal(t, int64(1), server.Connections())
+}
+
+func TestWSTransport_Reconnects(t *testing.T) {
+	server := rpctest.NewServer()
+	defer server.Close()
+
+	server.HandleResult("eth_blockNumber", "0x10")
+
+	client := newWSTestClient(t, server)
+
+	server.DropConnections()
+
+	assert.Eventually(t, func() bool {
+		_, err := client.Call(context.Background(), "eth_blockNumber", []interface{}{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "websocket transport did not reconnect")
+	assert.Equal(t, int64(2), server.Connections())
+}
@@ -3,50 +3,594 @@ package config
 import (
 	"errors"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Consensus policies supported by the JSON-RPC proxy
+const (
+	// PolicyConsensus compares the responses with the proxy's consensus strategy, see RPCConfig.Consensus
+	PolicyConsensus = "consensus"
+	// PolicyPlurality returns the most common response whatever the consensus strategy
+	PolicyPlurality = "plurality"
+	// PolicyFirst returns the first response, for methods whose answers legitimately differ between nodes
+	PolicyFirst = "first"
 )
 
+// defaultRPCMethods are the JSON-RPC methods proxied when RPC_ALLOWED_METHODS is not set
+var defaultRPCMethods = []string{
+	"eth_blockNumber",
+	"eth_chainId",
+	"net_version",
+	"eth_gasPrice",
+	"eth_getBalance",
+	"eth_getTransactionCount",
+	"eth_getCode",
+	"eth_getStorageAt",
+	"eth_call",
+	"eth_estimateGas",
+	"eth_getBlockByNumber",
+	"eth_getBlockByHash",
+	"eth_getTransactionByHash",
+	"eth_getTransactionReceipt",
+	"eth_getLogs",
+}
+
+// defaultRPCPolicies are per-method consensus policies applied when RPC_METHOD_POLICIES is not set.
+// Methods whose answers legitimately differ between healthy nodes take the first response.
+var defaultRPCPolicies = map[string]string{
+	"eth_blockNumber": PolicyFirst,
+	"eth_gasPrice":    PolicyFirst,
+	"eth_estimateGas": PolicyFirst,
+}
+
 // Config holds the application configuration
 type Config struct {
-	ServerPort          string
-	RequestTimeout      time.Duration
-	HealthCheckInterval time.Duration
-	Clients             []ClientConfig
+	ServerPort          string        `yaml:"port"`
+	RequestTimeout      time.Duration `yaml:"request_timeout"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+	// ClientDefaults holds the settings of clients that do not set them themselves,
+	// including clients added through the admin API
+	ClientDefaults ClientConfig `yaml:"client_defaults"`
+	// Clients are decoded separately from the config file, on top of ClientDefaults
+	Clients []ClientConfig `yaml:"-"`
+	Pool    PoolConfig     `yaml:"pool"`
+	RPC     RPCConfig      `yaml:"rpc"`
+	Balance BalanceConfig  `yaml:"balance"`
+	Admin   AdminConfig    `yaml:"admin"`
+}
+
+// AdminConfig holds the settings of the admin API, which manages the client pool at runtime
+type AdminConfig struct {
+	// Port is the port of the admin listener, separate from the public one. Empty disables the admin API.
+	Port string `yaml:"port"`
+	// Token is the bearer token every admin request must carry
+	Token string `yaml:"token"`
+}
+
+// PoolConfig holds the settings of the client pool
+type PoolConfig struct {
+	// ExpectedChainID is the chain every client must be on, 0 disables the check
+	ExpectedChainID uint64 `yaml:"expected_chain_id"`
+	// ExpectedNetworkID is the net_version every client must report, it usually equals the chain id
+	ExpectedNetworkID uint64 `yaml:"expected_network_id"`
+	// FanOutTimeout bounds a request sent to several clients at once
+	FanOutTimeout time.Duration `yaml:"fanout_timeout"`
+	// Quorum is the number of clients a request should reach. Clients of a lower priority
+	// tier are only used while the higher priority tiers have fewer serving clients than this.
+	// It is raised to the quorum of a quorum consensus strategy, which needs that many clients to answer.
+	Quorum     int              `yaml:"quorum"`
+	Health     HealthConfig     `yaml:"health"`
+	Reputation ReputationConfig `yaml:"reputation"`
+}
+
+// ReputationConfig holds the outlier detection that ejects clients which keep disagreeing with consensus.
+// Every balance query at a fixed block with a clear majority counts as a vote for each responding client.
+type ReputationConfig struct {
+	// DisagreementThreshold ejects a client once the ratio of votes it lost over the window reaches it, 0 disables ejection
+	DisagreementThreshold float64 `yaml:"disagreement_threshold"`
+	WindowSize            int     `yaml:"window_size"`
+	// MinVotes is the number of votes required in the window before the disagreement rate is evaluated
+	MinVotes int `yaml:"min_votes"`
+	// BaseEjectionTime is how long a client stays ejected, multiplied by the number of ejections in a row
+	BaseEjectionTime time.Duration `yaml:"base_ejection_time"`
+	MaxEjectionTime  time.Duration `yaml:"max_ejection_time"`
+	// MaxEjectionPercent caps the share of the pool's clients that may be ejected at the same time
+	MaxEjectionPercent int `yaml:"max_ejection_percent"`
+}
+
+// HealthConfig holds the scheduling of health checks and the thresholds they use to detect lagging nodes.
+// Lag is measured against the pool head, the highest head reached by at least half of the clients in the last round of checks.
+// A degraded client only serves traffic when no healthy client is left.
+type HealthConfig struct {
+	// Timeout bounds a single health probe
+	Timeout time.Duration `yaml:"timeout"`
+	// Concurrency is the number of clients probed at the same time, 0 probes all clients at once
+	Concurrency int `yaml:"concurrency"`
+	// Jitter spreads checks by moving each interval up to this ratio earlier or later,
+	// so that proxy replicas do not probe the same nodes in lockstep
+	Jitter float64 `yaml:"jitter"`
+	// HistorySize is the number of recent check results kept for each client
+	HistorySize int `yaml:"history_size"`
+	// DegradedBlockLag marks a client degraded when its head is more than this many blocks behind
+	DegradedBlockLag int `yaml:"degraded_block_lag"`
+	// MaxBlockLag marks a client unavailable when its head is more than this many blocks behind
+	MaxBlockLag int `yaml:"max_block_lag"`
+	// DegradedTimeLag marks a client degraded when its head block is this much older than the pool's
+	DegradedTimeLag time.Duration `yaml:"degraded_time_lag"`
+	// MaxTimeLag marks a client unavailable when its head block is this much older than the pool's
+	MaxTimeLag time.Duration       `yaml:"max_time_lag"`
+	Passive    PassiveHealthConfig `yaml:"passive"`
+}
+
+// PassiveHealthConfig marks a client unavailable from the outcome of live requests, without waiting
+// for the next health check, which then decides when the client comes back.
+// Transport failures count against the client, RPC errors mean it answered.
+type PassiveHealthConfig struct {
+	// ConsecutiveFailures marks the client unavailable after this many failures in a row, 0 disables the check
+	ConsecutiveFailures int `yaml:"consecutive_failures"`
+	// FailureRateThreshold marks the client unavailable when the failure ratio over the window reaches it, 0 disables the check
+	FailureRateThreshold float64 `yaml:"failure_rate"`
+	WindowSize           int     `yaml:"window_size"`
+	// MinRequests is the number of outcomes required in the window before the failure rate is evaluated
+	MinRequests int `yaml:"min_requests"`
+}
+
+// Query modes of the balance endpoint
+const (
+	ModeFanOut = "fanout"
+	ModeHedged = "hedged"
+)
+
+// BalanceConfig holds configuration for the balance endpoint
+type BalanceConfig struct {
+	// Mode is the default query mode, callers may override it per request
+	Mode string `yaml:"mode"`
+	// PinBlock resolves latest, safe and finalized to a block number agreed by a quorum of
+	// clients before a fan-out query, so that all clients answer for the same block
+	PinBlock  bool            `yaml:"pin_block"`
+	Hedge     HedgeConfig     `yaml:"hedge"`
+	Routing   RoutingConfig   `yaml:"routing"`
+	Consensus ConsensusConfig `yaml:"consensus"`
+}
+
+// Consensus strategies that decide which answer of a fan-out is returned
+const (
+	// ConsensusPlurality returns the most common answer, a tie for the most votes is no consensus
+	ConsensusPlurality = "plurality"
+	// ConsensusMajority requires more than half of the responses to agree
+	ConsensusMajority = "majority"
+	// ConsensusQuorum requires at least Quorum clients to agree
+	ConsensusQuorum = "quorum"
+	// ConsensusUnanimous requires every response to agree
+	ConsensusUnanimous = "unanimous"
+	// ConsensusWeighted returns the answer backed by the most trust weight
+	ConsensusWeighted = "weighted"
+	// ConsensusHighestBlock returns the answer of the client with the highest head block
+	ConsensusHighestBlock = "highest-block"
+)
+
+// What an endpoint answers when the consensus strategy is not satisfied
+const (
+	// FailOpen returns the best candidate answer, favouring availability
+	FailOpen = "open"
+	// FailClosed rejects the request with the conflicting answers, favouring correctness
+	FailClosed = "closed"
+)
+
+// ConsensusConfig holds the consensus strategy of an endpoint and its parameters
+type ConsensusConfig struct {
+	Strategy string `yaml:"strategy"`
+	// Quorum is the number of clients that must agree with the quorum strategy
+	Quorum int `yaml:"quorum"`
+	// Weights are the trust weights of the weighted strategy by client name, unlisted clients weigh 1
+	Weights map[string]float64 `yaml:"weights"`
+	// OnFailure is FailOpen or FailClosed
+	OnFailure string `yaml:"on_failure"`
+}
+
+// HedgeConfig holds the settings of the hedged query mode
+type HedgeConfig struct {
+	// Delay is how long to wait for a client before sending the request to the next one
+	Delay time.Duration `yaml:"delay"`
+	// UseP95 replaces Delay with the observed p95 latency of the client once it is known
+	UseP95      bool `yaml:"use_p95"`
+	MaxRequests int  `yaml:"max_requests"`
+}
+
+// RPCConfig holds configuration for the generic JSON-RPC proxy endpoint
+type RPCConfig struct {
+	AllowedMethods []string          `yaml:"allowed_methods"`
+	MethodPolicies map[string]string `yaml:"method_policies"`
+	DefaultPolicy  string            `yaml:"default_policy"`
+	MaxBatchSize   int               `yaml:"max_batch_size"`
+	Routing        RoutingConfig     `yaml:"routing"`
+	// Consensus is the strategy of methods with the consensus policy
+	Consensus ConsensusConfig `yaml:"consensus"`
+}
+
+// Routing strategies that pick the upstream clients of a request
+const (
+	RoutingFanOut         = "fanout"
+	RoutingRoundRobin     = "round-robin"
+	RoutingLeastLatency   = "least-latency"
+	RoutingWeightedRandom = "weighted-random"
+	RoutingRandomN        = "random-n"
+)
+
+// RoutingConfig selects the routing strategy of an endpoint
+type RoutingConfig struct {
+	Strategy string `yaml:"strategy"`
+	// Count is the number of clients each request is sent to, ignored by fanout
+	Count int `yaml:"count"`
 }
 
 // ClientConfig holds configuration for a single Ethereum client
 type ClientConfig struct {
-	URL     string
-	Name    string
-	Timeout time.Duration
+	URL          string        `yaml:"url"`
+	Name         string        `yaml:"name"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxBatchSize int           `yaml:"max_batch_size"`
+	// MaxResponseSize caps the size in bytes of a response read from the client
+	MaxResponseSize int `yaml:"max_response_size"`
+	// Weight is the relative share of traffic the client gets from the weighted-random routing strategy
+	Weight int `yaml:"weight"`
+	// Tier is the client's priority, 0 being the highest. Clients of a tier only take traffic
+	// when the tiers before it cannot reach the pool's quorum, e.g. for paid fallback providers.
+	Tier int `yaml:"tier"`
+	// RequestsPerSecond is the refill rate of the client-side token bucket, 0 disables client-side limiting
+	RequestsPerSecond float64 `yaml:"rps"`
+	// Burst is the capacity of the token bucket
+	Burst int `yaml:"burst"`
+	// NodeKind declares whether the client is an archive or a full node, empty detects it at startup
+	NodeKind string `yaml:"node_kind"`
+	// StateWindow is the number of blocks behind its head for which a full node still serves state,
+	// 0 detects it at startup unless the node kind is declared
+	StateWindow uint64        `yaml:"state_window"`
+	Auth        AuthConfig    `yaml:"auth"`
+	Breaker     BreakerConfig `yaml:"breaker"`
+	Retry       RetryConfig   `yaml:"retry"`
+}
+
+// Node kinds of a client, deciding which historical state queries it is sent
+const (
+	NodeKindDetect  = ""
+	NodeKindArchive = "archive"
+	NodeKindFull    = "full"
+)
+
+// Authentication schemes supported for upstream clients
+const (
+	AuthNone   = ""
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthJWT    = "jwt"
+)
+
+// AuthConfig holds the credentials sent to a client
+type AuthConfig struct {
+	Type     string `yaml:"type"`
+	Token    string `yaml:"token"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// JWTSecretFile holds the hex encoded HS256 secret shared with the node, as used by the engine API
+	JWTSecretFile string `yaml:"jwt_secret_file"`
+	// Headers are sent with every request in addition to the auth scheme, e.g. x-api-key
+	Headers map[string]string `yaml:"headers"`
+}
+
+// RetryConfig holds the retry policy for transient upstream failures
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, 1 disables retries
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	// Jitter is the fraction, between 0 and 1, by which each backoff is randomly shortened
+	Jitter float64 `yaml:"jitter"`
+}
+
+// BreakerConfig holds the circuit breaker settings applied to each client
+type BreakerConfig struct {
+	// ConsecutiveFailures opens the breaker after this many failures in a row, 0 disables the check
+	ConsecutiveFailures int `yaml:"consecutive_failures"`
+	// FailureRateThreshold opens the breaker when the failure ratio over the window reaches it, 0 disables the check
+	FailureRateThreshold float64 `yaml:"failure_rate"`
+	WindowSize           int     `yaml:"window_size"`
+	// MinRequests is the number of outcomes required in the window before the failure rate is evaluated
+	MinRequests    int           `yaml:"min_requests"`
+	Cooldown       time.Duration `yaml:"cooldown"`
+	HalfOpenProbes int           `yaml:"half_open_probes"`
+}
+
+// Default returns the settings used when neither the config file nor the environment sets them
+func Default() *Config {
+	return &Config{
+		ServerPort:          "8080",
+		RequestTimeout:      15 * time.Second,
+		HealthCheckInterval: 30 * time.Second,
+		ClientDefaults: ClientConfig{
+			Timeout:         10 * time.Second,
+			MaxBatchSize:    100,
+			MaxResponseSize: 16 << 20,
+			Weight:          1,
+			Breaker: BreakerConfig{
+				ConsecutiveFailures:  5,
+				FailureRateThreshold: 0.5,
+				WindowSize:           20,
+				MinRequests:          10,
+				Cooldown:             30 * time.Second,
+				HalfOpenProbes:       1,
+			},
+			Retry: RetryConfig{
+				MaxAttempts:    3,
+				InitialBackoff: 100 * time.Millisecond,
+				MaxBackoff:     2 * time.Second,
+				Multiplier:     2,
+				Jitter:         0.2,
+			},
+		},
+		Pool: PoolConfig{
+			FanOutTimeout: 30 * time.Second,
+			Quorum:        1,
+			Health: HealthConfig{
+				Timeout:          5 * time.Second,
+				Concurrency:      10,
+				Jitter:           0.1,
+				HistorySize:      20,
+				DegradedBlockLag: 3,
+				MaxBlockLag:      10,
+				DegradedTimeLag:  30 * time.Second,
+				MaxTimeLag:       2 * time.Minute,
+				Passive: PassiveHealthConfig{
+					ConsecutiveFailures:  3,
+					FailureRateThreshold: 0.5,
+					WindowSize:           20,
+					MinRequests:          10,
+				},
+			},
+			Reputation: ReputationConfig{
+				DisagreementThreshold: 0.2,
+				WindowSize:            50,
+				MinVotes:              10,
+				BaseEjectionTime:      30 * time.Second,
+				MaxEjectionTime:       5 * time.Minute,
+				MaxEjectionPercent:    50,
+			},
+		},
+		RPC: RPCConfig{
+			AllowedMethods: defaultRPCMethods,
+			DefaultPolicy:  PolicyConsensus,
+			MaxBatchSize:   500,
+			Routing:        RoutingConfig{Strategy: RoutingFanOut},
+			Consensus:      ConsensusConfig{Strategy: ConsensusPlurality, Quorum: 2, OnFailure: FailOpen},
+		},
+		Balance: BalanceConfig{
+			Mode:     ModeFanOut,
+			PinBlock: true,
+			Hedge: HedgeConfig{
+				Delay:       200 * time.Millisecond,
+				UseP95:      true,
+				MaxRequests: 3,
+			},
+			Routing:   RoutingConfig{Strategy: RoutingFanOut},
+			Consensus: ConsensusConfig{Strategy: ConsensusPlurality, Quorum: 2, OnFailure: FailOpen},
+		},
+	}
 }
 
-// Load loads the application configuration from environment variables
+// Load loads the application configuration from the YAML file named by CONFIG_FILE, if set,
+// and from environment variables, which take precedence over the file
 func Load() (*Config, error) {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile loads the application configuration from a YAML file and environment variables.
+// Settings are layered as defaults, then the file, then the environment, and the result is validated.
+// An empty path reads the environment only.
+func LoadFile(path string) (*Config, error) {
+	cfg := Default()
+
+	var clientNodes []yaml.Node
+	var err error
+	if path != "" {
+		if clientNodes, err = readFile(path, cfg); err != nil {
+			return nil, err
+		}
 	}
 
-	requestTimeout := 15 * time.Second
-	healthCheckInterval := 30 * time.Second
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
 
-	clients := getClientConfigsFromEnv()
-	if len(clients) == 0 {
-		return nil, errors.New("no Ethereum clients configured. Please set ETH_CLIENT_<N>_URL and ETH_CLIENT_<N>_NAME in .env")
+	// Clients are decoded once the environment has had its say on the client defaults they inherit
+	fileClients, err := decodeClients(path, clientNodes, cfg.ClientDefaults)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Config{
-		ServerPort:          port,
-		RequestTimeout:      requestTimeout,
-		HealthCheckInterval: healthCheckInterval,
-		Clients:             clients,
-	}, nil
+	// Clients from the environment replace the ones from the file
+	envClients, err := getClientConfigsFromEnv(cfg.ClientDefaults)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Clients = fileClients
+	if len(envClients) > 0 {
+		cfg.Clients = envClients
+	}
+	if len(cfg.Clients) == 0 {
+		return nil, errors.New("no Ethereum clients configured. Please set ETH_CLIENT_<N>_URL and ETH_CLIENT_<N>_NAME in .env or list clients in the config file")
+	}
+
+	cfg.applyDerivedDefaults()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
-// getClientConfigsFromEnv retrieves Ethereum client configurations from environment variables
-func getClientConfigsFromEnv() []ClientConfig {
+// applyEnv overrides the settings of cfg, except its clients, with environment variables
+func applyEnv(cfg *Config) error {
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.ServerPort = port
+	}
+
+	var err error
+	if cfg.RequestTimeout, err = getDurationFromEnv("REQUEST_TIMEOUT", cfg.RequestTimeout); err != nil {
+		return err
+	}
+	if cfg.HealthCheckInterval, err = getDurationFromEnv("HEALTH_CHECK_INTERVAL", cfg.HealthCheckInterval); err != nil {
+		return err
+	}
+
+	if cfg.ClientDefaults.Timeout, err = getDurationFromEnv("ETH_CLIENT_TIMEOUT", cfg.ClientDefaults.Timeout); err != nil {
+		return err
+	}
+	if cfg.ClientDefaults.Breaker, err = getBreakerConfigFromEnv(cfg.ClientDefaults.Breaker); err != nil {
+		return err
+	}
+	if cfg.ClientDefaults.Retry, err = getRetryConfigFromEnv(cfg.ClientDefaults.Retry); err != nil {
+		return err
+	}
+
+	if cfg.RPC, err = getRPCConfigFromEnv(cfg.RPC); err != nil {
+		return err
+	}
+	if cfg.Balance, err = getBalanceConfigFromEnv(cfg.Balance); err != nil {
+		return err
+	}
+	if cfg.Pool, err = getPoolConfigFromEnv(cfg.Pool); err != nil {
+		return err
+	}
+
+	if port := os.Getenv("ADMIN_PORT"); port != "" {
+		cfg.Admin.Port = port
+	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		cfg.Admin.Token = token
+	}
+
+	return nil
+}
+
+// applyDerivedDefaults fills in settings whose defaults depend on other settings
+func (c *Config) applyDerivedDefaults() {
+	if c.Pool.ExpectedNetworkID == 0 {
+		c.Pool.ExpectedNetworkID = c.Pool.ExpectedChainID
+	}
+
+	if c.RPC.MethodPolicies == nil {
+		c.RPC.MethodPolicies = make(map[string]string, len(defaultRPCPolicies))
+		for method, policy := range defaultRPCPolicies {
+			c.RPC.MethodPolicies[method] = policy
+		}
+	}
+
+	// Tier selection has to gather enough clients for a quorum strategy to be satisfied
+	c.Pool.Quorum = max(c.Pool.Quorum, c.Balance.Consensus.requiredClients(), c.RPC.Consensus.requiredClients())
+
+	c.RPC.Routing = c.RPC.Routing.withDefaultCount()
+	c.Balance.Routing = c.Balance.Routing.withDefaultCount()
+
+	for i := range c.Clients {
+		c.Clients[i].Burst = c.Clients[i].burstOrDefault()
+	}
+	c.ClientDefaults.Burst = c.ClientDefaults.burstOrDefault()
+}
+
+// requiredClients returns the number of clients that must answer before the consensus strategy can be satisfied
+func (c ConsensusConfig) requiredClients() int {
+	if c.Strategy == ConsensusQuorum {
+		return c.Quorum
+	}
+	return 1
+}
+
+// withDefaultCount sets the number of clients per request when it is not configured
+func (r RoutingConfig) withDefaultCount() RoutingConfig {
+	if r.Count == 0 {
+		r.Count = 1
+		if r.Strategy == RoutingRandomN {
+			r.Count = 2
+		}
+	}
+	return r
+}
+
+// burstOrDefault returns the configured burst, or one second worth of requests without an explicit burst
+func (c ClientConfig) burstOrDefault() int {
+	if c.Burst > 0 {
+		return c.Burst
+	}
+	return max(int(math.Ceil(c.RequestsPerSecond)), 1)
+}
+
+// getRPCConfigFromEnv overrides the JSON-RPC proxy configuration with environment variables.
+// RPC_ALLOWED_METHODS is a comma-separated list of methods and
+// RPC_METHOD_POLICIES is a comma-separated list of method=policy pairs.
+func getRPCConfigFromEnv(cfg RPCConfig) (RPCConfig, error) {
+	var err error
+	if cfg.MaxBatchSize, err = getIntFromEnv("RPC_MAX_BATCH_SIZE", cfg.MaxBatchSize); err != nil {
+		return RPCConfig{}, err
+	}
+
+	if cfg.Routing, err = getRoutingConfigFromEnv("RPC", cfg.Routing); err != nil {
+		return RPCConfig{}, err
+	}
+	if cfg.Consensus, err = getConsensusConfigFromEnv("RPC", cfg.Consensus); err != nil {
+		return RPCConfig{}, err
+	}
+
+	if methods := os.Getenv("RPC_ALLOWED_METHODS"); methods != "" {
+		cfg.AllowedMethods = splitList(methods)
+	}
+
+	if policy := os.Getenv("RPC_DEFAULT_POLICY"); policy != "" {
+		cfg.DefaultPolicy = policy
+	}
+
+	policies := os.Getenv("RPC_METHOD_POLICIES")
+	if policies == "" {
+		return cfg, nil
+	}
+
+	cfg.MethodPolicies = make(map[string]string)
+	for _, pair := range splitList(policies) {
+		method, policy, ok := strings.Cut(pair, "=")
+		if !ok || method == "" {
+			return RPCConfig{}, fmt.Errorf("invalid RPC_METHOD_POLICIES entry %q, expected method=policy", pair)
+		}
+		cfg.MethodPolicies[method] = policy
+	}
+
+	return cfg, nil
+}
+
+func isValidPolicy(policy string) bool {
+	return policy == PolicyConsensus || policy == PolicyPlurality || policy == PolicyFirst
+}
+
+// splitList splits a comma-separated list and drops empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getClientConfigsFromEnv retrieves Ethereum client configurations from environment variables,
+// settings a client does not set are taken from defaults
+func getClientConfigsFromEnv(defaults ClientConfig) ([]ClientConfig, error) {
 	var clients []ClientConfig
 
 	for i := 1; ; i++ {
@@ -64,12 +608,380 @@ func getClientConfigsFromEnv() []ClientConfig {
 			name = fmt.Sprintf("client-%d", i)
 		}
 
-		clients = append(clients, ClientConfig{
-			URL:     url,
-			Name:    name,
-			Timeout: 10 * time.Second,
-		})
+		cfg := defaults
+		cfg.URL = url
+		cfg.Name = name
+
+		var err error
+		if cfg.Timeout, err = getDurationFromEnv(fmt.Sprintf("ETH_CLIENT_%d_TIMEOUT", i), cfg.Timeout); err != nil {
+			return nil, err
+		}
+		if cfg.MaxBatchSize, err = getIntFromEnv(fmt.Sprintf("ETH_CLIENT_%d_MAX_BATCH_SIZE", i), cfg.MaxBatchSize); err != nil {
+			return nil, err
+		}
+		if cfg.MaxResponseSize, err = getIntFromEnv(fmt.Sprintf("ETH_CLIENT_%d_MAX_RESPONSE_SIZE", i), cfg.MaxResponseSize); err != nil {
+			return nil, err
+		}
+		if cfg.Weight, err = getIntFromEnv(fmt.Sprintf("ETH_CLIENT_%d_WEIGHT", i), cfg.Weight); err != nil {
+			return nil, err
+		}
+		if cfg.Tier, err = getNonNegativeIntFromEnv(fmt.Sprintf("ETH_CLIENT_%d_TIER", i), cfg.Tier); err != nil {
+			return nil, err
+		}
+		if nodeKind := os.Getenv(fmt.Sprintf("ETH_CLIENT_%d_NODE_KIND", i)); nodeKind != "" {
+			cfg.NodeKind = strings.ToLower(nodeKind)
+		}
+		if cfg.StateWindow, err = getUintFromEnv(fmt.Sprintf("ETH_CLIENT_%d_STATE_WINDOW", i), cfg.StateWindow); err != nil {
+			return nil, err
+		}
+		if cfg.Auth, err = getAuthConfigFromEnv(fmt.Sprintf("ETH_CLIENT_%d", i)); err != nil {
+			return nil, err
+		}
+		if cfg.RequestsPerSecond, err = getFloatFromEnv(fmt.Sprintf("ETH_CLIENT_%d_RPS", i), cfg.RequestsPerSecond); err != nil {
+			return nil, err
+		}
+		// Without an explicit burst the bucket holds one second worth of requests
+		if cfg.Burst, err = getIntFromEnv(fmt.Sprintf("ETH_CLIENT_%d_BURST", i), cfg.Burst); err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, cfg)
+	}
+
+	return clients, nil
+}
+
+// getAuthConfigFromEnv retrieves a client's credentials from <PREFIX>_AUTH_TYPE and the variables
+// of that scheme. <PREFIX>_HEADERS is a comma-separated list of name=value pairs sent with every request.
+func getAuthConfigFromEnv(prefix string) (AuthConfig, error) {
+	cfg := AuthConfig{
+		Type:          strings.ToLower(os.Getenv(prefix + "_AUTH_TYPE")),
+		Token:         os.Getenv(prefix + "_AUTH_TOKEN"),
+		Username:      os.Getenv(prefix + "_AUTH_USERNAME"),
+		Password:      os.Getenv(prefix + "_AUTH_PASSWORD"),
+		JWTSecretFile: os.Getenv(prefix + "_JWT_SECRET_FILE"),
+	}
+
+	if headers := os.Getenv(prefix + "_HEADERS"); headers != "" {
+		cfg.Headers = make(map[string]string)
+		for _, pair := range splitList(headers) {
+			name, value, ok := strings.Cut(pair, "=")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				return AuthConfig{}, fmt.Errorf("invalid %s_HEADERS entry %q, expected name=value", prefix, pair)
+			}
+			cfg.Headers[name] = strings.TrimSpace(value)
+		}
+	}
+
+	return cfg, nil
+}
+
+// getBalanceConfigFromEnv overrides the balance endpoint configuration with environment variables
+func getBalanceConfigFromEnv(cfg BalanceConfig) (BalanceConfig, error) {
+	if mode := os.Getenv("BALANCE_QUERY_MODE"); mode != "" {
+		cfg.Mode = mode
+	}
+
+	var err error
+	if cfg.PinBlock, err = getBoolFromEnv("BALANCE_PIN_BLOCK", cfg.PinBlock); err != nil {
+		return BalanceConfig{}, err
+	}
+	if cfg.Hedge.UseP95, err = getBoolFromEnv("HEDGE_USE_P95", cfg.Hedge.UseP95); err != nil {
+		return BalanceConfig{}, err
+	}
+	if cfg.Hedge.Delay, err = getDurationFromEnv("HEDGE_DELAY", cfg.Hedge.Delay); err != nil {
+		return BalanceConfig{}, err
+	}
+	if cfg.Hedge.MaxRequests, err = getIntFromEnv("HEDGE_MAX_REQUESTS", cfg.Hedge.MaxRequests); err != nil {
+		return BalanceConfig{}, err
+	}
+	if cfg.Routing, err = getRoutingConfigFromEnv("BALANCE", cfg.Routing); err != nil {
+		return BalanceConfig{}, err
+	}
+	if cfg.Consensus, err = getConsensusConfigFromEnv("BALANCE", cfg.Consensus); err != nil {
+		return BalanceConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// getRoutingConfigFromEnv overrides the routing strategy of an endpoint with
+// <PREFIX>_ROUTING and <PREFIX>_ROUTING_COUNT
+func getRoutingConfigFromEnv(prefix string, cfg RoutingConfig) (RoutingConfig, error) {
+	if strategy := os.Getenv(prefix + "_ROUTING"); strategy != "" {
+		cfg.Strategy = strategy
+	}
+
+	var err error
+	if cfg.Count, err = getIntFromEnv(prefix+"_ROUTING_COUNT", cfg.Count); err != nil {
+		return RoutingConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// getConsensusConfigFromEnv overrides the consensus strategy of an endpoint with <PREFIX>_CONSENSUS,
+// <PREFIX>_CONSENSUS_QUORUM, <PREFIX>_CONSENSUS_ON_FAILURE and <PREFIX>_CONSENSUS_WEIGHTS,
+// a comma-separated list of client=weight pairs
+func getConsensusConfigFromEnv(prefix string, cfg ConsensusConfig) (ConsensusConfig, error) {
+	if strategy := os.Getenv(prefix + "_CONSENSUS"); strategy != "" {
+		cfg.Strategy = strategy
+	}
+	if onFailure := os.Getenv(prefix + "_CONSENSUS_ON_FAILURE"); onFailure != "" {
+		cfg.OnFailure = onFailure
+	}
+
+	var err error
+	if cfg.Quorum, err = getIntFromEnv(prefix+"_CONSENSUS_QUORUM", cfg.Quorum); err != nil {
+		return ConsensusConfig{}, err
+	}
+
+	key := prefix + "_CONSENSUS_WEIGHTS"
+	weights := os.Getenv(key)
+	if weights == "" {
+		return cfg, nil
+	}
+
+	cfg.Weights = make(map[string]float64)
+	for _, pair := range splitList(weights) {
+		name, value, ok := strings.Cut(pair, "=")
+		weight, err := strconv.ParseFloat(value, 64)
+		if !ok || name == "" || err != nil {
+			return ConsensusConfig{}, fmt.Errorf("invalid %s entry %q, expected client=weight", key, pair)
+		}
+		cfg.Weights[name] = weight
+	}
+
+	return cfg, nil
+}
+
+// getBreakerConfigFromEnv overrides the circuit breaker settings shared by all clients
+func getBreakerConfigFromEnv(cfg BreakerConfig) (BreakerConfig, error) {
+	var err error
+
+	if cfg.ConsecutiveFailures, err = getNonNegativeIntFromEnv("CIRCUIT_CONSECUTIVE_FAILURES", cfg.ConsecutiveFailures); err != nil {
+		return BreakerConfig{}, err
+	}
+	if cfg.FailureRateThreshold, err = getFloatFromEnv("CIRCUIT_FAILURE_RATE", cfg.FailureRateThreshold); err != nil {
+		return BreakerConfig{}, err
 	}
+	if cfg.WindowSize, err = getIntFromEnv("CIRCUIT_WINDOW_SIZE", cfg.WindowSize); err != nil {
+		return BreakerConfig{}, err
+	}
+	if cfg.MinRequests, err = getIntFromEnv("CIRCUIT_MIN_REQUESTS", cfg.MinRequests); err != nil {
+		return BreakerConfig{}, err
+	}
+	if cfg.Cooldown, err = getDurationFromEnv("CIRCUIT_COOLDOWN", cfg.Cooldown); err != nil {
+		return BreakerConfig{}, err
+	}
+	if cfg.HalfOpenProbes, err = getIntFromEnv("CIRCUIT_HALF_OPEN_PROBES", cfg.HalfOpenProbes); err != nil {
+		return BreakerConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// getPoolConfigFromEnv overrides the chain, fan-out and health check settings of the pool
+func getPoolConfigFromEnv(cfg PoolConfig) (PoolConfig, error) {
+	var err error
+
+	if cfg.ExpectedChainID, err = getUintFromEnv("EXPECTED_CHAIN_ID", cfg.ExpectedChainID); err != nil {
+		return PoolConfig{}, err
+	}
+	if cfg.ExpectedNetworkID, err = getUintFromEnv("EXPECTED_NETWORK_ID", cfg.ExpectedNetworkID); err != nil {
+		return PoolConfig{}, err
+	}
+	if cfg.FanOutTimeout, err = getDurationFromEnv("FANOUT_TIMEOUT", cfg.FanOutTimeout); err != nil {
+		return PoolConfig{}, err
+	}
+	if cfg.Quorum, err = getIntFromEnv("POOL_QUORUM", cfg.Quorum); err != nil {
+		return PoolConfig{}, err
+	}
+	if cfg.Health, err = getHealthConfigFromEnv(cfg.Health); err != nil {
+		return PoolConfig{}, err
+	}
+	if cfg.Reputation, err = getReputationConfigFromEnv(cfg.Reputation); err != nil {
+		return PoolConfig{}, err
+	}
+
+	return cfg, nil
+}
 
-	return clients
+// getReputationConfigFromEnv overrides the outlier detection that ejects clients disagreeing with consensus
+func getReputationConfigFromEnv(cfg ReputationConfig) (ReputationConfig, error) {
+	var err error
+
+	if cfg.DisagreementThreshold, err = getFloatFromEnv("REPUTATION_DISAGREEMENT_THRESHOLD", cfg.DisagreementThreshold); err != nil {
+		return ReputationConfig{}, err
+	}
+	if cfg.WindowSize, err = getIntFromEnv("REPUTATION_WINDOW_SIZE", cfg.WindowSize); err != nil {
+		return ReputationConfig{}, err
+	}
+	if cfg.MinVotes, err = getIntFromEnv("REPUTATION_MIN_VOTES", cfg.MinVotes); err != nil {
+		return ReputationConfig{}, err
+	}
+	if cfg.BaseEjectionTime, err = getDurationFromEnv("REPUTATION_BASE_EJECTION_TIME", cfg.BaseEjectionTime); err != nil {
+		return ReputationConfig{}, err
+	}
+	if cfg.MaxEjectionTime, err = getDurationFromEnv("REPUTATION_MAX_EJECTION_TIME", cfg.MaxEjectionTime); err != nil {
+		return ReputationConfig{}, err
+	}
+	if cfg.MaxEjectionPercent, err = getNonNegativeIntFromEnv("REPUTATION_MAX_EJECTION_PERCENT", cfg.MaxEjectionPercent); err != nil {
+		return ReputationConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// getHealthConfigFromEnv overrides the scheduling, probe timeout and head lag thresholds of health checks
+func getHealthConfigFromEnv(cfg HealthConfig) (HealthConfig, error) {
+	var err error
+
+	if cfg.Timeout, err = getDurationFromEnv("HEALTH_CHECK_TIMEOUT", cfg.Timeout); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.Concurrency, err = getNonNegativeIntFromEnv("HEALTH_CHECK_CONCURRENCY", cfg.Concurrency); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.Jitter, err = getFloatFromEnv("HEALTH_CHECK_JITTER", cfg.Jitter); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.HistorySize, err = getIntFromEnv("HEALTH_HISTORY_SIZE", cfg.HistorySize); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.DegradedBlockLag, err = getNonNegativeIntFromEnv("HEALTH_DEGRADED_BLOCK_LAG", cfg.DegradedBlockLag); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.MaxBlockLag, err = getNonNegativeIntFromEnv("HEALTH_MAX_BLOCK_LAG", cfg.MaxBlockLag); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.DegradedTimeLag, err = getDurationFromEnv("HEALTH_DEGRADED_TIME_LAG", cfg.DegradedTimeLag); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.MaxTimeLag, err = getDurationFromEnv("HEALTH_MAX_TIME_LAG", cfg.MaxTimeLag); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.Passive.ConsecutiveFailures, err = getNonNegativeIntFromEnv("HEALTH_PASSIVE_CONSECUTIVE_FAILURES", cfg.Passive.ConsecutiveFailures); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.Passive.FailureRateThreshold, err = getFloatFromEnv("HEALTH_PASSIVE_FAILURE_RATE", cfg.Passive.FailureRateThreshold); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.Passive.WindowSize, err = getIntFromEnv("HEALTH_PASSIVE_WINDOW_SIZE", cfg.Passive.WindowSize); err != nil {
+		return HealthConfig{}, err
+	}
+	if cfg.Passive.MinRequests, err = getIntFromEnv("HEALTH_PASSIVE_MIN_REQUESTS", cfg.Passive.MinRequests); err != nil {
+		return HealthConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// getRetryConfigFromEnv overrides the retry policy shared by all clients
+func getRetryConfigFromEnv(cfg RetryConfig) (RetryConfig, error) {
+	var err error
+
+	if cfg.MaxAttempts, err = getIntFromEnv("RETRY_MAX_ATTEMPTS", cfg.MaxAttempts); err != nil {
+		return RetryConfig{}, err
+	}
+	if cfg.InitialBackoff, err = getDurationFromEnv("RETRY_INITIAL_BACKOFF", cfg.InitialBackoff); err != nil {
+		return RetryConfig{}, err
+	}
+	if cfg.MaxBackoff, err = getDurationFromEnv("RETRY_MAX_BACKOFF", cfg.MaxBackoff); err != nil {
+		return RetryConfig{}, err
+	}
+	if cfg.Multiplier, err = getFloatFromEnv("RETRY_MULTIPLIER", cfg.Multiplier); err != nil {
+		return RetryConfig{}, err
+	}
+	if cfg.Jitter, err = getFloatFromEnv("RETRY_JITTER", cfg.Jitter); err != nil {
+		return RetryConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// getIntFromEnv reads a positive integer from an environment variable, falling back to a default
+func getIntFromEnv(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a positive integer", key, value)
+	}
+	return parsed, nil
+}
+
+// getNonNegativeIntFromEnv reads an integer from an environment variable for settings where 0 is meaningful,
+// e.g. disables a check, falling back to a default
+func getNonNegativeIntFromEnv(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a non-negative integer", key, value)
+	}
+	return parsed, nil
+}
+
+// getUintFromEnv reads a non-negative integer from an environment variable, falling back to a default
+func getUintFromEnv(key string, defaultValue uint64) (uint64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q, expected a non-negative integer", key, value)
+	}
+	return parsed, nil
+}
+
+// getFloatFromEnv reads a non-negative number from an environment variable, falling back to a default
+func getFloatFromEnv(key string, defaultValue float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a non-negative number", key, value)
+	}
+	return parsed, nil
+}
+
+// getDurationFromEnv reads a positive duration such as "30s" from an environment variable, falling back to a default
+func getDurationFromEnv(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a positive duration such as 30s", key, value)
+	}
+	return parsed, nil
+}
+
+// getBoolFromEnv reads true or false from an environment variable, falling back to a default
+func getBoolFromEnv(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q, expected true or false", key, value)
+	}
+	return parsed, nil
 }
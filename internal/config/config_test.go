@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exampleFile = "../../config.example.yaml"
+
+// writeConfig writes a config file into a temporary directory and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadFile_Layering(t *testing.T) {
+	path := writeConfig(t, `
+request_timeout: 20s
+health_check_interval: 45s
+client_defaults:
+  weight: 2
+clients:
+  - name: file-client
+    url: https://file.example
+  - name: own-timeout
+    url: https://own.example
+    timeout: 3s
+`)
+	t.Setenv("REQUEST_TIMEOUT", "5s")
+	t.Setenv("ETH_CLIENT_TIMEOUT", "7s")
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5*time.Second, cfg.RequestTimeout, "the environment overrides the file")
+	assert.Equal(t, 45*time.Second, cfg.HealthCheckInterval, "the file overrides the defaults")
+	assert.Equal(t, 500, cfg.RPC.MaxBatchSize, "unset settings keep their default")
+
+	require.Len(t, cfg.Clients, 2)
+	assert.Equal(t, "file-client", cfg.Clients[0].Name)
+	assert.Equal(t, 2, cfg.Clients[0].Weight, "clients inherit the client defaults of the file")
+	assert.Equal(t, 7*time.Second, cfg.Clients[0].Timeout, "clients inherit the client defaults of the environment")
+	assert.Equal(t, 3*time.Second, cfg.Clients[1].Timeout, "a client's own setting wins over the defaults")
+}
+
+func TestLoadFile_EnvironmentClientsReplaceFileClients(t *testing.T) {
+	path := writeConfig(t, `
+clients:
+  - name: file-client
+    url: https://file.example
+`)
+	t.Setenv("ETH_CLIENT_1_URL", "https://env.example")
+	t.Setenv("ETH_CLIENT_1_NAME", "env-client")
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Clients, 1)
+	assert.Equal(t, "env-client", cfg.Clients[0].Name)
+	assert.Equal(t, "https://env.example", cfg.Clients[0].URL)
+}
+
+func TestLoadFile_EnvironmentOnly(t *testing.T) {
+	_, err := LoadFile("")
+	assert.ErrorContains(t, err, "no Ethereum clients configured")
+
+	t.Setenv("ETH_CLIENT_1_URL", "https://env.example")
+	cfg, err := LoadFile("")
+	require.NoError(t, err)
+	require.Len(t, cfg.Clients, 1)
+	assert.Equal(t, "client-1", cfg.Clients[0].Name, "unnamed clients are numbered")
+}
+
+func TestLoadFile_RejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"Unknown key", "request_timout: 5s\n", "field request_timout not found"},
+		{"Unknown client key", "clients:\n  - name: a\n    url: https://a.example\n    wieght: 2\n", "clients[0]"},
+		{"Invalid value", "request_timeout: soon\n", "config file"},
+		{"Invalid setting", "clients:\n  - name: a\n    url: https://a.example\nbalance:\n  mode: random\n", `invalid balance.mode "random"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writeConfig(t, tt.content))
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+
+	_, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "reading config file")
+}
+
+func TestLoadFile_AcceptsZeroWhereMeaningful(t *testing.T) {
+	t.Setenv("ETH_CLIENT_1_URL", "https://env.example")
+	t.Setenv("ETH_CLIENT_1_TIER", "0")
+	t.Setenv("CIRCUIT_CONSECUTIVE_FAILURES", "0")
+	t.Setenv("HEALTH_PASSIVE_CONSECUTIVE_FAILURES", "0")
+	t.Setenv("HEALTH_CHECK_CONCURRENCY", "0")
+	t.Setenv("HEALTH_DEGRADED_BLOCK_LAG", "0")
+	t.Setenv("HEALTH_MAX_BLOCK_LAG", "0")
+	t.Setenv("REPUTATION_MAX_EJECTION_PERCENT", "0")
+
+	cfg, err := LoadFile("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.Clients[0].Tier)
+	assert.Equal(t, 0, cfg.ClientDefaults.Breaker.ConsecutiveFailures)
+	assert.Equal(t, 0, cfg.Pool.Health.Passive.ConsecutiveFailures)
+	assert.Equal(t, 0, cfg.Pool.Health.Concurrency)
+	assert.Equal(t, 0, cfg.Pool.Health.DegradedBlockLag)
+	assert.Equal(t, 0, cfg.Pool.Health.MaxBlockLag)
+	assert.Equal(t, 0, cfg.Pool.Reputation.MaxEjectionPercent)
+}
+
+func TestLoadFile_RejectsInvalidEnvironment(t *testing.T) {
+	tests := []struct {
+		key      string
+		value    string
+		expected string
+	}{
+		{"HEALTH_MAX_BLOCK_LAG", "-1", "expected a non-negative integer"},
+		{"ETH_CLIENT_1_TIER", "first", "expected a non-negative integer"},
+		{"ETH_CLIENT_1_WEIGHT", "0", "expected a positive integer"},
+		{"REQUEST_TIMEOUT", "soon", "REQUEST_TIMEOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Setenv("ETH_CLIENT_1_URL", "https://env.example")
+			t.Setenv(tt.key, tt.value)
+
+			_, err := LoadFile("")
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestLoadFile_PoolQuorumCoversTheQuorumStrategy(t *testing.T) {
+	cfg, err := LoadFile(exampleFile)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.Pool.Quorum, "other strategies work with any number of clients")
+
+	t.Setenv("BALANCE_CONSENSUS", ConsensusQuorum)
+	t.Setenv("BALANCE_CONSENSUS_QUORUM", "3")
+	cfg, err = LoadFile(exampleFile)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.Pool.Quorum)
+
+	t.Setenv("POOL_QUORUM", "4")
+	cfg, err = LoadFile(exampleFile)
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.Pool.Quorum, "a higher pool quorum is kept")
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the layout of the YAML config file. Clients are kept as raw nodes,
+// so each of them can be decoded on top of the client defaults.
+type fileConfig struct {
+	Config  `yaml:",inline"`
+	Clients []yaml.Node `yaml:"clients"`
+}
+
+// readFile decodes the YAML config file on top of cfg and returns the raw client entries.
+// Unknown keys are rejected, so typos do not silently fall back to defaults.
+func readFile(path string, cfg *Config) ([]yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	file := fileConfig{Config: *cfg}
+	if err := decodeStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	*cfg = file.Config
+
+	return file.Clients, nil
+}
+
+// decodeClients decodes the client entries of the config file, each on top of the client defaults
+func decodeClients(path string, nodes []yaml.Node, defaults ClientConfig) ([]ClientConfig, error) {
+	clients := make([]ClientConfig, 0, len(nodes))
+	for i := range nodes {
+		data, err := yaml.Marshal(&nodes[i])
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: clients[%d]: %w", path, i, err)
+		}
+
+		client := defaults
+		if err := decodeStrict(data, &client); err != nil {
+			return nil, fmt.Errorf("config file %s: clients[%d]: %w", path, i, err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// decodeStrict decodes a YAML document into v, rejecting unknown keys
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
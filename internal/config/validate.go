@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Validate checks the configuration as a whole and reports every invalid setting at once.
+// Setting names follow the keys of the config file.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.require(c.ServerPort != "", "port is required")
+	v.positive("request_timeout", c.RequestTimeout > 0)
+	v.positive("health_check_interval", c.HealthCheckInterval > 0)
+
+	v.client("client_defaults", c.ClientDefaults, false)
+
+	names := make(map[string]bool, len(c.Clients))
+	for i, client := range c.Clients {
+		field := fmt.Sprintf("clients[%d]", i)
+		v.client(field, client, true)
+		v.require(!names[client.Name], "%s: duplicate client name %q", field, client.Name)
+		names[client.Name] = true
+	}
+
+	v.positive("pool.fanout_timeout", c.Pool.FanOutTimeout > 0)
+	v.positive("pool.quorum", c.Pool.Quorum > 0)
+	v.health("pool.health", c.Pool.Health)
+	v.reputation("pool.reputation", c.Pool.Reputation)
+
+	v.require(len(c.RPC.AllowedMethods) > 0, "rpc.allowed_methods must not be empty")
+	v.require(isValidPolicy(c.RPC.DefaultPolicy), "invalid rpc.default_policy %q, expected %s, %s or %s",
+		c.RPC.DefaultPolicy, PolicyConsensus, PolicyPlurality, PolicyFirst)
+	for method, policy := range c.RPC.MethodPolicies {
+		v.require(isValidPolicy(policy), "invalid consensus policy %q for method %s", policy, method)
+	}
+	v.positive("rpc.max_batch_size", c.RPC.MaxBatchSize > 0)
+	v.routing("rpc.routing", c.RPC.Routing)
+	v.consensus("rpc.consensus", c.RPC.Consensus)
+	v.consensusWeights("rpc.consensus", c.RPC.Consensus, c.Clients)
+
+	v.require(c.Balance.Mode == ModeFanOut || c.Balance.Mode == ModeHedged,
+		"invalid balance.mode %q, expected %s or %s", c.Balance.Mode, ModeFanOut, ModeHedged)
+	v.positive("balance.hedge.delay", c.Balance.Hedge.Delay > 0)
+	v.positive("balance.hedge.max_requests", c.Balance.Hedge.MaxRequests > 0)
+	v.routing("balance.routing", c.Balance.Routing)
+	v.consensus("balance.consensus", c.Balance.Consensus)
+	v.consensusWeights("balance.consensus", c.Balance.Consensus, c.Clients)
+	// The first answer of a hedged query is never compared with the others, so it cannot fail closed
+	v.require(c.Balance.Mode != ModeHedged || c.Balance.Consensus.OnFailure != FailClosed,
+		"balance.consensus.on_failure %s requires balance.mode %s", FailClosed, ModeFanOut)
+
+	v.require(c.Admin.Port == "" || c.Admin.Token != "", "admin.token is required when admin.port is set")
+
+	return errors.Join(v.errs...)
+}
+
+// ValidateClient checks the settings of a single client the way Validate checks the configured clients,
+// for clients added at runtime
+func ValidateClient(c ClientConfig) error {
+	v := &validator{}
+	v.client("client", c, true)
+	return errors.Join(v.errs...)
+}
+
+// validator collects validation errors
+type validator struct {
+	errs []error
+}
+
+func (v *validator) require(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf(format, args...))
+	}
+}
+
+func (v *validator) positive(field string, ok bool) {
+	v.require(ok, "%s must be positive", field)
+}
+
+// client checks a client's settings, named clients also need a valid URL and credentials
+func (v *validator) client(field string, c ClientConfig, named bool) {
+	if named {
+		v.require(c.Name != "", "%s.name is required", field)
+		v.require(validClientURL(c.URL), "%s.url %q must be an http, https, ws or wss URL or an ipc:// or unix:// socket path", field, c.URL)
+		v.auth(field+".auth", c.Auth)
+	}
+
+	v.positive(field+".timeout", c.Timeout > 0)
+	v.positive(field+".max_batch_size", c.MaxBatchSize > 0)
+	v.positive(field+".max_response_size", c.MaxResponseSize > 0)
+	v.positive(field+".weight", c.Weight > 0)
+	v.require(c.Tier >= 0, "%s.tier must not be negative", field)
+	v.require(c.NodeKind == NodeKindDetect || c.NodeKind == NodeKindArchive || c.NodeKind == NodeKindFull,
+		"invalid %s.node_kind %q, expected %s or %s", field, c.NodeKind, NodeKindArchive, NodeKindFull)
+	v.positive(field+".burst", c.Burst > 0)
+	v.require(c.RequestsPerSecond >= 0, "%s.rps must not be negative", field)
+
+	breaker := c.Breaker
+	v.require(breaker.ConsecutiveFailures >= 0, "%s.breaker.consecutive_failures must not be negative", field)
+	v.require(breaker.FailureRateThreshold >= 0 && breaker.FailureRateThreshold <= 1,
+		"invalid %s.breaker.failure_rate %v, expected a ratio between 0 and 1", field, breaker.FailureRateThreshold)
+	v.positive(field+".breaker.window_size", breaker.WindowSize > 0)
+	v.positive(field+".breaker.min_requests", breaker.MinRequests > 0)
+	v.positive(field+".breaker.cooldown", breaker.Cooldown > 0)
+	v.positive(field+".breaker.half_open_probes", breaker.HalfOpenProbes > 0)
+
+	retry := c.Retry
+	v.positive(field+".retry.max_attempts", retry.MaxAttempts > 0)
+	v.positive(field+".retry.initial_backoff", retry.InitialBackoff > 0)
+	v.positive(field+".retry.max_backoff", retry.MaxBackoff > 0)
+	v.positive(field+".retry.multiplier", retry.Multiplier > 0)
+	v.require(retry.Jitter >= 0 && retry.Jitter <= 1, "invalid %s.retry.jitter %v, expected a ratio between 0 and 1", field, retry.Jitter)
+}
+
+// validClientURL reports whether a client URL names a network endpoint or, for IPC, a socket path
+func validClientURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https", "ws", "wss":
+		return parsed.Host != ""
+	case "ipc", "unix":
+		return parsed.Host+parsed.Path != ""
+	default:
+		return false
+	}
+}
+
+// auth checks that a client's credentials match its auth scheme
+func (v *validator) auth(field string, c AuthConfig) {
+	switch c.Type {
+	case AuthNone:
+	case AuthBearer:
+		v.require(c.Token != "", "%s.token is required for bearer auth", field)
+	case AuthBasic:
+		v.require(c.Username != "", "%s.username is required for basic auth", field)
+	case AuthJWT:
+		v.require(c.JWTSecretFile != "", "%s.jwt_secret_file is required for jwt auth", field)
+	default:
+		v.require(false, "invalid %s.type %q, expected %s, %s or %s", field, c.Type, AuthBearer, AuthBasic, AuthJWT)
+	}
+
+	for name := range c.Headers {
+		v.require(name != "", "%s.headers must not contain an empty name", field)
+	}
+}
+
+func (v *validator) health(field string, c HealthConfig) {
+	v.positive(field+".timeout", c.Timeout > 0)
+	v.require(c.Concurrency >= 0, "%s.concurrency must not be negative", field)
+	v.require(c.Jitter >= 0 && c.Jitter < 1, "invalid %s.jitter %v, expected a ratio between 0 and 1", field, c.Jitter)
+	v.positive(field+".history_size", c.HistorySize > 0)
+	v.require(c.DegradedBlockLag >= 0 && c.MaxBlockLag >= 0, "%s block lags must not be negative", field)
+	v.positive(field+".degraded_time_lag", c.DegradedTimeLag > 0)
+	v.positive(field+".max_time_lag", c.MaxTimeLag > 0)
+	v.require(c.DegradedBlockLag <= c.MaxBlockLag, "%s.degraded_block_lag %d exceeds %s.max_block_lag %d", field, c.DegradedBlockLag, field, c.MaxBlockLag)
+	v.require(c.DegradedTimeLag <= c.MaxTimeLag, "%s.degraded_time_lag %v exceeds %s.max_time_lag %v", field, c.DegradedTimeLag, field, c.MaxTimeLag)
+
+	passive := c.Passive
+	v.require(passive.ConsecutiveFailures >= 0, "%s.passive.consecutive_failures must not be negative", field)
+	v.require(passive.FailureRateThreshold >= 0 && passive.FailureRateThreshold <= 1,
+		"invalid %s.passive.failure_rate %v, expected a ratio between 0 and 1", field, passive.FailureRateThreshold)
+	v.positive(field+".passive.window_size", passive.WindowSize > 0)
+	v.positive(field+".passive.min_requests", passive.MinRequests > 0)
+}
+
+func (v *validator) reputation(field string, c ReputationConfig) {
+	v.require(c.DisagreementThreshold >= 0 && c.DisagreementThreshold <= 1,
+		"invalid %s.disagreement_threshold %v, expected a ratio between 0 and 1", field, c.DisagreementThreshold)
+	v.positive(field+".window_size", c.WindowSize > 0)
+	v.positive(field+".min_votes", c.MinVotes > 0)
+	v.require(c.MinVotes <= c.WindowSize, "%s.min_votes %d exceeds %s.window_size %d", field, c.MinVotes, field, c.WindowSize)
+	v.positive(field+".base_ejection_time", c.BaseEjectionTime > 0)
+	v.require(c.BaseEjectionTime <= c.MaxEjectionTime, "%s.base_ejection_time %v exceeds %s.max_ejection_time %v",
+		field, c.BaseEjectionTime, field, c.MaxEjectionTime)
+	v.require(c.MaxEjectionPercent >= 0 && c.MaxEjectionPercent <= 100,
+		"invalid %s.max_ejection_percent %d, expected a percentage between 0 and 100", field, c.MaxEjectionPercent)
+}
+
+func (v *validator) consensus(field string, c ConsensusConfig) {
+	switch c.Strategy {
+	case ConsensusPlurality, ConsensusMajority, ConsensusQuorum, ConsensusUnanimous, ConsensusWeighted, ConsensusHighestBlock:
+	default:
+		v.require(false, "invalid %s.strategy %q", field, c.Strategy)
+	}
+	v.positive(field+".quorum", c.Quorum > 0)
+	v.require(c.OnFailure == FailOpen || c.OnFailure == FailClosed,
+		"invalid %s.on_failure %q, expected open or closed", field, c.OnFailure)
+	for name, weight := range c.Weights {
+		v.require(weight >= 0, "%s.weights: weight of client %s must not be negative", field, name)
+	}
+}
+
+// consensusWeights rejects a weighted strategy that leaves no configured client with a trust weight above 0,
+// a weight of 0 excludes a client from the vote
+func (v *validator) consensusWeights(field string, c ConsensusConfig, clients []ClientConfig) {
+	if c.Strategy != ConsensusWeighted || len(clients) == 0 {
+		return
+	}
+	for _, client := range clients {
+		if weight, ok := c.Weights[client.Name]; !ok || weight > 0 {
+			return
+		}
+	}
+	v.require(false, "%s.weights must give at least one client a weight above 0", field)
+}
+
+func (v *validator) routing(field string, c RoutingConfig) {
+	switch c.Strategy {
+	case RoutingFanOut, RoutingRoundRobin, RoutingLeastLatency, RoutingWeightedRandom, RoutingRandomN:
+	default:
+		v.require(false, "invalid %s.strategy %q", field, c.Strategy)
+	}
+	v.positive(field+".count", c.Count > 0)
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validConfig returns the default configuration with two clients, which passes validation
+func validConfig() *Config {
+	cfg := Default()
+	for _, name := range []string{"client1", "client2"} {
+		client := cfg.ClientDefaults
+		client.Name = name
+		client.URL = "https://" + name + ".example"
+		cfg.Clients = append(cfg.Clients, client)
+	}
+	cfg.applyDerivedDefaults()
+	return cfg
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, validConfig().Validate())
+
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		expected string
+	}{
+		{"Port", func(c *Config) { c.ServerPort = "" }, "port is required"},
+		{"Request timeout", func(c *Config) { c.RequestTimeout = 0 }, "request_timeout must be positive"},
+		{"Health check interval", func(c *Config) { c.HealthCheckInterval = 0 }, "health_check_interval must be positive"},
+		{"Client defaults", func(c *Config) { c.ClientDefaults.Weight = 0 }, "client_defaults.weight must be positive"},
+		{"Client name", func(c *Config) { c.Clients[0].Name = "" }, "clients[0].name is required"},
+		{"Duplicate client name", func(c *Config) { c.Clients[1].Name = "client1" }, `clients[1]: duplicate client name "client1"`},
+		{"Client URL", func(c *Config) { c.Clients[0].URL = "ftp://client1.example" }, `clients[0].url "ftp://client1.example" must be`},
+		{"Client URL without host", func(c *Config) { c.Clients[0].URL = "https://" }, "clients[0].url"},
+		{"Client timeout", func(c *Config) { c.Clients[0].Timeout = 0 }, "clients[0].timeout must be positive"},
+		{"Client max batch size", func(c *Config) { c.Clients[0].MaxBatchSize = 0 }, "clients[0].max_batch_size must be positive"},
+		{"Client max response size", func(c *Config) { c.Clients[0].MaxResponseSize = 0 }, "clients[0].max_response_size must be positive"},
+		{"Client tier", func(c *Config) { c.Clients[0].Tier = -1 }, "clients[0].tier must not be negative"},
+		{"Client node kind", func(c *Config) { c.Clients[0].NodeKind = "light" }, `invalid clients[0].node_kind "light"`},
+		{"Client burst", func(c *Config) { c.Clients[0].Burst = 0 }, "clients[0].burst must be positive"},
+		{"Client rps", func(c *Config) { c.Clients[0].RequestsPerSecond = -1 }, "clients[0].rps must not be negative"},
+		{"Auth type", func(c *Config) { c.Clients[0].Auth.Type = "oauth" }, `invalid clients[0].auth.type "oauth"`},
+		{"Bearer token", func(c *Config) { c.Clients[0].Auth.Type = AuthBearer }, "clients[0].auth.token is required for bearer auth"},
+		{"Basic username", func(c *Config) { c.Clients[0].Auth.Type = AuthBasic }, "clients[0].auth.username is required for basic auth"},
+		{"JWT secret", func(c *Config) { c.Clients[0].Auth.Type = AuthJWT }, "clients[0].auth.jwt_secret_file is required for jwt auth"},
+		{"Auth header name", func(c *Config) { c.Clients[0].Auth.Headers = map[string]string{"": "value"} }, "clients[0].auth.headers must not contain an empty name"},
+		{"Breaker consecutive failures", func(c *Config) { c.Clients[0].Breaker.ConsecutiveFailures = -1 }, "clients[0].breaker.consecutive_failures must not be negative"},
+		{"Breaker failure rate", func(c *Config) { c.Clients[0].Breaker.FailureRateThreshold = 1.5 }, "invalid clients[0].breaker.failure_rate 1.5"},
+		{"Breaker window size", func(c *Config) { c.Clients[0].Breaker.WindowSize = 0 }, "clients[0].breaker.window_size must be positive"},
+		{"Breaker min requests", func(c *Config) { c.Clients[0].Breaker.MinRequests = 0 }, "clients[0].breaker.min_requests must be positive"},
+		{"Breaker cooldown", func(c *Config) { c.Clients[0].Breaker.Cooldown = 0 }, "clients[0].breaker.cooldown must be positive"},
+		{"Breaker half-open probes", func(c *Config) { c.Clients[0].Breaker.HalfOpenProbes = 0 }, "clients[0].breaker.half_open_probes must be positive"},
+		{"Retry max attempts", func(c *Config) { c.Clients[0].Retry.MaxAttempts = 0 }, "clients[0].retry.max_attempts must be positive"},
+		{"Retry initial backoff", func(c *Config) { c.Clients[0].Retry.InitialBackoff = 0 }, "clients[0].retry.initial_backoff must be positive"},
+		{"Retry max backoff", func(c *Config) { c.Clients[0].Retry.MaxBackoff = 0 }, "clients[0].retry.max_backoff must be positive"},
+		{"Retry multiplier", func(c *Config) { c.Clients[0].Retry.Multiplier = 0 }, "clients[0].retry.multiplier must be positive"},
+		{"Retry jitter", func(c *Config) { c.Clients[0].Retry.Jitter = -0.1 }, "invalid clients[0].retry.jitter -0.1"},
+		{"Fan-out timeout", func(c *Config) { c.Pool.FanOutTimeout = 0 }, "pool.fanout_timeout must be positive"},
+		{"Pool quorum", func(c *Config) { c.Pool.Quorum = 0 }, "pool.quorum must be positive"},
+		{"Health timeout", func(c *Config) { c.Pool.Health.Timeout = 0 }, "pool.health.timeout must be positive"},
+		{"Health concurrency", func(c *Config) { c.Pool.Health.Concurrency = -1 }, "pool.health.concurrency must not be negative"},
+		{"Health jitter", func(c *Config) { c.Pool.Health.Jitter = 1 }, "invalid pool.health.jitter 1"},
+		{"Health history size", func(c *Config) { c.Pool.Health.HistorySize = 0 }, "pool.health.history_size must be positive"},
+		{"Health block lag", func(c *Config) { c.Pool.Health.DegradedBlockLag = -1 }, "pool.health block lags must not be negative"},
+		{"Health degraded time lag", func(c *Config) { c.Pool.Health.DegradedTimeLag = 0 }, "pool.health.degraded_time_lag must be positive"},
+		{"Health max time lag", func(c *Config) { c.Pool.Health.MaxTimeLag = 0 }, "pool.health.max_time_lag must be positive"},
+		{"Health block lags order", func(c *Config) { c.Pool.Health.DegradedBlockLag = 20 }, "pool.health.degraded_block_lag 20 exceeds pool.health.max_block_lag 10"},
+		{"Health time lags order", func(c *Config) { c.Pool.Health.DegradedTimeLag = time.Hour }, "pool.health.degraded_time_lag 1h0m0s exceeds pool.health.max_time_lag 2m0s"},
+		{"Passive consecutive failures", func(c *Config) { c.Pool.Health.Passive.ConsecutiveFailures = -1 }, "pool.health.passive.consecutive_failures must not be negative"},
+		{"Passive failure rate", func(c *Config) { c.Pool.Health.Passive.FailureRateThreshold = 2 }, "invalid pool.health.passive.failure_rate 2"},
+		{"Passive window size", func(c *Config) { c.Pool.Health.Passive.WindowSize = 0 }, "pool.health.passive.window_size must be positive"},
+		{"Passive min requests", func(c *Config) { c.Pool.Health.Passive.MinRequests = 0 }, "pool.health.passive.min_requests must be positive"},
+		{"Disagreement threshold", func(c *Config) { c.Pool.Reputation.DisagreementThreshold = 1.1 }, "invalid pool.reputation.disagreement_threshold 1.1"},
+		{"Reputation window size", func(c *Config) { c.Pool.Reputation.WindowSize = 0 }, "pool.reputation.window_size must be positive"},
+		{"Reputation min votes", func(c *Config) { c.Pool.Reputation.MinVotes = 0 }, "pool.reputation.min_votes must be positive"},
+		{"Reputation min votes over window", func(c *Config) { c.Pool.Reputation.MinVotes = 60 }, "pool.reputation.min_votes 60 exceeds pool.reputation.window_size 50"},
+		{"Base ejection time", func(c *Config) { c.Pool.Reputation.BaseEjectionTime = 0 }, "pool.reputation.base_ejection_time must be positive"},
+		{"Ejection times order", func(c *Config) { c.Pool.Reputation.BaseEjectionTime = time.Hour }, "pool.reputation.base_ejection_time 1h0m0s exceeds pool.reputation.max_ejection_time 5m0s"},
+		{"Max ejection percent", func(c *Config) { c.Pool.Reputation.MaxEjectionPercent = 101 }, "invalid pool.reputation.max_ejection_percent 101"},
+		{"Allowed methods", func(c *Config) { c.RPC.AllowedMethods = nil }, "rpc.allowed_methods must not be empty"},
+		{"Default policy", func(c *Config) { c.RPC.DefaultPolicy = "random" }, `invalid rpc.default_policy "random"`},
+		{"Method policy", func(c *Config) { c.RPC.MethodPolicies["eth_call"] = "random" }, `invalid consensus policy "random" for method eth_call`},
+		{"RPC max batch size", func(c *Config) { c.RPC.MaxBatchSize = 0 }, "rpc.max_batch_size must be positive"},
+		{"RPC routing strategy", func(c *Config) { c.RPC.Routing.Strategy = "sticky" }, `invalid rpc.routing.strategy "sticky"`},
+		{"RPC routing count", func(c *Config) { c.RPC.Routing.Count = 0 }, "rpc.routing.count must be positive"},
+		{"RPC consensus strategy", func(c *Config) { c.RPC.Consensus.Strategy = "loudest" }, `invalid rpc.consensus.strategy "loudest"`},
+		{"RPC consensus quorum", func(c *Config) { c.RPC.Consensus.Quorum = 0 }, "rpc.consensus.quorum must be positive"},
+		{"RPC consensus on failure", func(c *Config) { c.RPC.Consensus.OnFailure = "retry" }, `invalid rpc.consensus.on_failure "retry"`},
+		{"RPC negative weight", func(c *Config) { c.RPC.Consensus.Weights = map[string]float64{"client1": -1} }, "rpc.consensus.weights: weight of client client1 must not be negative"},
+		{"RPC weights exclude every client", func(c *Config) {
+			c.RPC.Consensus.Strategy = ConsensusWeighted
+			c.RPC.Consensus.Weights = map[string]float64{"client1": 0, "client2": 0}
+		}, "rpc.consensus.weights must give at least one client a weight above 0"},
+		{"Balance mode", func(c *Config) { c.Balance.Mode = "race" }, `invalid balance.mode "race"`},
+		{"Hedge delay", func(c *Config) { c.Balance.Hedge.Delay = 0 }, "balance.hedge.delay must be positive"},
+		{"Hedge max requests", func(c *Config) { c.Balance.Hedge.MaxRequests = 0 }, "balance.hedge.max_requests must be positive"},
+		{"Balance routing strategy", func(c *Config) { c.Balance.Routing.Strategy = "sticky" }, `invalid balance.routing.strategy "sticky"`},
+		{"Balance consensus strategy", func(c *Config) { c.Balance.Consensus.Strategy = "loudest" }, `invalid balance.consensus.strategy "loudest"`},
+		{"Balance weights exclude every client", func(c *Config) {
+			c.Balance.Consensus.Strategy = ConsensusWeighted
+			c.Balance.Consensus.Weights = map[string]float64{"client1": 0, "client2": 0}
+		}, "balance.consensus.weights must give at least one client a weight above 0"},
+		{"Hedged mode cannot fail closed", func(c *Config) {
+			c.Balance.Mode = ModeHedged
+			c.Balance.Consensus.OnFailure = FailClosed
+		}, "balance.consensus.on_failure closed requires balance.mode fanout"},
+		{"Admin token", func(c *Config) { c.Admin.Port = "9090" }, "admin.token is required when admin.port is set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			assert.ErrorContains(t, cfg.Validate(), tt.expected)
+		})
+	}
+}
+
+func TestConfig_ValidateAcceptsCrossFieldExceptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"Weights keep a client in the vote", func(c *Config) {
+			c.Balance.Consensus.Strategy = ConsensusWeighted
+			c.Balance.Consensus.Weights = map[string]float64{"client1": 0}
+		}},
+		{"Zero weights without the weighted strategy", func(c *Config) {
+			c.RPC.Consensus.Weights = map[string]float64{"client1": 0, "client2": 0}
+		}},
+		{"Hedged mode failing open", func(c *Config) { c.Balance.Mode = ModeHedged }},
+		{"Fan-out mode failing closed", func(c *Config) { c.Balance.Consensus.OnFailure = FailClosed }},
+		{"Admin API with a token", func(c *Config) {
+			c.Admin.Port = "9090"
+			c.Admin.Token = "secret"
+		}},
+		{"IPC client", func(c *Config) { c.Clients[0].URL = "ipc:///data/geth.ipc" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			assert.NoError(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfig_ValidateReportsEverySetting(t *testing.T) {
+	cfg := validConfig()
+	cfg.RequestTimeout = 0
+	cfg.Clients[1].Weight = 0
+	cfg.Balance.Mode = "race"
+
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "request_timeout must be positive")
+	assert.ErrorContains(t, err, "clients[1].weight must be positive")
+	assert.ErrorContains(t, err, `invalid balance.mode "race"`)
+}
+
+func TestValidateClient(t *testing.T) {
+	client := validConfig().Clients[0]
+	assert.NoError(t, ValidateClient(client))
+
+	client.Name = ""
+	client.Tier = -1
+	err := ValidateClient(client)
+	assert.ErrorContains(t, err, "client.name is required")
+	assert.ErrorContains(t, err, "client.tier must not be negative")
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce collapses the burst of events an editor produces when saving a file into one reload
+const reloadDebounce = 200 * time.Millisecond
+
+// Watcher reloads the configuration when the process receives SIGHUP or the config file changes.
+// A reload is all or nothing: a configuration that fails to load or validate, or that apply
+// rejects, leaves the running configuration untouched.
+type Watcher struct {
+	path  string
+	apply func(*Config) error
+}
+
+// NewWatcher creates a watcher for the config file at path, an empty path only reloads on SIGHUP.
+// apply is called with every valid configuration and must either apply all of it or nothing.
+func NewWatcher(path string, apply func(*Config) error) *Watcher {
+	return &Watcher{path: path, apply: apply}
+}
+
+// Run watches for reload triggers until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if w.path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("watching config file: %w", err)
+		}
+		defer watcher.Close()
+
+		// The directory is watched because editors and Kubernetes config maps replace the file instead of writing to it
+		if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+			return fmt.Errorf("watching config file: %w", err)
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			log.Println("Received SIGHUP, reloading configuration...")
+			w.Reload()
+		case event := <-events:
+			if w.affects(event) {
+				debounce = time.After(reloadDebounce)
+			}
+		case <-debounce:
+			log.Printf("Config file %s changed, reloading configuration...\n", w.path)
+			w.Reload()
+		case err := <-errs:
+			log.Printf("Error watching config file %s: %v\n", w.path, err)
+		}
+	}
+}
+
+// Reload loads, validates and applies the configuration
+func (w *Watcher) Reload() error {
+	cfg, err := LoadFile(w.path)
+	if err == nil {
+		err = w.apply(cfg)
+	}
+
+	if err != nil {
+		log.Printf("Configuration reload rejected, keeping the running configuration: %v\n", err)
+		metrics.RecordConfigReload(false)
+		return err
+	}
+
+	log.Println("Configuration reloaded")
+	metrics.RecordConfigReload(true)
+	return nil
+}
+
+// affects reports whether a file system event may have changed the config file.
+// Kubernetes swaps the ..data symlink when a mounted config map is updated.
+func (w *Watcher) affects(event fsnotify.Event) bool {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) && !event.Has(fsnotify.Remove) {
+		return false
+	}
+	return filepath.Clean(event.Name) == filepath.Clean(w.path) || filepath.Base(event.Name) == "..data"
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const watchedConfig = `
+clients:
+  - name: client1
+    url: https://client1.example
+`
+
+func TestWatcher_ReloadAppliesValidConfig(t *testing.T) {
+	path := writeConfig(t, watchedConfig)
+
+	var running *Config
+	watcher := NewWatcher(path, func(cfg *Config) error {
+		running = cfg
+		return nil
+	})
+
+	require.NoError(t, watcher.Reload())
+	require.NotNil(t, running)
+	assert.Equal(t, "client1", running.Clients[0].Name)
+}
+
+func TestWatcher_RejectedReloadKeepsRunningConfig(t *testing.T) {
+	path := writeConfig(t, watchedConfig)
+
+	var running *Config
+	watcher := NewWatcher(path, func(cfg *Config) error {
+		running = cfg
+		return nil
+	})
+	require.NoError(t, watcher.Reload())
+	applied := running
+
+	// A file that fails validation is never handed to apply
+	require.NoError(t, os.WriteFile(path, []byte(watchedConfig+"balance:\n  mode: race\n"), 0o600))
+	assert.ErrorContains(t, watcher.Reload(), `invalid balance.mode "race"`)
+	assert.Same(t, applied, running)
+
+	require.NoError(t, os.WriteFile(path, []byte("clients: ["), 0o600))
+	assert.Error(t, watcher.Reload())
+	assert.Same(t, applied, running)
+}
+
+func TestWatcher_ReloadReportsRejectedApply(t *testing.T) {
+	path := writeConfig(t, watchedConfig)
+	rejected := errors.New("client1 failed its health check")
+
+	watcher := NewWatcher(path, func(cfg *Config) error { return rejected })
+	assert.ErrorIs(t, watcher.Reload(), rejected)
+}
+
+func TestWatcher_ReloadsOnFileWrite(t *testing.T) {
+	path := writeConfig(t, watchedConfig)
+
+	reloads := make(chan *Config, 10)
+	watcher := NewWatcher(path, func(cfg *Config) error {
+		reloads <- cfg
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+
+	changed := watchedConfig + "request_timeout: 20s\n"
+	// The file is written until the watcher picks it up, as Run starts watching asynchronously.
+	// Writes are spaced beyond the debounce, which every event restarts.
+	var reloaded *Config
+	require.Eventually(t, func() bool {
+		select {
+		case reloaded = <-reloads:
+			return true
+		default:
+			assert.NoError(t, os.WriteFile(path, []byte(changed), 0o600))
+			return false
+		}
+	}, 5*time.Second, 2*reloadDebounce)
+	assert.Equal(t, 20*time.Second, reloaded.RequestTimeout)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not stop with its context")
+	}
+}
+
+func TestWatcher_Affects(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	watcher := NewWatcher(path, nil)
+
+	tests := []struct {
+		name     string
+		event    fsnotify.Event
+		expected bool
+	}{
+		{"Write", fsnotify.Event{Name: path, Op: fsnotify.Write}, true},
+		{"Replaced by an editor", fsnotify.Event{Name: path, Op: fsnotify.Create}, true},
+		{"Renamed", fsnotify.Event{Name: path, Op: fsnotify.Rename}, true},
+		{"Removed", fsnotify.Event{Name: path, Op: fsnotify.Remove}, true},
+		{"Permissions changed", fsnotify.Event{Name: path, Op: fsnotify.Chmod}, false},
+		{"Other file", fsnotify.Event{Name: filepath.Join(dir, "other.yaml"), Op: fsnotify.Write}, false},
+		{"Config map update", fsnotify.Event{Name: filepath.Join(dir, "..data"), Op: fsnotify.Create}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, watcher.affects(tt.event))
+		})
+	}
+}
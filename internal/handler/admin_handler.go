@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// drainTimeout bounds how long drain and remove requests wait for in-flight upstream requests
+const drainTimeout = 30 * time.Second
+
+// AdminHandler handles the admin endpoints that manage the client pool at runtime
+type AdminHandler struct {
+	clientPool     *client.PoolStruct
+	clientDefaults config.ClientConfig
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(clientPool *client.PoolStruct, clientDefaults config.ClientConfig) *AdminHandler {
+	return &AdminHandler{
+		clientPool:     clientPool,
+		clientDefaults: clientDefaults,
+	}
+}
+
+// addClientRequest is the body of a request adding a client, unset fields take the configured defaults.
+// Numeric settings are pointers so that an explicit 0, e.g. the highest tier, is told apart from an unset field.
+type addClientRequest struct {
+	Name              string         `json:"name"`
+	URL               string         `json:"url"`
+	Weight            *int           `json:"weight"`
+	Tier              *int           `json:"tier"`
+	NodeKind          string         `json:"node_kind"`
+	StateWindow       *uint64        `json:"state_window"`
+	Timeout           string         `json:"timeout"`
+	MaxBatchSize      *int           `json:"max_batch_size"`
+	MaxResponseSize   *int           `json:"max_response_size"`
+	RequestsPerSecond *float64       `json:"rps"`
+	Burst             *int           `json:"burst"`
+	Auth              *addClientAuth `json:"auth"`
+}
+
+// addClientAuth holds the credentials of a client added at runtime
+type addClientAuth struct {
+	Type          string            `json:"type"`
+	Token         string            `json:"token"`
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	JWTSecretFile string            `json:"jwt_secret_file"`
+	Headers       map[string]string `json:"headers"`
+}
+
+// ListClients returns the live state of every client
+func (h *AdminHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	writeAdminResponse(w, http.StatusOK, h.clientPool.Status())
+}
+
+// GetClient returns the live state of a single client
+func (h *AdminHandler) GetClient(w http.ResponseWriter, r *http.Request) {
+	status, err := h.clientPool.ClientStatus(chi.URLParam(r, "name"))
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, status)
+}
+
+// GetClientHealth returns the recent health check results of a single client, oldest first
+func (h *AdminHandler) GetClientHealth(w http.ResponseWriter, r *http.Request) {
+	history, err := h.clientPool.HealthHistory(chi.URLParam(r, "name"))
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, history)
+}
+
+// AddClient adds a client to the pool
+func (h *AdminHandler) AddClient(w http.ResponseWriter, r *http.Request) {
+	var req addClientRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeAdminResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+		return
+	}
+
+	cfg, err := h.clientConfig(req)
+	if err != nil {
+		writeAdminResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	status, err := h.clientPool.AddClient(r.Context(), cfg)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeAdminResponse(w, http.StatusCreated, status)
+}
+
+// RemoveClient takes a client out of the pool once its in-flight requests have finished
+func (h *AdminHandler) RemoveClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), drainTimeout)
+	defer cancel()
+
+	if err := h.clientPool.RemoveClient(ctx, chi.URLParam(r, "name")); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CordonClient takes a client out of rotation until its override is cleared
+func (h *AdminHandler) CordonClient(w http.ResponseWriter, r *http.Request) {
+	h.setOverride(w, r, client.OverrideCordoned)
+}
+
+// EnableClient keeps a client in rotation regardless of health checks until its override is cleared
+func (h *AdminHandler) EnableClient(w http.ResponseWriter, r *http.Request) {
+	h.setOverride(w, r, client.OverrideEnabled)
+}
+
+// ClearOverride hands routing of a client back to health checks
+func (h *AdminHandler) ClearOverride(w http.ResponseWriter, r *http.Request) {
+	h.setOverride(w, r, client.OverrideNone)
+}
+
+// DrainClient cordons a client and responds once its in-flight requests have finished
+func (h *AdminHandler) DrainClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), drainTimeout)
+	defer cancel()
+
+	status, err := h.clientPool.Drain(ctx, chi.URLParam(r, "name"))
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, status)
+}
+
+func (h *AdminHandler) setOverride(w http.ResponseWriter, r *http.Request, override client.Override) {
+	status, err := h.clientPool.SetOverride(chi.URLParam(r, "name"), override)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, status)
+}
+
+// clientConfig applies the request on top of the configured client defaults and validates
+// the result like the clients of the configuration
+func (h *AdminHandler) clientConfig(req addClientRequest) (config.ClientConfig, error) {
+	cfg := h.clientDefaults
+	cfg.Name = req.Name
+	cfg.URL = req.URL
+
+	if cfg.Name == "" || cfg.URL == "" {
+		return config.ClientConfig{}, errors.New("name and url are required")
+	}
+
+	if req.Timeout != "" {
+		timeout, err := time.ParseDuration(req.Timeout)
+		if err != nil {
+			return config.ClientConfig{}, fmt.Errorf("invalid timeout %q, expected a positive duration such as 10s", req.Timeout)
+		}
+		cfg.Timeout = timeout
+	}
+	if req.Weight != nil {
+		cfg.Weight = *req.Weight
+	}
+	if req.Tier != nil {
+		cfg.Tier = *req.Tier
+	}
+	if req.NodeKind != config.NodeKindDetect {
+		cfg.NodeKind = req.NodeKind
+	}
+	if req.StateWindow != nil {
+		cfg.StateWindow = *req.StateWindow
+	}
+	if req.MaxBatchSize != nil {
+		cfg.MaxBatchSize = *req.MaxBatchSize
+	}
+	if req.MaxResponseSize != nil {
+		cfg.MaxResponseSize = *req.MaxResponseSize
+	}
+	if req.RequestsPerSecond != nil {
+		cfg.RequestsPerSecond = *req.RequestsPerSecond
+		// Without an explicit burst the bucket holds one second worth of requests
+		cfg.Burst = max(int(math.Ceil(*req.RequestsPerSecond)), 1)
+	}
+	if req.Burst != nil {
+		cfg.Burst = *req.Burst
+	}
+
+	if req.Auth != nil {
+		cfg.Auth = config.AuthConfig{
+			Type:          req.Auth.Type,
+			Token:         req.Auth.Token,
+			Username:      req.Auth.Username,
+			Password:      req.Auth.Password,
+			JWTSecretFile: req.Auth.JWTSecretFile,
+			Headers:       req.Auth.Headers,
+		}
+	}
+
+	if err := config.ValidateClient(cfg); err != nil {
+		return config.ClientConfig{}, err
+	}
+	return cfg, nil
+}
+
+// writeAdminError maps pool administration errors to HTTP statuses
+func writeAdminError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, client.ErrClientNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, client.ErrClientExists):
+		status = http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+	writeAdminResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeAdminResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
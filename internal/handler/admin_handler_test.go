@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandler_AddClient(t *testing.T) {
+	server := rpctest.NewServer()
+	defer server.Close()
+	server.HandleHead(1000, 1700000000)
+	server.HandleResult("eth_syncing", false)
+
+	pool, err := client.NewPool([]config.ClientConfig{{URL: server.URL, Name: "node", Timeout: time.Second}}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	defaults := config.Default().ClientDefaults
+	defaults.Tier = 1
+	defaults.Weight = 3
+	// Loading the configuration derives the burst from the request rate
+	defaults.Burst = 1
+	handler := NewAdminHandler(pool, defaults)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"unsupported scheme", `{"name":"ftp","url":"ftp://example.com"}`, http.StatusBadRequest, "client.url"},
+		{"not a url", `{"name":"garbage","url":"not a url"}`, http.StatusBadRequest, "client.url"},
+		{"unknown auth type", `{"name":"auth","url":"` + server.URL + `","auth":{"type":"digest"}}`, http.StatusBadRequest, "client.auth.type"},
+		{"bearer without token", `{"name":"bearer","url":"` + server.URL + `","auth":{"type":"bearer"}}`, http.StatusBadRequest, "client.auth.token"},
+		{"unknown node kind", `{"name":"kind","url":"` + server.URL + `","node_kind":"light"}`, http.StatusBadRequest, "client.node_kind"},
+		{"zero weight", `{"name":"weightless","url":"` + server.URL + `","weight":0}`, http.StatusBadRequest, "client.weight"},
+		{"negative tier", `{"name":"negative","url":"` + server.URL + `","tier":-1}`, http.StatusBadRequest, "client.tier"},
+		{"highest tier", `{"name":"primary","url":"` + server.URL + `","tier":0}`, http.StatusCreated, ""},
+		{"defaults", `{"name":"secondary","url":"` + server.URL + `"}`, http.StatusCreated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.AddClient(recorder, httptest.NewRequest(http.MethodPost, "/admin/clients", strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.expectedStatus, recorder.Code, recorder.Body.String())
+			if tt.expectedError != "" {
+				assert.Contains(t, recorder.Body.String(), tt.expectedError)
+			}
+		})
+	}
+
+	primary, err := pool.ClientStatus("primary")
+	require.NoError(t, err)
+	assert.Equal(t, 0, primary.Tier, "an explicit 0 overrides the default tier")
+	assert.Equal(t, 3, primary.Weight)
+
+	secondary, err := pool.ClientStatus("secondary")
+	require.NoError(t, err)
+	assert.Equal(t, 1, secondary.Tier)
+
+	assert.Len(t, pool.GetAllClients(), 3, "rejected clients are not added")
+}
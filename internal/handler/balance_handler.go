@@ -3,11 +3,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/config"
 	"github.com/bersh/alluvial_test_1/internal/service"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -20,8 +24,8 @@ type BalanceHandler struct {
 }
 
 // NewBalanceHandler creates a new balance handler
-func NewBalanceHandler(clientPool *client.PoolStruct, requestTimeout time.Duration) *BalanceHandler {
-	balanceService := service.NewBalanceService(clientPool)
+func NewBalanceHandler(clientPool *client.PoolStruct, requestTimeout time.Duration, balanceConfig config.BalanceConfig) *BalanceHandler {
+	balanceService := service.NewBalanceService(clientPool, balanceConfig)
 	return &BalanceHandler{
 		clientPool:     clientPool,
 		requestTimeout: requestTimeout,
@@ -44,23 +48,122 @@ func (h *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	// Normalize the address
 	address = common.HexToAddress(address).Hex()
 
-	blockParam := r.URL.Query().Get("block")
-	if blockParam == "" {
-		blockParam = "latest"
+	block, err := parseBlockQuery(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Latency-sensitive callers can ask for hedged requests instead of the default mode
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != config.ModeFanOut && mode != config.ModeHedged {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid mode, expected fanout or hedged"})
+		return
+	}
+
+	// Callers that prefer an error to a disputed balance, or the other way round, can override the configuration
+	onFailure := r.URL.Query().Get("on_failure")
+	if onFailure != "" && onFailure != config.FailOpen && onFailure != config.FailClosed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid on_failure, expected open or closed"})
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
 	defer cancel()
 
-	balance, err := h.balanceService.GetBalance(ctx, address, blockParam)
+	result, err := h.balanceService.GetBalanceWithOptions(ctx, address, block, service.BalanceOptions{
+		Mode:      mode,
+		OnFailure: onFailure,
+	})
+	var consensusErr *service.ConsensusError
+	if errors.As(err, &consensusErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(newConsensusErrorResponse(consensusErr))
+		return
+	}
 	if err != nil {
+		// Historical queries no configured client can answer and hedged fail-closed queries will not succeed on retry
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, client.ErrNoStateHistory) || errors.Is(err, service.ErrHedgedFailClosed) {
+			status = http.StatusBadRequest
+		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusServiceUnavailable)
+		w.WriteHeader(status)
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
+	if result.Balance == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no balance returned by the clients"})
+		return
+	}
+
+	// The block is included whenever the balance was read at a known block number
+	response := balanceResponse{Balance: result.Balance.String()}
+	if result.BlockNumber != nil {
+		response.Block = strconv.FormatUint(*result.BlockNumber, 10)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"balance": balance.String()})
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseBlockQuery reads the block parameter of a balance request, defaulting to latest.
+// A block hash may be combined with requireCanonical=true as defined by EIP-1898.
+func parseBlockQuery(r *http.Request) (client.BlockParameter, error) {
+	query := r.URL.Query()
+
+	block := client.LatestBlock
+	if value := query.Get("block"); value != "" {
+		var err error
+		if block, err = client.ParseBlockParameter(value); err != nil {
+			return client.BlockParameter{}, err
+		}
+	}
+
+	if value := query.Get("requireCanonical"); value != "" {
+		requireCanonical, err := strconv.ParseBool(value)
+		if err != nil {
+			return client.BlockParameter{}, fmt.Errorf("invalid requireCanonical %q, expected true or false", value)
+		}
+		return block.WithRequireCanonical(requireCanonical)
+	}
+
+	return block, nil
+}
+
+// balanceResponse is the body of a successful balance request
+type balanceResponse struct {
+	Balance string `json:"balance"`
+	Block   string `json:"block,omitempty"`
+}
+
+// consensusErrorResponse is the body of a fail-closed balance request the clients did not agree on
+type consensusErrorResponse struct {
+	Error     string            `json:"error"`
+	Conflicts []balanceConflict `json:"conflicts"`
+}
+
+// balanceConflict is one of the disputed balances and the clients that returned it
+type balanceConflict struct {
+	Balance string   `json:"balance"`
+	Clients []string `json:"clients"`
+}
+
+func newConsensusErrorResponse(err *service.ConsensusError) consensusErrorResponse {
+	response := consensusErrorResponse{Error: err.Error(), Conflicts: make([]balanceConflict, len(err.Conflicts))}
+	for i, conflict := range err.Conflicts {
+		response.Conflicts[i] = balanceConflict{Balance: conflict.Value, Clients: conflict.Clients}
+	}
+	return response
 }
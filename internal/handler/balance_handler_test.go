@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceHandler_HedgedFailClosedIsRejected(t *testing.T) {
+	var clients []config.ClientConfig
+	for _, balance := range []string{"0x3e8", "0x7d0"} {
+		server := rpctest.NewServer()
+		defer server.Close()
+		server.HandleResult("eth_getBalance", balance)
+		clients = append(clients, config.ClientConfig{URL: server.URL, Name: "node-" + balance, Timeout: time.Second})
+	}
+
+	pool, err := client.NewPool(clients, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	balanceConfig := config.Default().Balance
+	balanceConfig.PinBlock = false
+
+	tests := []struct {
+		name           string
+		mode           string
+		onFailure      string
+		query          string
+		expectedStatus int
+	}{
+		{"hedged and closed requested", config.ModeFanOut, config.FailOpen, "?mode=hedged&on_failure=closed", http.StatusBadRequest},
+		{"closed requested in hedged mode", config.ModeHedged, config.FailOpen, "?on_failure=closed", http.StatusBadRequest},
+		{"hedged requested when failing closed", config.ModeFanOut, config.FailClosed, "?mode=hedged", http.StatusBadRequest},
+		{"hedged and open", config.ModeFanOut, config.FailClosed, "?mode=hedged&on_failure=open", http.StatusOK},
+		{"fanout and closed", config.ModeHedged, config.FailOpen, "?mode=fanout&on_failure=closed", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := balanceConfig
+			cfg.Mode = tt.mode
+			cfg.Consensus.OnFailure = tt.onFailure
+
+			router := chi.NewRouter()
+			router.Get("/eth/balance/{address}", NewBalanceHandler(pool, time.Second, cfg).GetBalance)
+
+			recorder := httptest.NewRecorder()
+			address := "0x0000000000000000000000000000000000000001"
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/eth/balance/"+address+tt.query, nil))
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code, recorder.Body.String())
+		})
+	}
+}
@@ -18,9 +18,11 @@ func SetupRouter(clientPool *client.PoolStruct, cfg *config.Config) http.Handler
 	r.Use(middleware.Recoverer)
 	r.Use(PrometheusMiddleware)
 
-	balanceHandler := NewBalanceHandler(clientPool, cfg.RequestTimeout)
+	balanceHandler := NewBalanceHandler(clientPool, cfg.RequestTimeout, cfg.Balance)
+	rpcHandler := NewRPCHandler(clientPool, cfg.RequestTimeout, cfg.RPC)
 	healthHandler := NewHealthHandler(clientPool)
 
+	r.Post("/", rpcHandler.HandleRPC)
 	r.Get("/eth/balance/{address}", balanceHandler.GetBalance)
 
 	r.Get("/health/live", healthHandler.LivenessCheck)
@@ -30,3 +32,29 @@ func SetupRouter(clientPool *client.PoolStruct, cfg *config.Config) http.Handler
 
 	return r
 }
+
+// SetupAdminRouter configures the router of the admin API, served on its own port.
+// Every request must carry the admin token.
+func SetupAdminRouter(clientPool *client.PoolStruct, cfg *config.Config) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(AdminAuthMiddleware(cfg.Admin.Token))
+
+	adminHandler := NewAdminHandler(clientPool, cfg.ClientDefaults)
+
+	r.Route("/admin/clients", func(r chi.Router) {
+		r.Get("/", adminHandler.ListClients)
+		r.Post("/", adminHandler.AddClient)
+		r.Get("/{name}", adminHandler.GetClient)
+		r.Get("/{name}/health", adminHandler.GetClientHealth)
+		r.Delete("/{name}", adminHandler.RemoveClient)
+		r.Post("/{name}/cordon", adminHandler.CordonClient)
+		r.Post("/{name}/drain", adminHandler.DrainClient)
+		r.Post("/{name}/enable", adminHandler.EnableClient)
+		r.Delete("/{name}/override", adminHandler.ClearOverride)
+	})
+
+	return r
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/bersh/alluvial_test_1/internal/client"
 )
@@ -26,21 +27,36 @@ func (h *HealthHandler) LivenessCheck(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
 }
 
+// readinessResponse is the body of the readiness probe
+type readinessResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Clients holds the outcome of each client's last health check. Error messages are left
+	// out, they may carry upstream URLs and the endpoint is public.
+	Clients map[string]readinessCheck `json:"clients"`
+}
+
+type readinessCheck struct {
+	Outcome   string    `json:"outcome"`
+	Available bool      `json:"available"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
 // ReadinessCheck handles the readiness probe
 func (h *HealthHandler) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
-	available := h.clientPool.HasAvailableClients()
-
-	if !available {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status":  "not ready",
-			"message": "no clients available",
-		})
-		return
+	response := readinessResponse{Status: "ready", Clients: make(map[string]readinessCheck)}
+	for name, result := range h.clientPool.LastHealthChecks() {
+		response.Clients[name] = readinessCheck{Outcome: result.Outcome, Available: result.Available, CheckedAt: result.Time}
+	}
+
+	status := http.StatusOK
+	if !h.clientPool.HasAvailableClients() {
+		status = http.StatusServiceUnavailable
+		response.Status = "not ready"
+		response.Message = "no clients available"
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
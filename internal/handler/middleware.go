@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -27,3 +29,22 @@ func PrometheusMiddleware(next http.Handler) http.Handler {
 		metrics.M.RequestTotal.WithLabelValues(r.Method, endpoint, status).Inc()
 	})
 }
+
+// AdminAuthMiddleware rejects requests that do not carry the admin bearer token.
+// Without a token every request is rejected.
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/service"
+)
+
+// maxRPCBodySize limits the size of inbound JSON-RPC payloads
+const maxRPCBodySize = 1 << 20
+
+// RPCHandler handles the generic JSON-RPC 2.0 proxy endpoint
+type RPCHandler struct {
+	requestTimeout time.Duration
+	maxBatchSize   int
+	rpcService     *service.RPCService
+}
+
+// NewRPCHandler creates a new JSON-RPC handler
+func NewRPCHandler(clientPool *client.PoolStruct, requestTimeout time.Duration, rpcConfig config.RPCConfig) *RPCHandler {
+	return &RPCHandler{
+		requestTimeout: requestTimeout,
+		maxBatchSize:   rpcConfig.MaxBatchSize,
+		rpcService:     service.NewRPCService(clientPool, rpcConfig),
+	}
+}
+
+// rpcRequest is an inbound JSON-RPC request. Params are kept raw and forwarded unchanged.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// HandleRPC handles JSON-RPC 2.0 requests and batches sent to the proxy.
+// Notifications, requests without an id, are answered with 204 and no body.
+func (h *RPCHandler) HandleRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBodySize))
+	if err != nil {
+		writeRPCResponse(w, rpcErrorResponse(nil, client.CodeParseError, "parse error"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		h.handleBatch(ctx, w, body)
+		return
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		// Well-formed JSON that is not a request object is an invalid request, not a parse error
+		if json.Valid(body) {
+			writeRPCResponse(w, rpcErrorResponse(readID(body), client.CodeInvalidRequest, "invalid request"))
+			return
+		}
+		writeRPCResponse(w, rpcErrorResponse(nil, client.CodeParseError, "parse error"))
+		return
+	}
+
+	resp := h.handleRequest(ctx, req)
+	// A notification is still forwarded, but the client expects no response
+	if req.isNotification() && req.isValid() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeRPCResponse(w, resp)
+}
+
+// handleBatch validates every request of a batch and forwards the valid ones
+// to the pool as a single upstream batch. Notifications get no response.
+func (h *RPCHandler) handleBatch(ctx context.Context, w http.ResponseWriter, body []byte) {
+	var rawRequests []json.RawMessage
+	if err := json.Unmarshal(body, &rawRequests); err != nil {
+		writeRPCResponse(w, rpcErrorResponse(nil, client.CodeParseError, "parse error"))
+		return
+	}
+
+	if len(rawRequests) == 0 {
+		writeRPCResponse(w, rpcErrorResponse(nil, client.CodeInvalidRequest, "empty batch"))
+		return
+	}
+
+	if len(rawRequests) > h.maxBatchSize {
+		writeRPCResponse(w, rpcErrorResponse(nil, client.CodeInvalidRequest,
+			fmt.Sprintf("batch too large: %d requests, maximum is %d", len(rawRequests), h.maxBatchSize)))
+		return
+	}
+
+	requests := make([]rpcRequest, len(rawRequests))
+	responses := make([]client.Response, len(rawRequests))
+	calls := make([]client.Call, 0, len(rawRequests))
+	positions := make([]int, 0, len(rawRequests))
+
+	for i, raw := range rawRequests {
+		if err := json.Unmarshal(raw, &requests[i]); err != nil || !requests[i].isValid() {
+			responses[i] = rpcErrorResponse(readID(raw), client.CodeInvalidRequest, "invalid request")
+			continue
+		}
+		calls = append(calls, client.Call{Method: requests[i].Method, Params: requests[i].params()})
+		positions = append(positions, i)
+	}
+
+	results := h.rpcService.CallBatch(ctx, calls)
+	for j, i := range positions {
+		responses[i] = callResultResponse(requests[i], results[j].Result, results[j].Error)
+	}
+
+	batch := make([]client.Response, 0, len(responses))
+	for i, resp := range responses {
+		if requests[i].isNotification() && requests[i].isValid() {
+			continue
+		}
+		batch = append(batch, resp)
+	}
+
+	if len(batch) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeRPCResponse(w, batch)
+}
+
+// handleRequest validates a single JSON-RPC request and forwards it to the pool
+func (h *RPCHandler) handleRequest(ctx context.Context, req rpcRequest) client.Response {
+	if !req.isValid() {
+		return rpcErrorResponse(req.ID, client.CodeInvalidRequest, "invalid request")
+	}
+
+	result, err := h.rpcService.Call(ctx, req.Method, req.params())
+	return callResultResponse(req, result, err)
+}
+
+func (r rpcRequest) isValid() bool {
+	return r.JSONRPC == "2.0" && r.Method != ""
+}
+
+func (r rpcRequest) isNotification() bool {
+	return len(r.ID) == 0
+}
+
+// params returns the request params, defaulting to an empty positional list
+func (r rpcRequest) params() json.RawMessage {
+	if len(r.Params) == 0 || bytes.Equal(r.Params, []byte("null")) {
+		return json.RawMessage("[]")
+	}
+	return r.Params
+}
+
+// callResultResponse converts the outcome of a proxied call into a JSON-RPC response
+func callResultResponse(req rpcRequest, result json.RawMessage, err error) client.Response {
+	if err != nil {
+		var rpcErr *client.RPCError
+		switch {
+		case errors.Is(err, service.ErrMethodNotAllowed):
+			return rpcErrorResponse(req.ID, client.CodeMethodNotFound, "method not found or not allowed: "+req.Method)
+		case errors.As(err, &rpcErr):
+			return client.Response{JSONRPC: "2.0", ID: responseID(req.ID), Error: rpcErr}
+		default:
+			return rpcErrorResponse(req.ID, client.CodeInternalError, err.Error())
+		}
+	}
+
+	return client.Response{JSONRPC: "2.0", ID: responseID(req.ID), Result: result}
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, message string) client.Response {
+	return client.Response{
+		JSONRPC: "2.0",
+		ID:      responseID(id),
+		Error:   &client.RPCError{Code: code, Message: message},
+	}
+}
+
+// readID extracts the id of a request that could not be decoded as a whole, so its error response
+// can still be matched to it. It returns nil when the id is missing or is not a string, number or null.
+func readID(raw json.RawMessage) json.RawMessage {
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || len(req.ID) == 0 {
+		return nil
+	}
+
+	var id interface{}
+	if err := json.Unmarshal(req.ID, &id); err != nil {
+		return nil
+	}
+	switch id.(type) {
+	case string, float64, nil:
+		return req.ID
+	default:
+		return nil
+	}
+}
+
+// responseID echoes the request id, using null when the request had none
+func responseID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func writeRPCResponse(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/client/rpctest"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCHandler_InvalidBatchItemsEchoTheirID(t *testing.T) {
+	server := rpctest.NewServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+
+	pool, err := client.NewPool([]config.ClientConfig{{URL: server.URL, Name: "node", Timeout: time.Second}}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	handler := NewRPCHandler(pool, time.Second, config.RPCConfig{
+		AllowedMethods: []string{"eth_chainId"},
+		MaxBatchSize:   10,
+		DefaultPolicy:  config.PolicyFirst,
+	})
+
+	body := `[
+		{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},
+		{"jsonrpc":"1.0","id":2,"method":"eth_chainId"},
+		{"jsonrpc":"2.0","id":"three","method":4},
+		{"jsonrpc":"2.0","id":{"bad":true},"method":5},
+		42
+	]`
+	recorder := httptest.NewRecorder()
+	handler.HandleRPC(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	var responses []client.Response
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &responses))
+	require.Len(t, responses, 5)
+
+	assert.JSONEq(t, `1`, string(responses[0].ID))
+	assert.Nil(t, responses[0].Error)
+
+	ids := []string{`2`, `"three"`, `null`, `null`}
+	for i, id := range ids {
+		resp := responses[i+1]
+		assert.JSONEq(t, id, string(resp.ID))
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, client.CodeInvalidRequest, resp.Error.Code)
+	}
+}
+
+func TestRPCHandler_InvalidRequestObject(t *testing.T) {
+	handler := &RPCHandler{requestTimeout: time.Second, maxBatchSize: 10}
+
+	tests := []struct {
+		body string
+		id   string
+		code int
+	}{
+		{body: `{"jsonrpc":"2.0","id":"a","method":7}`, id: `"a"`, code: client.CodeInvalidRequest},
+		{body: `{"jsonrpc":"2.0","id":1,`, id: `null`, code: client.CodeParseError},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		handler.HandleRPC(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+		var resp client.Response
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		assert.JSONEq(t, tt.id, string(resp.ID))
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, tt.code, resp.Error.Code)
+	}
+}
+
+func TestRPCHandler_NotificationGetsNoResponse(t *testing.T) {
+	server := rpctest.NewServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+
+	pool, err := client.NewPool([]config.ClientConfig{{URL: server.URL, Name: "node", Timeout: time.Second}}, config.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	handler := NewRPCHandler(pool, time.Second, config.RPCConfig{
+		AllowedMethods: []string{"eth_chainId"},
+		MaxBatchSize:   10,
+		DefaultPolicy:  config.PolicyFirst,
+	})
+
+	recorder := httptest.NewRecorder()
+	body := `{"jsonrpc":"2.0","method":"eth_chainId"}`
+	handler.HandleRPC(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+
+	// An invalid request without an id is not a notification and gets an error
+	recorder = httptest.NewRecorder()
+	body = `{"jsonrpc":"1.0","method":"eth_chainId"}`
+	handler.HandleRPC(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	var resp client.Response
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	assert.JSONEq(t, `null`, string(resp.ID))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, client.CodeInvalidRequest, resp.Error.Code)
+}
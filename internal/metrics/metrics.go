@@ -5,11 +5,33 @@ import (
 )
 
 type Metrics struct {
-	RequestDuration    *prometheus.HistogramVec
-	RequestTotal       *prometheus.CounterVec
-	ClientErrors       *prometheus.CounterVec
-	ClientAvailability *prometheus.GaugeVec
-	BalanceDiscrepancy *prometheus.CounterVec
+	RequestDuration     *prometheus.HistogramVec
+	RequestTotal        *prometheus.CounterVec
+	ClientErrors        *prometheus.CounterVec
+	ClientAvailability  *prometheus.GaugeVec
+	BalanceDiscrepancy  *prometheus.CounterVec
+	RPCDiscrepancy      *prometheus.CounterVec
+	CircuitState        *prometheus.GaugeVec
+	CircuitTransitions  *prometheus.CounterVec
+	ClientRetries       *prometheus.CounterVec
+	ClientThrottled     *prometheus.GaugeVec
+	ClientThrottles     *prometheus.CounterVec
+	ClientDegraded      *prometheus.GaugeVec
+	ClientHead          *prometheus.GaugeVec
+	ClientHeadLag       *prometheus.GaugeVec
+	ClientHeadLagTime   *prometheus.GaugeVec
+	ClientQuarantined   *prometheus.GaugeVec
+	ClientOverride      *prometheus.GaugeVec
+	ConfigReloads       *prometheus.CounterVec
+	TierRequests        *prometheus.CounterVec
+	ClientReputation    *prometheus.GaugeVec
+	ClientEjected       *prometheus.GaugeVec
+	ClientEjections     *prometheus.CounterVec
+	HealthCheckDuration *prometheus.HistogramVec
+	UpstreamDuration    *prometheus.HistogramVec
+	ClientInFlight      *prometheus.GaugeVec
+	ConsensusOutcomes   *prometheus.CounterVec
+	FanOutOverhead      *prometheus.HistogramVec
 }
 
 // Global metrics instance - can be nil in test environments
@@ -43,9 +65,9 @@ func Init() {
 		ClientAvailability: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "client_availability",
-				Help: "Availability status of each client (1=available, 0=unavailable)",
+				Help: "Availability status of each client (1=available, 0=unavailable) and what last changed it (health_check, passive=live traffic failures, manual)",
 			},
-			[]string{"client_name"},
+			[]string{"client_name", "reason"},
 		),
 		BalanceDiscrepancy: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -54,6 +76,163 @@ func Init() {
 			},
 			[]string{"address"},
 		),
+		RPCDiscrepancy: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "rpc_discrepancy_total",
+				Help: "Count of result discrepancies between clients per proxied JSON-RPC method",
+			},
+			[]string{"method"},
+		),
+		CircuitState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_circuit_state",
+				Help: "Circuit breaker state of each client (0=closed, 1=half-open, 2=open)",
+			},
+			[]string{"client_name"},
+		),
+		CircuitTransitions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_circuit_transitions_total",
+				Help: "Count of circuit breaker state transitions per client and target state",
+			},
+			[]string{"client_name", "state"},
+		),
+		ClientRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_retries_total",
+				Help: "Count of retried upstream requests per client and error type of the failed attempt",
+			},
+			[]string{"client_name", "error_type"},
+		),
+		ClientThrottled: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_throttled",
+				Help: "Whether a client is backing off after a rate-limit response (1=throttled, 0=not throttled)",
+			},
+			[]string{"client_name"},
+		),
+		ClientThrottles: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_throttles_total",
+				Help: "Count of rate-limit events per client and source (upstream=rate-limit response, local=token bucket empty)",
+			},
+			[]string{"client_name", "source"},
+		),
+		ClientDegraded: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_degraded",
+				Help: "Whether a client lags behind the pool head enough to be deprioritised (1=degraded, 0=healthy)",
+			},
+			[]string{"client_name"},
+		),
+		ClientHead: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_head_block",
+				Help: "Latest block number reported by each client's last successful health check",
+			},
+			[]string{"client_name"},
+		),
+		ClientHeadLag: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_head_lag_blocks",
+				Help: "Number of blocks each client's head is behind the pool head",
+			},
+			[]string{"client_name"},
+		),
+		ClientHeadLagTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_head_lag_seconds",
+				Help: "Age difference (in seconds) between each client's head block and the pool head",
+			},
+			[]string{"client_name"},
+		),
+		ClientQuarantined: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_quarantined",
+				Help: "Whether a client is quarantined for being on the wrong chain (1=quarantined, 0=verified)",
+			},
+			[]string{"client_name"},
+		),
+		ClientOverride: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_override",
+				Help: "Manual routing override of each client (0=none, 1=cordoned, 2=enabled)",
+			},
+			[]string{"client_name"},
+		),
+		ConfigReloads: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "config_reloads_total",
+				Help: "Total number of configuration reloads by result (applied or rejected)",
+			},
+			[]string{"result"},
+		),
+		TierRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tier_requests_total",
+				Help: "Count of requests sent to clients of each tier, lower priority tiers are only used when higher ones cannot reach the quorum",
+			},
+			[]string{"tier"},
+		),
+		ClientReputation: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_reputation_score",
+				Help: "Ratio of recent consensus votes each client agreed with (1=always agreed)",
+			},
+			[]string{"client_name"},
+		),
+		ClientEjected: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_ejected",
+				Help: "Whether a client is ejected for disagreeing with consensus (1=ejected, 0=in rotation)",
+			},
+			[]string{"client_name"},
+		),
+		ClientEjections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_ejections_total",
+				Help: "Count of ejections per client for disagreeing with consensus",
+			},
+			[]string{"client_name"},
+		),
+		HealthCheckDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "health_check_duration_seconds",
+				Help:    "Time (in seconds) spent probing each client per health check outcome (healthy, degraded, lagging, syncing, wrong_chain, rate_limited, failed)",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"client_name", "outcome"},
+		),
+		UpstreamDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "upstream_request_duration_seconds",
+				Help:    "Time (in seconds) spent on each request attempt sent to a client, per JSON-RPC method and outcome (success or the error type)",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"client_name", "method", "outcome"},
+		),
+		ClientInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_in_flight_requests",
+				Help: "Number of requests currently being executed by each client",
+			},
+			[]string{"client_name"},
+		),
+		ConsensusOutcomes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "consensus_outcomes_total",
+				Help: "Count of fan-out results per method and how the clients agreed (unanimous, majority, plurality, no_quorum)",
+			},
+			[]string{"method", "outcome"},
+		),
+		FanOutOverhead: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "fanout_overhead_seconds",
+				Help:    "Time (in seconds) a fan-out request spent waiting for the other clients after the fastest client answered",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method"},
+		),
 	}
 
 	prometheus.MustRegister(
@@ -62,6 +241,28 @@ func Init() {
 		M.ClientErrors,
 		M.ClientAvailability,
 		M.BalanceDiscrepancy,
+		M.RPCDiscrepancy,
+		M.CircuitState,
+		M.CircuitTransitions,
+		M.ClientRetries,
+		M.ClientThrottled,
+		M.ClientThrottles,
+		M.ClientDegraded,
+		M.ClientHead,
+		M.ClientHeadLag,
+		M.ClientHeadLagTime,
+		M.ClientQuarantined,
+		M.ClientOverride,
+		M.ConfigReloads,
+		M.TierRequests,
+		M.ClientReputation,
+		M.ClientEjected,
+		M.ClientEjections,
+		M.HealthCheckDuration,
+		M.UpstreamDuration,
+		M.ClientInFlight,
+		M.ConsensusOutcomes,
+		M.FanOutOverhead,
 	)
 }
 
@@ -72,7 +273,9 @@ func RecordClientError(clientName, errorType string) {
 	M.ClientErrors.WithLabelValues(clientName, errorType).Inc()
 }
 
-func SetClientAvailability(clientName string, isAvailable bool) {
+// SetClientAvailability records a client's availability together with what last changed it,
+// replacing the series of the previous reason
+func SetClientAvailability(clientName string, isAvailable bool, reason string) {
 	if M == nil || M.ClientAvailability == nil {
 		return
 	}
@@ -81,7 +284,8 @@ func SetClientAvailability(clientName string, isAvailable bool) {
 	if isAvailable {
 		value = 1
 	}
-	M.ClientAvailability.WithLabelValues(clientName).Set(value)
+	M.ClientAvailability.DeletePartialMatch(prometheus.Labels{"client_name": clientName})
+	M.ClientAvailability.WithLabelValues(clientName, reason).Set(value)
 }
 
 func RecordBalanceDiscrepancy(address string) {
@@ -90,3 +294,210 @@ func RecordBalanceDiscrepancy(address string) {
 	}
 	M.BalanceDiscrepancy.WithLabelValues(address).Inc()
 }
+
+func RecordRPCDiscrepancy(method string) {
+	if M == nil || M.RPCDiscrepancy == nil {
+		return
+	}
+	M.RPCDiscrepancy.WithLabelValues(method).Inc()
+}
+
+func SetCircuitState(clientName string, state int) {
+	if M == nil || M.CircuitState == nil {
+		return
+	}
+	M.CircuitState.WithLabelValues(clientName).Set(float64(state))
+}
+
+func RecordCircuitTransition(clientName, state string) {
+	if M == nil || M.CircuitTransitions == nil {
+		return
+	}
+	M.CircuitTransitions.WithLabelValues(clientName, state).Inc()
+}
+
+func RecordClientRetry(clientName, errorType string) {
+	if M == nil || M.ClientRetries == nil {
+		return
+	}
+	M.ClientRetries.WithLabelValues(clientName, errorType).Inc()
+}
+
+func SetClientThrottled(clientName string, throttled bool) {
+	if M == nil || M.ClientThrottled == nil {
+		return
+	}
+
+	var value float64 = 0
+	if throttled {
+		value = 1
+	}
+	M.ClientThrottled.WithLabelValues(clientName).Set(value)
+}
+
+func RecordClientThrottle(clientName, source string) {
+	if M == nil || M.ClientThrottles == nil {
+		return
+	}
+	M.ClientThrottles.WithLabelValues(clientName, source).Inc()
+}
+
+func SetClientDegraded(clientName string, isDegraded bool) {
+	if M == nil || M.ClientDegraded == nil {
+		return
+	}
+
+	var value float64 = 0
+	if isDegraded {
+		value = 1
+	}
+	M.ClientDegraded.WithLabelValues(clientName).Set(value)
+}
+
+func SetClientHead(clientName string, block float64) {
+	if M == nil || M.ClientHead == nil {
+		return
+	}
+	M.ClientHead.WithLabelValues(clientName).Set(block)
+}
+
+func SetClientHeadLag(clientName string, blocks, seconds float64) {
+	if M == nil || M.ClientHeadLag == nil || M.ClientHeadLagTime == nil {
+		return
+	}
+	M.ClientHeadLag.WithLabelValues(clientName).Set(blocks)
+	M.ClientHeadLagTime.WithLabelValues(clientName).Set(seconds)
+}
+
+func SetClientQuarantined(clientName string, quarantined bool) {
+	if M == nil || M.ClientQuarantined == nil {
+		return
+	}
+
+	var value float64 = 0
+	if quarantined {
+		value = 1
+	}
+	M.ClientQuarantined.WithLabelValues(clientName).Set(value)
+}
+
+func SetClientOverride(clientName string, override int) {
+	if M == nil || M.ClientOverride == nil {
+		return
+	}
+	M.ClientOverride.WithLabelValues(clientName).Set(float64(override))
+}
+
+func RecordConfigReload(applied bool) {
+	if M == nil || M.ConfigReloads == nil {
+		return
+	}
+
+	result := "rejected"
+	if applied {
+		result = "applied"
+	}
+	M.ConfigReloads.WithLabelValues(result).Inc()
+}
+
+func RecordTierRequest(tier string) {
+	if M == nil || M.TierRequests == nil {
+		return
+	}
+	M.TierRequests.WithLabelValues(tier).Inc()
+}
+
+func SetClientReputation(clientName string, score float64) {
+	if M == nil || M.ClientReputation == nil {
+		return
+	}
+	M.ClientReputation.WithLabelValues(clientName).Set(score)
+}
+
+func SetClientEjected(clientName string, ejected bool) {
+	if M == nil || M.ClientEjected == nil {
+		return
+	}
+
+	var value float64 = 0
+	if ejected {
+		value = 1
+	}
+	M.ClientEjected.WithLabelValues(clientName).Set(value)
+}
+
+func RecordClientEjection(clientName string) {
+	if M == nil || M.ClientEjections == nil {
+		return
+	}
+	M.ClientEjections.WithLabelValues(clientName).Inc()
+}
+
+func ObserveHealthCheck(clientName, outcome string, seconds float64) {
+	if M == nil || M.HealthCheckDuration == nil {
+		return
+	}
+	M.HealthCheckDuration.WithLabelValues(clientName, outcome).Observe(seconds)
+}
+
+func ObserveUpstreamRequest(clientName, method, outcome string, seconds float64) {
+	if M == nil || M.UpstreamDuration == nil {
+		return
+	}
+	M.UpstreamDuration.WithLabelValues(clientName, method, outcome).Observe(seconds)
+}
+
+func SetClientInFlight(clientName string, inFlight int64) {
+	if M == nil || M.ClientInFlight == nil {
+		return
+	}
+	M.ClientInFlight.WithLabelValues(clientName).Set(float64(inFlight))
+}
+
+func RecordConsensusOutcome(method, outcome string) {
+	if M == nil || M.ConsensusOutcomes == nil {
+		return
+	}
+	M.ConsensusOutcomes.WithLabelValues(method, outcome).Inc()
+}
+
+func ObserveFanOutOverhead(method string, seconds float64) {
+	if M == nil || M.FanOutOverhead == nil {
+		return
+	}
+	M.FanOutOverhead.WithLabelValues(method).Observe(seconds)
+}
+
+// RemoveClient deletes the series of a client that was removed from the pool
+func RemoveClient(clientName string) {
+	if M == nil {
+		return
+	}
+
+	labels := prometheus.Labels{"client_name": clientName}
+	for _, vec := range []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{
+		M.ClientErrors,
+		M.ClientAvailability,
+		M.CircuitState,
+		M.CircuitTransitions,
+		M.ClientRetries,
+		M.ClientThrottled,
+		M.ClientThrottles,
+		M.ClientDegraded,
+		M.ClientHead,
+		M.ClientHeadLag,
+		M.ClientHeadLagTime,
+		M.ClientQuarantined,
+		M.ClientOverride,
+		M.ClientReputation,
+		M.ClientEjected,
+		M.ClientEjections,
+		M.HealthCheckDuration,
+		M.UpstreamDuration,
+		M.ClientInFlight,
+	} {
+		vec.DeletePartialMatch(labels)
+	}
+}
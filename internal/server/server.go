@@ -4,19 +4,31 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 )
 
 type Server struct {
-	server *http.Server
+	server  *http.Server
+	handler atomic.Pointer[http.Handler]
 }
 
 func New(handler http.Handler, port string) *Server {
-	return &Server{
-		server: &http.Server{
-			Addr:    fmt.Sprintf(":%s", port),
-			Handler: handler,
-		},
+	s := &Server{}
+	s.handler.Store(&handler)
+	s.server = &http.Server{
+		Addr:    fmt.Sprintf(":%s", port),
+		Handler: http.HandlerFunc(s.serveHTTP),
 	}
+	return s
+}
+
+// SetHandler replaces the handler of new requests, requests in flight finish on the previous one
+func (s *Server) SetHandler(handler http.Handler) {
+	s.handler.Store(&handler)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	(*s.handler.Load()).ServeHTTP(w, r)
 }
 
 func (s *Server) Start() error {
@@ -1,95 +1,210 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/bersh/alluvial_test_1/internal/metrics"
+	"log"
 	"math/big"
 	"sort"
 
 	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
+// ErrHedgedFailClosed is returned for a fail-closed query in hedged mode, whose first answer is never checked
+// against the other clients
+var ErrHedgedFailClosed = errors.New("hedged mode cannot verify consensus, use fanout mode with on_failure=closed")
+
+// pinnableTags are the block tags resolved to a single block number before a fan-out query,
+// so that every client answers for the same block
+var pinnableTags = map[string]bool{
+	client.TagLatest:    true,
+	client.TagSafe:      true,
+	client.TagFinalized: true,
+}
+
+// BalanceResult is a balance together with the block it was read at
+type BalanceResult struct {
+	Balance *big.Int
+	// BlockNumber is the block the balance was read at, nil when the query was not made at a known number
+	BlockNumber *uint64
+}
+
 // BalanceService handles balance-related operations
 type BalanceService struct {
 	clientPool client.Pool
+	selector   client.Selector
+	strategy   ConsensusStrategy
+	cfg        config.BalanceConfig
 }
 
 // NewBalanceService creates a new balance service
-func NewBalanceService(clientPool client.Pool) *BalanceService {
+func NewBalanceService(clientPool client.Pool, cfg config.BalanceConfig) *BalanceService {
 	return &BalanceService{
 		clientPool: clientPool,
+		selector:   newSelector(cfg.Routing),
+		strategy:   newConsensusStrategy(cfg.Consensus),
+		cfg:        cfg,
 	}
 }
 
-// GetBalance retrieves a balance from multiple clients and returns the consensus result
-func (s *BalanceService) GetBalance(ctx context.Context, address, blockParam string) (*big.Int, error) {
-	responses, err := s.clientPool.QueryBalanceFromAllClients(ctx, address, blockParam)
+// newSelector creates the selector of an endpoint, falling back to fan-out for unknown strategies
+func newSelector(cfg config.RoutingConfig) client.Selector {
+	selector, err := client.NewSelector(cfg)
+	if err != nil {
+		log.Printf("Invalid routing configuration, falling back to fan-out: %v\n", err)
+		return client.FanOutSelector{}
+	}
+	return selector
+}
+
+// BalanceOptions override the configuration of the balance endpoint for a single request, empty fields keep it
+type BalanceOptions struct {
+	// Mode is config.ModeFanOut or config.ModeHedged
+	Mode string
+	// OnFailure is config.FailOpen or config.FailClosed
+	OnFailure string
+}
+
+// GetBalance retrieves a balance using the configured query mode
+func (s *BalanceService) GetBalance(ctx context.Context, address string, block client.BlockParameter) (*BalanceResult, error) {
+	return s.GetBalanceWithOptions(ctx, address, block, BalanceOptions{})
+}
+
+// GetBalanceWithOptions retrieves a balance, overriding the configured query mode and consensus failure
+// handling with the non-empty options. In fan-out mode the clients picked by the routing strategy are
+// queried and the balance chosen by the consensus strategy is returned, in hedged mode the first valid
+// answer wins. Queries at old blocks only go to clients that still keep their state, they fail with
+// client.ErrNoStateHistory when there is none.
+//
+// When the consensus strategy is not satisfied a fail-open request returns the best candidate balance,
+// a fail-closed request fails with a *ConsensusError listing the conflicting balances. Both fail when
+// the strategy did not even pick a candidate. A fail-closed request in hedged mode fails with ErrHedgedFailClosed.
+//
+// With block pinning enabled, fan-out queries for latest, safe or finalized are first
+// resolved to a single block number so that clients at different heads are compared
+// at the same block instead of reporting false discrepancies.
+func (s *BalanceService) GetBalanceWithOptions(ctx context.Context, address string, block client.BlockParameter, opts BalanceOptions) (*BalanceResult, error) {
+	mode := cmp.Or(opts.Mode, s.cfg.Mode)
+	onFailure := cmp.Or(opts.OnFailure, s.cfg.Consensus.OnFailure)
+
+	if mode == config.ModeHedged {
+		if onFailure == config.FailClosed {
+			return nil, ErrHedgedFailClosed
+		}
+		response, err := s.clientPool.QueryBalanceHedged(ctx, address, block, s.cfg.Hedge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query balance: %w", err)
+		}
+		return &BalanceResult{Balance: response.Balance, BlockNumber: blockNumber(block)}, nil
+	}
+
+	clients, err := s.clientPool.GetClientsForBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	clients = s.selector.Select(clients)
+
+	if tag, ok := block.Tag(); ok && s.cfg.PinBlock && pinnableTags[tag] {
+		if number, err := s.resolveBlock(ctx, clients, tag); err == nil {
+			block = client.BlockNumber(number)
+		} else {
+			log.Printf("Could not pin %s block, querying clients at their own head: %v\n", tag, err)
+		}
+	}
+
+	responses, err := s.clientPool.QueryBalanceFromClients(ctx, clients, address, block)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query balances: %w", err)
 	}
 
-	consensusBalance, hasDiscrepancy := getConsensusBalance(responses, address)
+	winner, decision, err := decideAnswers(s.strategy, responses, balanceVote(clientHeads(clients)))
+	metrics.RecordConsensusOutcome("eth_getBalance", decision.Agreement)
+	consensusBalance := winner.Balance
 
-	if hasDiscrepancy {
+	if len(decision.Dissenting) > 0 {
+		metrics.RecordBalanceDiscrepancy(address)
 		fmt.Printf("Balance discrepancy detected for address %s\n", address)
 	}
+	if err != nil {
+		// Without even a best candidate, e.g. when every answering client has a trust weight of 0, there is nothing to return
+		if onFailure == config.FailClosed || consensusBalance == nil {
+			return nil, err
+		}
+		log.Printf("Returning the best balance for address %s without consensus: %v\n", address, err)
+	}
+
+	// Answers for a tag may legitimately differ between clients at different heads,
+	// only votes at a fixed block say something about a client's data
+	if _, isTag := block.Tag(); !isTag && err == nil && decision.Clear() {
+		s.clientPool.RecordConsensus(decision.Agreeing, decision.Dissenting)
+	}
 
-	return consensusBalance, nil
+	return &BalanceResult{Balance: consensusBalance, BlockNumber: blockNumber(block)}, nil
 }
 
-// getConsensusBalance determines the most reliable balance from multiple client responses
-func getConsensusBalance(responses []client.BalanceResponse, address string) (*big.Int, bool) {
-	if len(responses) == 1 {
-		return responses[0].Balance, false
+// resolveBlock asks the clients for the block a tag points to and returns the highest
+// block number a quorum of the responding clients has reached
+func (s *BalanceService) resolveBlock(ctx context.Context, clients []*client.Client, tag string) (uint64, error) {
+	responses, err := s.clientPool.CallFromClients(ctx, clients, "eth_getBlockByNumber", []interface{}{tag, false})
+	if err != nil {
+		return 0, err
 	}
 
-	// Track the original order of balances to ensure deterministic behavior
-	// when there are equal vote counts
-	balanceOrder := make(map[string]int)
-	balanceCounts := make(map[string]int)
-	balanceMap := make(map[string]*big.Int)
-
-	for i, resp := range responses {
-		balanceStr := resp.Balance.String()
-		// Only record the first occurrence position for each balance
-		if _, exists := balanceOrder[balanceStr]; !exists {
-			balanceOrder[balanceStr] = i
+	heights := make([]uint64, 0, len(responses))
+	for _, resp := range responses {
+		var block struct {
+			Number hexutil.Uint64 `json:"number"`
+		}
+		if err := json.Unmarshal(resp.Result, &block); err != nil {
+			log.Printf("Invalid %s block from client %s: %v\n", tag, resp.ClientName, err)
+			continue
 		}
-		balanceCounts[balanceStr]++
-		balanceMap[balanceStr] = resp.Balance
+		heights = append(heights, uint64(block.Number))
 	}
 
-	type balanceCount struct {
-		balance string
-		count   int
-		order   int // Original order in the responses
-	}
+	return quorumHeight(heights)
+}
 
-	sortedBalances := make([]balanceCount, 0, len(balanceCounts))
-	for balance, count := range balanceCounts {
-		sortedBalances = append(sortedBalances, balanceCount{
-			balance: balance,
-			count:   count,
-			order:   balanceOrder[balance],
-		})
+// quorumHeight returns the highest height reached by a majority of the given heads
+func quorumHeight(heights []uint64) (uint64, error) {
+	if len(heights) == 0 {
+		return 0, fmt.Errorf("no client reported a block number")
 	}
 
-	// Sort by count (descending) and then by original order (ascending) for stable results
-	sort.Slice(sortedBalances, func(i, j int) bool {
-		if sortedBalances[i].count != sortedBalances[j].count {
-			return sortedBalances[i].count > sortedBalances[j].count
-		}
-		// If counts are equal, use the original order to ensure deterministic behavior
-		return sortedBalances[i].order < sortedBalances[j].order
-	})
+	sorted := append([]uint64(nil), heights...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
 
-	hasDiscrepancy := len(balanceCounts) > 1
+	quorum := len(sorted)/2 + 1
+	return sorted[quorum-1], nil
+}
 
-	if hasDiscrepancy {
-		metrics.RecordBalanceDiscrepancy(address)
+// blockNumber returns the number of a block parameter, nil for tags and hashes
+func blockNumber(block client.BlockParameter) *uint64 {
+	number, ok := block.Number()
+	if !ok {
+		return nil
 	}
+	return &number
+}
 
-	consensusBalance := balanceMap[sortedBalances[0].balance]
-	return consensusBalance, hasDiscrepancy
+// clientHeads returns the latest block each client reported to health checks, by name
+func clientHeads(clients []*client.Client) map[string]uint64 {
+	heads := make(map[string]uint64, len(clients))
+	for _, c := range clients {
+		heads[c.Name] = c.Head().Number
+	}
+	return heads
+}
+
+// balanceVote returns the vote of a client for the balance it returned, heads are the clients' latest blocks by name
+func balanceVote(heads map[string]uint64) func(client.BalanceResponse) Vote {
+	return func(resp client.BalanceResponse) Vote {
+		return Vote{Client: resp.ClientName, Value: resp.Balance.String(), Head: heads[resp.ClientName]}
+	}
 }
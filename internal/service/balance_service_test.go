@@ -2,16 +2,26 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/bersh/alluvial_test_1/internal/client"
 	"github.com/bersh/alluvial_test_1/internal/client/mocks"
+	"github.com/bersh/alluvial_test_1/internal/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+var testClients = []*client.Client{
+	{Name: "client1", IsAvailable: true},
+	{Name: "client2", IsAvailable: true},
+	{Name: "client3", IsAvailable: true},
+}
+
 func TestBalanceService_GetBalance(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -74,15 +84,17 @@ func TestBalanceService_GetBalance(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockPool := new(mocks.Pool)
 
-			mockPool.On("QueryBalanceFromAllClients",
+			mockPool.On("GetClientsForBlock", client.LatestBlock).Return(testClients, nil)
+			mockPool.On("QueryBalanceFromClients",
 				mock.Anything,                 // context
+				testClients,                   // clients picked by the selector
 				mock.AnythingOfType("string"), // address
-				mock.AnythingOfType("string"), // blockParam
+				client.LatestBlock,
 			).Return(tt.mockResponses, tt.mockError)
 
-			service := NewBalanceService(mockPool)
+			service := NewBalanceService(mockPool, config.BalanceConfig{})
 
-			result, err := service.GetBalance(context.Background(), "0x123", "latest")
+			result, err := service.GetBalance(context.Background(), "0x123", client.LatestBlock)
 
 			mockPool.AssertExpectations(t)
 
@@ -90,19 +102,249 @@ func TestBalanceService_GetBalance(t *testing.T) {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, 0, tt.expectedResult.Cmp(result),
-					"Expected balance %s, got %s", tt.expectedResult.String(), result.String())
+				assert.Equal(t, 0, tt.expectedResult.Cmp(result.Balance),
+					"Expected balance %s, got %s", tt.expectedResult.String(), result.Balance.String())
+				assert.Nil(t, result.BlockNumber)
 			}
 		})
 	}
 }
 
+func TestBalanceService_GetBalanceHedged(t *testing.T) {
+	hedge := config.HedgeConfig{Delay: 100 * time.Millisecond, MaxRequests: 2}
+
+	mockPool := new(mocks.Pool)
+	mockPool.On("QueryBalanceHedged",
+		mock.Anything, // context
+		"0x123",
+		client.LatestBlock,
+		hedge,
+	).Return(&client.BalanceResponse{ClientName: "client1", Balance: big.NewInt(1000)}, nil)
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{Mode: config.ModeHedged, Hedge: hedge})
+
+	result, err := service.GetBalance(context.Background(), "0x123", client.LatestBlock)
+
+	mockPool.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, big.NewInt(1000).Cmp(result.Balance))
+	mockPool.AssertNotCalled(t, "QueryBalanceFromClients", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBalanceService_GetBalanceUsesSelector(t *testing.T) {
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetClientsForBlock", client.LatestBlock).Return(testClients, nil)
+	mockPool.On("QueryBalanceFromClients",
+		mock.Anything, // context
+		mock.MatchedBy(func(clients []*client.Client) bool { return len(clients) == 2 }),
+		"0x123",
+		client.LatestBlock,
+	).Return([]client.BalanceResponse{
+		{ClientName: "client1", Balance: big.NewInt(1000)},
+		{ClientName: "client2", Balance: big.NewInt(1000)},
+	}, nil)
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{
+		Routing: config.RoutingConfig{Strategy: config.RoutingRandomN, Count: 2},
+	})
+
+	result, err := service.GetBalance(context.Background(), "0x123", client.LatestBlock)
+
+	mockPool.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, big.NewInt(1000).Cmp(result.Balance))
+}
+
+func TestBalanceService_GetBalancePinsBlock(t *testing.T) {
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetClientsForBlock", client.BlockTag(client.TagFinalized)).Return(testClients, nil)
+	mockPool.On("CallFromClients",
+		mock.Anything, // context
+		testClients,
+		"eth_getBlockByNumber",
+		[]interface{}{"finalized", false},
+	).Return([]client.CallResponse{
+		{ClientName: "client1", Result: json.RawMessage(`{"number":"0x66"}`)},
+		{ClientName: "client2", Result: json.RawMessage(`{"number":"0x64"}`)},
+		{ClientName: "client3", Result: json.RawMessage(`{"number":"0x65"}`)},
+	}, nil)
+	mockPool.On("QueryBalanceFromClients",
+		mock.Anything, // context
+		testClients,
+		"0x123",
+		client.BlockNumber(0x65), // the highest block two of the three clients have reached
+	).Return([]client.BalanceResponse{
+		{ClientName: "client1", Balance: big.NewInt(1000)},
+		{ClientName: "client3", Balance: big.NewInt(1000)},
+	}, nil)
+	mockPool.On("RecordConsensus", []string{"client1", "client3"}, []string(nil)).Return()
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{PinBlock: true})
+
+	result, err := service.GetBalance(context.Background(), "0x123", client.BlockTag(client.TagFinalized))
+
+	mockPool.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, big.NewInt(1000).Cmp(result.Balance))
+	if assert.NotNil(t, result.BlockNumber) {
+		assert.Equal(t, uint64(0x65), *result.BlockNumber)
+	}
+}
+
+func TestBalanceService_GetBalancePinFallsBackToTag(t *testing.T) {
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetClientsForBlock", client.LatestBlock).Return(testClients, nil)
+	mockPool.On("CallFromClients", mock.Anything, testClients, "eth_getBlockByNumber", mock.Anything).
+		Return(nil, errors.New("failed to retrieve result from any client"))
+	mockPool.On("QueryBalanceFromClients", mock.Anything, testClients, "0x123", client.LatestBlock).
+		Return([]client.BalanceResponse{{ClientName: "client1", Balance: big.NewInt(1000)}}, nil)
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{PinBlock: true})
+
+	result, err := service.GetBalance(context.Background(), "0x123", client.LatestBlock)
+
+	mockPool.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Nil(t, result.BlockNumber)
+}
+
+func TestBalanceService_GetBalanceWithoutStateHistory(t *testing.T) {
+	block := client.BlockNumber(0x100)
+
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetClientsForBlock", block).Return(nil, client.ErrNoStateHistory)
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{})
+
+	_, err := service.GetBalance(context.Background(), "0x123", block)
+
+	mockPool.AssertExpectations(t)
+	assert.ErrorIs(t, err, client.ErrNoStateHistory)
+	mockPool.AssertNotCalled(t, "QueryBalanceFromClients", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBalanceService_GetBalanceRecordsOutvotedClients(t *testing.T) {
+	block := client.BlockNumber(0x100)
+
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetClientsForBlock", block).Return(testClients, nil)
+	mockPool.On("QueryBalanceFromClients", mock.Anything, testClients, "0x123", block).
+		Return([]client.BalanceResponse{
+			{ClientName: "client1", Balance: big.NewInt(1000)},
+			{ClientName: "client2", Balance: big.NewInt(999)},
+			{ClientName: "client3", Balance: big.NewInt(1000)},
+		}, nil)
+	mockPool.On("RecordConsensus", []string{"client1", "client3"}, []string{"client2"}).Return()
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{})
+
+	result, err := service.GetBalance(context.Background(), "0x123", block)
+
+	mockPool.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, big.NewInt(1000).Cmp(result.Balance))
+}
+
+func TestDecision_Clear(t *testing.T) {
+	responses := func(balances ...int64) []client.BalanceResponse {
+		var responses []client.BalanceResponse
+		for i, balance := range balances {
+			responses = append(responses, client.BalanceResponse{ClientName: fmt.Sprintf("client%d", i+1), Balance: big.NewInt(balance)})
+		}
+		return responses
+	}
+
+	tests := []struct {
+		name        string
+		responses   []client.BalanceResponse
+		strategy    ConsensusStrategy
+		agreeing    []string
+		disagreeing []string
+		clear       bool
+	}{
+		{"Single response is not a vote", responses(100), PluralityStrategy{}, []string{"client1"}, nil, false},
+		{"Unanimous", responses(100, 100), PluralityStrategy{}, []string{"client1", "client2"}, nil, true},
+		{"Minority is outvoted", responses(100, 200, 100), PluralityStrategy{}, []string{"client1", "client3"}, []string{"client2"}, true},
+		{"Tie has no majority", responses(100, 200), PluralityStrategy{}, []string{"client1"}, []string{"client2"}, false},
+		{"Plurality", responses(100, 200, 300, 100), PluralityStrategy{}, []string{"client1", "client4"}, []string{"client2", "client3"}, true},
+		{"Trusted minority", responses(100, 200, 200), WeightedStrategy{Weights: map[string]float64{"client1": 5}},
+			[]string{"client1"}, []string{"client2", "client3"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, decision, _ := decideAnswers(tt.strategy, tt.responses, balanceVote(nil))
+			assert.Equal(t, tt.clear, decision.Clear())
+			assert.Equal(t, tt.agreeing, decision.Agreeing)
+			assert.Equal(t, tt.disagreeing, decision.Dissenting)
+		})
+	}
+}
+
+func TestDecision_Agreement(t *testing.T) {
+	responses := func(balances ...int64) []client.BalanceResponse {
+		var responses []client.BalanceResponse
+		for _, balance := range balances {
+			responses = append(responses, client.BalanceResponse{Balance: big.NewInt(balance)})
+		}
+		return responses
+	}
+
+	tests := []struct {
+		name      string
+		responses []client.BalanceResponse
+		expected  string
+	}{
+		{"Single response", responses(100), consensusUnanimous},
+		{"All agree", responses(100, 100, 100), consensusUnanimous},
+		{"Majority", responses(100, 200, 100), consensusMajority},
+		{"Plurality", responses(100, 200, 300, 100), consensusPlurality},
+		{"Half is not a majority", responses(100, 100, 200, 300), consensusPlurality},
+		{"Tie", responses(100, 200), consensusNoQuorum},
+		{"Tie for the most votes", responses(100, 200, 100, 200, 300), consensusNoQuorum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, decision, _ := decideAnswers(PluralityStrategy{}, tt.responses, balanceVote(nil))
+			assert.Equal(t, tt.expected, decision.Agreement)
+		})
+	}
+}
+
+func TestQuorumHeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		heights  []uint64
+		expected uint64
+	}{
+		{"Single client", []uint64{10}, 10},
+		{"All clients agree", []uint64{10, 10, 10}, 10},
+		{"One client ahead", []uint64{12, 10, 10}, 10},
+		{"One client behind", []uint64{12, 12, 3}, 12},
+		{"Three of four", []uint64{13, 12, 11, 10}, 11},
+		{"Majority of five", []uint64{10, 14, 11, 13, 12}, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			height, err := quorumHeight(tt.heights)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, height)
+		})
+	}
+
+	_, err := quorumHeight(nil)
+	assert.Error(t, err)
+}
+
 func TestGetConsensusBalance(t *testing.T) {
 	tests := []struct {
 		name           string
 		responses      []client.BalanceResponse
 		expectedValue  *big.Int
 		hasDiscrepancy bool
+		noConsensus    bool
 	}{
 		{
 			name: "Single response returns that value",
@@ -176,6 +418,7 @@ func TestGetConsensusBalance(t *testing.T) {
 			},
 			expectedValue:  big.NewInt(100),
 			hasDiscrepancy: true,
+			noConsensus:    true,
 		},
 		{
 			name: "Complex scenario with multiple different responses",
@@ -213,7 +456,14 @@ func TestGetConsensusBalance(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, hasDiscrepancy := getConsensusBalance(tt.responses, "0x123")
+			winner, decision, err := decideAnswers(PluralityStrategy{}, tt.responses, balanceVote(nil))
+			result := winner.Balance
+			if tt.noConsensus {
+				assert.ErrorIs(t, err, ErrNoConsensus)
+			} else {
+				assert.NoError(t, err)
+			}
+			hasDiscrepancy := len(decision.Dissenting) > 0
 
 			assert.Equal(t, 0, result.Cmp(tt.expectedValue),
 				"Expected balance %s, got %s", tt.expectedValue.String(), result.String())
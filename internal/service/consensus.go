@@ -0,0 +1,355 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/bersh/alluvial_test_1/internal/config"
+)
+
+// Agreement between the responding clients whatever the strategy, see Decision.Agreement
+const (
+	consensusUnanimous = "unanimous"
+	consensusMajority  = "majority"
+	consensusPlurality = "plurality"
+	consensusNoQuorum  = "no_quorum"
+)
+
+// ErrNoConsensus is returned when the answers of the clients do not satisfy the consensus strategy
+var ErrNoConsensus = errors.New("no consensus")
+
+// Conflict is one of the answers clients disagreed on, together with the clients that returned it
+type Conflict struct {
+	Value   string
+	Clients []string
+}
+
+// ConsensusError is returned by fail-closed requests when the consensus strategy is not satisfied.
+// It lists every answer with the clients that returned it, most supported first.
+type ConsensusError struct {
+	Err       error
+	Conflicts []Conflict
+}
+
+func (e *ConsensusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConsensusError) Unwrap() error {
+	return e.Err
+}
+
+// Vote is the answer of one client to a fan-out request
+type Vote struct {
+	Client string
+	// Value is the answer in a form that compares equal between clients that agree
+	Value string
+	// Head is the latest block the client reported to health checks, 0 when unknown
+	Head uint64
+}
+
+// ConsensusOutcome is the decision of a consensus strategy over a set of votes
+type ConsensusOutcome struct {
+	// Winner is the value the strategy settled on. When the strategy fails it is the best candidate.
+	Winner     string
+	Agreeing   []string
+	Dissenting []string
+	// Confidence is the share of the votes, or of the trust weight for the weighted strategy, backing the winner
+	Confidence float64
+}
+
+// Decision is the outcome of a consensus strategy together with the breakdown of the votes it was taken on
+type Decision struct {
+	ConsensusOutcome
+	// Agreement classifies how the clients agreed: unanimous when all answers match, majority when the most
+	// common answer got more than half of the votes, plurality when it merely got more votes than every other
+	// answer, and no_quorum when several answers tie for the most votes
+	Agreement string
+	// Conflicts lists every answer with the clients that returned it, the most common first
+	Conflicts []Conflict
+}
+
+// Clear reports whether at least two clients answered and the winner was returned by more of them than
+// any other answer, so that the vote says something about the data of each client
+func (d Decision) Clear() bool {
+	if len(d.Agreeing)+len(d.Dissenting) < 2 || len(d.Conflicts) == 0 || d.Conflicts[0].Value != d.Winner {
+		return false
+	}
+	return len(d.Conflicts) == 1 || len(d.Conflicts[1].Clients) < len(d.Conflicts[0].Clients)
+}
+
+// decideAnswers lets the strategy decide between the answers of the clients, vote turns an answer into its
+// client's vote. It returns the first answer of the winning value, the zero value when there is none.
+// When the strategy is not satisfied the answer is the best candidate and the error a *ConsensusError.
+func decideAnswers[T any](strategy ConsensusStrategy, answers []T, vote func(T) Vote) (T, Decision, error) {
+	votes := make([]Vote, len(answers))
+	for i, answer := range answers {
+		votes[i] = vote(answer)
+	}
+
+	outcome, err := strategy.Decide(votes)
+	decision := Decision{ConsensusOutcome: outcome, Conflicts: conflicts(votes)}
+	decision.Agreement = agreement(decision.Conflicts, len(votes))
+	if err != nil {
+		err = &ConsensusError{Err: err, Conflicts: decision.Conflicts}
+	}
+
+	var winner T
+	// A failed strategy may not even name a candidate
+	if len(outcome.Agreeing) > 0 {
+		for i, v := range votes {
+			if v.Value == outcome.Winner {
+				winner = answers[i]
+				break
+			}
+		}
+	}
+	return winner, decision, err
+}
+
+// ConsensusStrategy decides which of the clients' answers a fan-out request returns
+type ConsensusStrategy interface {
+	// Decide picks the winner among votes given in arrival order. It fails with ErrNoConsensus
+	// when the votes do not satisfy the strategy, the outcome then describes the best candidate.
+	Decide(votes []Vote) (ConsensusOutcome, error)
+}
+
+// NewConsensusStrategy creates the consensus strategy described by the configuration
+func NewConsensusStrategy(cfg config.ConsensusConfig) (ConsensusStrategy, error) {
+	switch cfg.Strategy {
+	case config.ConsensusPlurality, "":
+		return PluralityStrategy{}, nil
+	case config.ConsensusMajority:
+		return MajorityStrategy{}, nil
+	case config.ConsensusQuorum:
+		if cfg.Quorum <= 0 {
+			return nil, fmt.Errorf("quorum consensus requires a positive quorum, got %d", cfg.Quorum)
+		}
+		return QuorumStrategy{Quorum: cfg.Quorum}, nil
+	case config.ConsensusUnanimous:
+		return UnanimousStrategy{}, nil
+	case config.ConsensusWeighted:
+		return WeightedStrategy{Weights: cfg.Weights}, nil
+	case config.ConsensusHighestBlock:
+		return HighestBlockStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown consensus strategy %q", cfg.Strategy)
+	}
+}
+
+// newConsensusStrategy creates the consensus strategy of an endpoint, falling back to plurality for invalid settings
+func newConsensusStrategy(cfg config.ConsensusConfig) ConsensusStrategy {
+	strategy, err := NewConsensusStrategy(cfg)
+	if err != nil {
+		log.Printf("Invalid consensus configuration, falling back to plurality: %v\n", err)
+		return PluralityStrategy{}
+	}
+	return strategy
+}
+
+// PluralityStrategy returns the most common answer. A tie for the most votes is no consensus,
+// the earliest of the tied answers is then the best candidate.
+type PluralityStrategy struct{}
+
+func (PluralityStrategy) Decide(votes []Vote) (ConsensusOutcome, error) {
+	candidates, total := tally(votes, countVotes)
+	if len(candidates) == 0 {
+		return ConsensusOutcome{}, fmt.Errorf("%w: no answers", ErrNoConsensus)
+	}
+	return decided(votes, candidates[0], total), tie(candidates)
+}
+
+// MajorityStrategy requires more than half of the responses to agree
+type MajorityStrategy struct{}
+
+func (MajorityStrategy) Decide(votes []Vote) (ConsensusOutcome, error) {
+	candidates, total := tally(votes, countVotes)
+	if len(candidates) == 0 {
+		return ConsensusOutcome{}, fmt.Errorf("%w: no answers", ErrNoConsensus)
+	}
+
+	best := candidates[0]
+	outcome := decided(votes, best, total)
+	if best.weight*2 <= total {
+		return outcome, fmt.Errorf("%w: the most common answer has %.0f of %.0f votes, a majority is required", ErrNoConsensus, best.weight, total)
+	}
+	return outcome, nil
+}
+
+// QuorumStrategy requires at least Quorum clients to agree, whatever the number of responses
+type QuorumStrategy struct {
+	Quorum int
+}
+
+func (s QuorumStrategy) Decide(votes []Vote) (ConsensusOutcome, error) {
+	candidates, total := tally(votes, countVotes)
+	if len(candidates) == 0 {
+		return ConsensusOutcome{}, fmt.Errorf("%w: no answers", ErrNoConsensus)
+	}
+
+	best := candidates[0]
+	outcome := decided(votes, best, total)
+	if best.weight < float64(s.Quorum) {
+		return outcome, fmt.Errorf("%w: the most common answer has %.0f votes, %d are required", ErrNoConsensus, best.weight, s.Quorum)
+	}
+	return outcome, tie(candidates)
+}
+
+// UnanimousStrategy requires every response to agree
+type UnanimousStrategy struct{}
+
+func (UnanimousStrategy) Decide(votes []Vote) (ConsensusOutcome, error) {
+	candidates, total := tally(votes, countVotes)
+	if len(candidates) == 0 {
+		return ConsensusOutcome{}, fmt.Errorf("%w: no answers", ErrNoConsensus)
+	}
+
+	outcome := decided(votes, candidates[0], total)
+	if len(candidates) > 1 {
+		return outcome, fmt.Errorf("%w: clients returned %d different answers", ErrNoConsensus, len(candidates))
+	}
+	return outcome, nil
+}
+
+// WeightedStrategy returns the answer backed by the most trust weight, a tie is no consensus.
+// Clients missing from Weights weigh 1, a weight of 0 makes a client's answer count for nothing.
+type WeightedStrategy struct {
+	Weights map[string]float64
+}
+
+func (s WeightedStrategy) Decide(votes []Vote) (ConsensusOutcome, error) {
+	candidates, total := tally(votes, func(vote Vote) float64 {
+		if weight, ok := s.Weights[vote.Client]; ok {
+			return weight
+		}
+		return 1
+	})
+	if len(candidates) == 0 || candidates[0].weight == 0 {
+		return ConsensusOutcome{}, fmt.Errorf("%w: no trusted answers", ErrNoConsensus)
+	}
+	return decided(votes, candidates[0], total), tie(candidates)
+}
+
+// HighestBlockStrategy returns the answer of the client whose head is the furthest ahead,
+// the most common answer among clients sharing that head when there are several.
+// A tie between clients at that head is no consensus.
+type HighestBlockStrategy struct{}
+
+func (HighestBlockStrategy) Decide(votes []Vote) (ConsensusOutcome, error) {
+	if len(votes) == 0 {
+		return ConsensusOutcome{}, fmt.Errorf("%w: no answers", ErrNoConsensus)
+	}
+
+	highest := votes[0].Head
+	for _, vote := range votes[1:] {
+		highest = max(highest, vote.Head)
+	}
+	candidates, _ := tally(votes, func(vote Vote) float64 {
+		if vote.Head == highest {
+			return 1
+		}
+		return 0
+	})
+
+	// Confidence counts every client agreeing with the winner, not only those at the highest head
+	outcome := decided(votes, candidates[0], float64(len(votes)))
+	outcome.Confidence = float64(len(outcome.Agreeing)) / float64(len(votes))
+	return outcome, tie(candidates)
+}
+
+// candidate is an answer and the weight of the votes backing it
+type candidate struct {
+	value  string
+	weight float64
+}
+
+func countVotes(Vote) float64 { return 1 }
+
+// tie reports ErrNoConsensus when several answers share the most weight
+func tie(candidates []candidate) error {
+	tied := 0
+	for _, c := range candidates {
+		if c.weight == candidates[0].weight {
+			tied++
+		}
+	}
+	if tied > 1 {
+		return fmt.Errorf("%w: %d answers tie for the most support", ErrNoConsensus, tied)
+	}
+	return nil
+}
+
+// tally groups votes by value and returns the answers by descending weight, ties ordered by
+// their earliest vote, together with the total weight of all votes
+func tally(votes []Vote, weight func(Vote) float64) ([]candidate, float64) {
+	byValue := make(map[string]*candidate)
+	candidates := make([]*candidate, 0)
+	total := 0.0
+	for _, vote := range votes {
+		c, ok := byValue[vote.Value]
+		if !ok {
+			c = &candidate{value: vote.Value}
+			byValue[vote.Value] = c
+			candidates = append(candidates, c)
+		}
+		w := weight(vote)
+		c.weight += w
+		total += w
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].weight > candidates[j].weight })
+
+	ranked := make([]candidate, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = *c
+	}
+	return ranked, total
+}
+
+// decided builds the outcome of a winning answer, splitting the clients in vote order
+func decided(votes []Vote, winner candidate, total float64) ConsensusOutcome {
+	outcome := ConsensusOutcome{Winner: winner.value}
+	if total > 0 {
+		outcome.Confidence = winner.weight / total
+	}
+	for _, vote := range votes {
+		if vote.Value == winner.value {
+			outcome.Agreeing = append(outcome.Agreeing, vote.Client)
+		} else {
+			outcome.Dissenting = append(outcome.Dissenting, vote.Client)
+		}
+	}
+	return outcome
+}
+
+// agreement classifies the agreement between the clients from the votes grouped by answer, see Decision.Agreement
+func agreement(conflicts []Conflict, votes int) string {
+	switch {
+	case len(conflicts) <= 1:
+		return consensusUnanimous
+	case len(conflicts[1].Clients) == len(conflicts[0].Clients):
+		return consensusNoQuorum
+	case len(conflicts[0].Clients)*2 > votes:
+		return consensusMajority
+	default:
+		return consensusPlurality
+	}
+}
+
+// conflicts groups the votes by answer, the answers returned by the most clients first
+func conflicts(votes []Vote) []Conflict {
+	candidates, _ := tally(votes, countVotes)
+	byValue := make(map[string]int, len(candidates))
+	result := make([]Conflict, len(candidates))
+	for i, c := range candidates {
+		byValue[c.value] = i
+		result[i].Value = c.value
+	}
+	for _, vote := range votes {
+		i := byValue[vote.Value]
+		result[i].Clients = append(result[i].Clients, vote.Client)
+	}
+	return result
+}
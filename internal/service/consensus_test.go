@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/client/mocks"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusStrategies(t *testing.T) {
+	votes := func(values ...string) []Vote {
+		result := make([]Vote, len(values))
+		for i, value := range values {
+			result[i] = Vote{Client: string(rune('a' + i)), Value: value}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name       string
+		strategy   ConsensusStrategy
+		votes      []Vote
+		winner     string
+		agreeing   []string
+		dissenting []string
+		confidence float64
+		failed     bool
+	}{
+		{
+			name:       "plurality picks the most common answer",
+			strategy:   PluralityStrategy{},
+			votes:      votes("1", "2", "2", "3"),
+			winner:     "2",
+			agreeing:   []string{"b", "c"},
+			dissenting: []string{"a", "d"},
+			confidence: 0.5,
+		},
+		{
+			name:       "plurality fails on a tie, the earliest answer is the best candidate",
+			strategy:   PluralityStrategy{},
+			votes:      votes("2", "1", "1", "2"),
+			winner:     "2",
+			agreeing:   []string{"a", "d"},
+			dissenting: []string{"b", "c"},
+			confidence: 0.5,
+			failed:     true,
+		},
+		{
+			name:       "majority accepts more than half",
+			strategy:   MajorityStrategy{},
+			votes:      votes("1", "2", "1"),
+			winner:     "1",
+			agreeing:   []string{"a", "c"},
+			dissenting: []string{"b"},
+			confidence: 2.0 / 3,
+		},
+		{
+			name:       "majority rejects half",
+			strategy:   MajorityStrategy{},
+			votes:      votes("1", "2", "1", "2"),
+			winner:     "1",
+			agreeing:   []string{"a", "c"},
+			dissenting: []string{"b", "d"},
+			confidence: 0.5,
+			failed:     true,
+		},
+		{
+			name:       "quorum accepts enough agreeing clients",
+			strategy:   QuorumStrategy{Quorum: 2},
+			votes:      votes("1", "2", "3", "2"),
+			winner:     "2",
+			agreeing:   []string{"b", "d"},
+			dissenting: []string{"a", "c"},
+			confidence: 0.5,
+		},
+		{
+			name:       "quorum rejects too few agreeing clients",
+			strategy:   QuorumStrategy{Quorum: 3},
+			votes:      votes("1", "1"),
+			winner:     "1",
+			agreeing:   []string{"a", "b"},
+			confidence: 1,
+			failed:     true,
+		},
+		{
+			name:       "quorum rejects two answers reaching it",
+			strategy:   QuorumStrategy{Quorum: 2},
+			votes:      votes("1", "2", "2", "1"),
+			winner:     "1",
+			agreeing:   []string{"a", "d"},
+			dissenting: []string{"b", "c"},
+			confidence: 0.5,
+			failed:     true,
+		},
+		{
+			name:       "unanimous accepts identical answers",
+			strategy:   UnanimousStrategy{},
+			votes:      votes("1", "1", "1"),
+			winner:     "1",
+			agreeing:   []string{"a", "b", "c"},
+			confidence: 1,
+		},
+		{
+			name:       "unanimous rejects a single dissenter",
+			strategy:   UnanimousStrategy{},
+			votes:      votes("1", "1", "2"),
+			winner:     "1",
+			agreeing:   []string{"a", "b"},
+			dissenting: []string{"c"},
+			confidence: 2.0 / 3,
+			failed:     true,
+		},
+		{
+			name:       "weighted lets a trusted client outvote others",
+			strategy:   WeightedStrategy{Weights: map[string]float64{"c": 3}},
+			votes:      votes("1", "1", "2"),
+			winner:     "2",
+			agreeing:   []string{"c"},
+			dissenting: []string{"a", "b"},
+			confidence: 0.6,
+		},
+		{
+			name:     "weighted fails without trusted answers",
+			strategy: WeightedStrategy{Weights: map[string]float64{"a": 0, "b": 0}},
+			votes:    votes("1", "2"),
+			failed:   true,
+		},
+		{
+			name:     "highest block follows the client furthest ahead",
+			strategy: HighestBlockStrategy{},
+			votes: []Vote{
+				{Client: "a", Value: "1", Head: 100},
+				{Client: "b", Value: "1", Head: 100},
+				{Client: "c", Value: "2", Head: 101},
+			},
+			winner:     "2",
+			agreeing:   []string{"c"},
+			dissenting: []string{"a", "b"},
+			confidence: 1.0 / 3,
+		},
+		{
+			name:     "highest block takes the most common answer at the highest head",
+			strategy: HighestBlockStrategy{},
+			votes: []Vote{
+				{Client: "a", Value: "1", Head: 101},
+				{Client: "b", Value: "2", Head: 101},
+				{Client: "c", Value: "2", Head: 101},
+				{Client: "d", Value: "1", Head: 99},
+			},
+			winner:     "2",
+			agreeing:   []string{"b", "c"},
+			dissenting: []string{"a", "d"},
+			confidence: 0.5,
+		},
+		{
+			name:     "no answers",
+			strategy: PluralityStrategy{},
+			failed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, err := tt.strategy.Decide(tt.votes)
+
+			if tt.failed {
+				assert.ErrorIs(t, err, ErrNoConsensus)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.winner, outcome.Winner)
+			assert.Equal(t, tt.agreeing, outcome.Agreeing)
+			assert.Equal(t, tt.dissenting, outcome.Dissenting)
+			assert.InDelta(t, tt.confidence, outcome.Confidence, 1e-9)
+		})
+	}
+}
+
+func TestNewConsensusStrategy(t *testing.T) {
+	strategy, err := NewConsensusStrategy(config.ConsensusConfig{Strategy: config.ConsensusQuorum, Quorum: 3})
+	require.NoError(t, err)
+	assert.Equal(t, QuorumStrategy{Quorum: 3}, strategy)
+
+	strategy, err = NewConsensusStrategy(config.ConsensusConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, PluralityStrategy{}, strategy)
+
+	_, err = NewConsensusStrategy(config.ConsensusConfig{Strategy: config.ConsensusQuorum})
+	assert.Error(t, err)
+	_, err = NewConsensusStrategy(config.ConsensusConfig{Strategy: "median"})
+	assert.Error(t, err)
+	assert.Equal(t, PluralityStrategy{}, newConsensusStrategy(config.ConsensusConfig{Strategy: "median"}))
+}
+
+func TestBalanceService_GetBalanceWithoutConsensus(t *testing.T) {
+	responses := []client.BalanceResponse{
+		{ClientName: "client1", Balance: big.NewInt(1000)},
+		{ClientName: "client2", Balance: big.NewInt(2000)},
+	}
+
+	for _, onFailure := range []string{config.FailOpen, config.FailClosed} {
+		t.Run(onFailure, func(t *testing.T) {
+			mockPool := new(mocks.Pool)
+			mockPool.On("GetClientsForBlock", client.LatestBlock).Return(testClients, nil)
+			mockPool.On("QueryBalanceFromClients", mock.Anything, testClients, "0x123", client.LatestBlock).Return(responses, nil)
+
+			service := NewBalanceService(mockPool, config.BalanceConfig{Consensus: config.ConsensusConfig{
+				Strategy:  config.ConsensusPlurality,
+				OnFailure: onFailure,
+			}})
+
+			result, err := service.GetBalance(context.Background(), "0x123", client.LatestBlock)
+			mockPool.AssertExpectations(t)
+
+			if onFailure == config.FailOpen {
+				require.NoError(t, err)
+				assert.Equal(t, 0, big.NewInt(1000).Cmp(result.Balance), "fail-open returns the first answer of a tie")
+				return
+			}
+
+			var consensusErr *ConsensusError
+			require.ErrorAs(t, err, &consensusErr)
+			assert.ErrorIs(t, err, ErrNoConsensus)
+			assert.Nil(t, result)
+			assert.Equal(t, []Conflict{
+				{Value: "1000", Clients: []string{"client1"}},
+				{Value: "2000", Clients: []string{"client2"}},
+			}, consensusErr.Conflicts)
+		})
+	}
+
+	t.Run("options override the configuration", func(t *testing.T) {
+		mockPool := new(mocks.Pool)
+		mockPool.On("GetClientsForBlock", client.LatestBlock).Return(testClients, nil)
+		mockPool.On("QueryBalanceFromClients", mock.Anything, testClients, "0x123", client.LatestBlock).Return(responses, nil)
+
+		service := NewBalanceService(mockPool, config.BalanceConfig{Consensus: config.ConsensusConfig{OnFailure: config.FailOpen}})
+
+		_, err := service.GetBalanceWithOptions(context.Background(), "0x123", client.LatestBlock,
+			BalanceOptions{OnFailure: config.FailClosed})
+		assert.ErrorIs(t, err, ErrNoConsensus)
+	})
+}
+
+func TestBalanceService_GetBalanceUsesConsensusStrategy(t *testing.T) {
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetClientsForBlock", client.LatestBlock).Return(testClients, nil)
+	mockPool.On("QueryBalanceFromClients", mock.Anything, testClients, "0x123", client.LatestBlock).
+		Return([]client.BalanceResponse{
+			{ClientName: "client1", Balance: big.NewInt(1000)},
+			{ClientName: "client2", Balance: big.NewInt(2000)},
+			{ClientName: "client3", Balance: big.NewInt(1000)},
+		}, nil)
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{Consensus: config.ConsensusConfig{
+		Strategy: config.ConsensusWeighted,
+		Weights:  map[string]float64{"client2": 5},
+	}})
+
+	result, err := service.GetBalance(context.Background(), "0x123", client.LatestBlock)
+
+	mockPool.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Equal(t, 0, big.NewInt(2000).Cmp(result.Balance))
+}
+
+func TestBalanceService_GetBalanceWithoutTrustedAnswers(t *testing.T) {
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetClientsForBlock", client.LatestBlock).Return(testClients, nil)
+	mockPool.On("QueryBalanceFromClients", mock.Anything, testClients, "0x123", client.LatestBlock).
+		Return([]client.BalanceResponse{
+			{ClientName: "client1", Balance: big.NewInt(1000)},
+			{ClientName: "client2", Balance: big.NewInt(1000)},
+		}, nil)
+
+	service := NewBalanceService(mockPool, config.BalanceConfig{Consensus: config.ConsensusConfig{
+		Strategy:  config.ConsensusWeighted,
+		Weights:   map[string]float64{"client1": 0, "client2": 0},
+		OnFailure: config.FailOpen,
+	}})
+
+	result, err := service.GetBalance(context.Background(), "0x123", client.LatestBlock)
+
+	mockPool.AssertExpectations(t)
+	assert.ErrorIs(t, err, ErrNoConsensus, "fail-open has no candidate to return")
+	assert.Nil(t, result)
+}
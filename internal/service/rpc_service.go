@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/bersh/alluvial_test_1/internal/metrics"
+)
+
+// ErrMethodNotAllowed is returned when a JSON-RPC method is not in the allowlist
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+// RPCService proxies allowlisted JSON-RPC methods to the client pool
+type RPCService struct {
+	clientPool    client.Pool
+	selector      client.Selector
+	strategy      ConsensusStrategy
+	onFailure     string
+	allowed       map[string]bool
+	policies      map[string]string
+	defaultPolicy string
+}
+
+// NewRPCService creates a new JSON-RPC proxy service
+func NewRPCService(clientPool client.Pool, cfg config.RPCConfig) *RPCService {
+	allowed := make(map[string]bool, len(cfg.AllowedMethods))
+	for _, method := range cfg.AllowedMethods {
+		allowed[method] = true
+	}
+
+	defaultPolicy := cfg.DefaultPolicy
+	if defaultPolicy == "" {
+		defaultPolicy = config.PolicyConsensus
+	}
+
+	return &RPCService{
+		clientPool:    clientPool,
+		selector:      newSelector(cfg.Routing),
+		strategy:      newConsensusStrategy(cfg.Consensus),
+		onFailure:     cfg.Consensus.OnFailure,
+		allowed:       allowed,
+		policies:      cfg.MethodPolicies,
+		defaultPolicy: defaultPolicy,
+	}
+}
+
+// IsAllowed reports whether a method may be proxied
+func (s *RPCService) IsAllowed(method string) bool {
+	return s.allowed[method]
+}
+
+// Call sends an allowlisted JSON-RPC method to the client pool and returns the consensus result
+func (s *RPCService) Call(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+	if !s.IsAllowed(method) {
+		return nil, fmt.Errorf("%w: %s", ErrMethodNotAllowed, method)
+	}
+
+	clients := s.selector.Select(s.clientPool.GetAvailableClients())
+	responses, err := s.clientPool.CallFromClients(ctx, clients, method, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", method, err)
+	}
+
+	return s.resolve(method, responses, clientHeads(clients))
+}
+
+// CallBatch sends a batch of JSON-RPC calls to the client pool in a single upstream
+// batch per client and returns a consensus result for every call, in call order.
+// Calls that fail are reported individually without failing the whole batch.
+func (s *RPCService) CallBatch(ctx context.Context, calls []client.Call) []client.CallResult {
+	results := make([]client.CallResult, len(calls))
+
+	upstreamCalls := make([]client.Call, 0, len(calls))
+	positions := make([]int, 0, len(calls))
+	for i, call := range calls {
+		if !s.IsAllowed(call.Method) {
+			results[i] = client.CallResult{Error: fmt.Errorf("%w: %s", ErrMethodNotAllowed, call.Method)}
+			continue
+		}
+		upstreamCalls = append(upstreamCalls, call)
+		positions = append(positions, i)
+	}
+
+	if len(upstreamCalls) == 0 {
+		return results
+	}
+
+	clients := s.selector.Select(s.clientPool.GetAvailableClients())
+	batchResponses, err := s.clientPool.BatchCallFromClients(ctx, clients, upstreamCalls)
+	if err != nil {
+		for _, i := range positions {
+			results[i] = client.CallResult{Error: fmt.Errorf("failed to send batch: %w", err)}
+		}
+		return results
+	}
+
+	heads := clientHeads(clients)
+	for j, i := range positions {
+		method := upstreamCalls[j].Method
+
+		responses := make([]client.CallResponse, 0, len(batchResponses))
+		var itemErr error
+		for _, batch := range batchResponses {
+			result := batch.Results[j]
+			if result.Error != nil {
+				itemErr = preferRPCError(itemErr, result.Error)
+				continue
+			}
+			responses = append(responses, client.CallResponse{
+				ClientName: batch.ClientName,
+				Result:     result.Result,
+			})
+		}
+
+		if len(responses) == 0 {
+			results[i] = client.CallResult{Error: fmt.Errorf("failed to call %s: %w", method, itemErr)}
+			continue
+		}
+		result, err := s.resolve(method, responses, heads)
+		results[i] = client.CallResult{Result: result, Error: err}
+	}
+
+	return results
+}
+
+// resolve applies the consensus policy of a method to the successful responses.
+// A result the strategy could not agree on is returned anyway when failing open, unless there is no candidate at all.
+func (s *RPCService) resolve(method string, responses []client.CallResponse, heads map[string]uint64) (json.RawMessage, error) {
+	strategy := s.strategy
+	switch s.policyFor(method) {
+	case config.PolicyFirst:
+		return responses[0].Result, nil
+	case config.PolicyPlurality:
+		strategy = PluralityStrategy{}
+	}
+
+	winner, decision, err := decideAnswers(strategy, responses, func(resp client.CallResponse) Vote {
+		return Vote{Client: resp.ClientName, Value: canonicalJSON(resp.Result), Head: heads[resp.ClientName]}
+	})
+	metrics.RecordConsensusOutcome(method, decision.Agreement)
+	result := winner.Result
+
+	if len(decision.Dissenting) > 0 {
+		log.Printf("Result discrepancy detected for method %s\n", method)
+		metrics.RecordRPCDiscrepancy(method)
+	}
+	if err != nil {
+		if result == nil || s.onFailure == config.FailClosed {
+			return nil, fmt.Errorf("failed to call %s: %w", method, err)
+		}
+		log.Printf("Returning the best result for method %s without consensus: %v\n", method, err)
+	}
+
+	return result, nil
+}
+
+// preferRPCError keeps upstream RPC errors over other failures so that
+// deterministic errors such as reverted calls reach the caller
+func preferRPCError(current, next error) error {
+	var rpcErr *client.RPCError
+	if current != nil && errors.As(current, &rpcErr) {
+		return current
+	}
+	return next
+}
+
+func (s *RPCService) policyFor(method string) string {
+	if policy, ok := s.policies[method]; ok {
+		return policy
+	}
+	return s.defaultPolicy
+}
+
+// canonicalJSON returns a whitespace-insensitive representation of a raw JSON value
+func canonicalJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
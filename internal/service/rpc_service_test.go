@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/bersh/alluvial_test_1/internal/client"
+	"github.com/bersh/alluvial_test_1/internal/client/mocks"
+	"github.com/bersh/alluvial_test_1/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCService_Call(t *testing.T) {
+	rpcConfig := config.RPCConfig{
+		AllowedMethods: []string{"eth_chainId", "eth_blockNumber"},
+		MethodPolicies: map[string]string{"eth_blockNumber": config.PolicyFirst},
+		DefaultPolicy:  config.PolicyPlurality,
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		mockResponses  []client.CallResponse
+		mockError      error
+		expectedResult string
+		expectedError  error
+	}{
+		{
+			name:   "Plurality policy returns the most common result",
+			method: "eth_chainId",
+			mockResponses: []client.CallResponse{
+				{ClientName: "client1", Result: json.RawMessage(`"0x5"`)},
+				{ClientName: "client2", Result: json.RawMessage(`"0x1"`)},
+				{ClientName: "client3", Result: json.RawMessage(` "0x1" `)},
+			},
+			expectedResult: `"0x1"`,
+		},
+		{
+			name:   "First policy returns the first response",
+			method: "eth_blockNumber",
+			mockResponses: []client.CallResponse{
+				{ClientName: "client1", Result: json.RawMessage(`"0x10"`)},
+				{ClientName: "client2", Result: json.RawMessage(`"0x11"`)},
+				{ClientName: "client3", Result: json.RawMessage(`"0x11"`)},
+			},
+			expectedResult: `"0x10"`,
+		},
+		{
+			name:          "Method outside the allowlist is rejected",
+			method:        "eth_sendRawTransaction",
+			expectedError: ErrMethodNotAllowed,
+		},
+		{
+			name:          "Upstream RPC error is propagated",
+			method:        "eth_chainId",
+			mockError:     &client.RPCError{Code: -32000, Message: "execution reverted"},
+			expectedError: &client.RPCError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPool := new(mocks.Pool)
+
+			if tt.mockResponses != nil || tt.mockError != nil {
+				mockPool.On("GetAvailableClients").Return(testClients)
+				mockPool.On("CallFromClients",
+					mock.Anything, // context
+					testClients,
+					tt.method,
+					mock.Anything, // params
+				).Return(tt.mockResponses, tt.mockError)
+			}
+
+			service := NewRPCService(mockPool, rpcConfig)
+
+			result, err := service.Call(context.Background(), tt.method, json.RawMessage("[]"))
+
+			mockPool.AssertExpectations(t)
+
+			switch expected := tt.expectedError.(type) {
+			case nil:
+				assert.NoError(t, err)
+				assert.JSONEq(t, tt.expectedResult, string(result))
+			case *client.RPCError:
+				assert.ErrorAs(t, err, &expected)
+			default:
+				assert.True(t, errors.Is(err, expected), "Expected %v, got %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestRPCService_CallUsesConsensusStrategy(t *testing.T) {
+	responses := []client.CallResponse{
+		{ClientName: "client1", Result: json.RawMessage(`"0x5"`)},
+		{ClientName: "client2", Result: json.RawMessage(`"0x1"`)},
+		{ClientName: "client3", Result: json.RawMessage(`"0x1"`)},
+	}
+	call := func(t *testing.T, cfg config.RPCConfig, method string) (json.RawMessage, error) {
+		mockPool := new(mocks.Pool)
+		mockPool.On("GetAvailableClients").Return(testClients)
+		mockPool.On("CallFromClients", mock.Anything, testClients, method, mock.Anything).Return(responses, nil)
+		defer mockPool.AssertExpectations(t)
+
+		return NewRPCService(mockPool, cfg).Call(context.Background(), method, json.RawMessage("[]"))
+	}
+
+	rpcConfig := config.RPCConfig{
+		AllowedMethods: []string{"eth_call", "eth_chainId"},
+		MethodPolicies: map[string]string{"eth_chainId": config.PolicyPlurality},
+		DefaultPolicy:  config.PolicyConsensus,
+		Consensus: config.ConsensusConfig{
+			Strategy: config.ConsensusWeighted,
+			Weights:  map[string]float64{"client1": 5},
+		},
+	}
+
+	result, err := call(t, rpcConfig, "eth_call")
+	require.NoError(t, err)
+	assert.JSONEq(t, `"0x5"`, string(result), "the consensus policy follows the configured strategy")
+
+	result, err = call(t, rpcConfig, "eth_chainId")
+	require.NoError(t, err)
+	assert.JSONEq(t, `"0x1"`, string(result), "the plurality policy ignores it")
+
+	rpcConfig.Consensus.Weights = map[string]float64{"client1": 0, "client2": 0, "client3": 0}
+	_, err = call(t, rpcConfig, "eth_call")
+	assert.ErrorIs(t, err, ErrNoConsensus, "no result without a trusted answer")
+
+	rpcConfig.Consensus = config.ConsensusConfig{Strategy: config.ConsensusUnanimous, OnFailure: config.FailOpen}
+	result, err = call(t, rpcConfig, "eth_call")
+	require.NoError(t, err)
+	assert.JSONEq(t, `"0x1"`, string(result), "failing open returns the best result")
+
+	rpcConfig.Consensus.OnFailure = config.FailClosed
+	_, err = call(t, rpcConfig, "eth_call")
+	var consensusErr *ConsensusError
+	assert.ErrorAs(t, err, &consensusErr, "failing closed returns no result without consensus")
+}
+
+func TestRPCService_CallBatch(t *testing.T) {
+	rpcConfig := config.RPCConfig{
+		AllowedMethods: []string{"eth_getBalance", "eth_call"},
+		DefaultPolicy:  config.PolicyPlurality,
+	}
+
+	mockPool := new(mocks.Pool)
+	mockPool.On("GetAvailableClients").Return(testClients)
+	mockPool.On("BatchCallFromClients",
+		mock.Anything, // context
+		testClients,
+		mock.MatchedBy(func(calls []client.Call) bool { return len(calls) == 2 }),
+	).Return([]client.BatchResponse{
+		{
+			ClientName: "client1",
+			Results: []client.CallResult{
+				{Result: json.RawMessage(`"0x3e8"`)},
+				{Error: &client.RPCError{Code: 3, Message: "execution reverted"}},
+			},
+		},
+		{
+			ClientName: "client2",
+			Results: []client.CallResult{
+				{Result: json.RawMessage(`"0x3e8"`)},
+				{Error: errors.New("no response for request in batch")},
+			},
+		},
+	}, nil)
+
+	service := NewRPCService(mockPool, rpcConfig)
+
+	results := service.CallBatch(context.Background(), []client.Call{
+		{Method: "eth_getBalance", Params: json.RawMessage(`["0x123","latest"]`)},
+		{Method: "eth_sendRawTransaction", Params: json.RawMessage(`["0x00"]`)},
+		{Method: "eth_call", Params: json.RawMessage(`[{},"latest"]`)},
+	})
+
+	mockPool.AssertExpectations(t)
+
+	assert.Len(t, results, 3)
+
+	assert.NoError(t, results[0].Error)
+	assert.JSONEq(t, `"0x3e8"`, string(results[0].Result))
+
+	assert.ErrorIs(t, results[1].Error, ErrMethodNotAllowed)
+
+	var rpcErr *client.RPCError
+	assert.ErrorAs(t, results[2].Error, &rpcErr)
+	assert.Equal(t, 3, rpcErr.Code)
+}